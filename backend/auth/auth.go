@@ -0,0 +1,53 @@
+// Package auth verifies the bearer tokens that identify API callers
+package auth
+
+import (
+	"context"
+	"net/http"
+)
+
+// Identity is the verified caller of a request
+type Identity struct {
+	UID   string
+	Email string
+	Name  string
+}
+
+// contextKey keeps the identity's context key private to this package
+type contextKey struct{}
+
+// WithIdentity returns a copy of ctx carrying the caller's identity
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, contextKey{}, identity)
+}
+
+// FromContext returns the verified caller, if the request was authenticated
+func FromContext(ctx context.Context) (*Identity, bool) {
+	identity, ok := ctx.Value(contextKey{}).(*Identity)
+	return identity, ok
+}
+
+// claimsKey marks requests whose claimed uid is trusted
+type claimsKey struct{}
+
+// TrustClaims returns a copy of ctx in which the uid a caller claims for
+// itself counts, as on a server with authentication disabled
+func TrustClaims(ctx context.Context) context.Context {
+	return context.WithValue(ctx, claimsKey{}, true)
+}
+
+// ClaimsTrusted reports whether TrustClaims marked the request
+func ClaimsTrusted(ctx context.Context) bool {
+	trusted, _ := ctx.Value(claimsKey{}).(bool)
+	return trusted
+}
+
+// CallerUID returns the verified uid of the caller. Without authentication
+// it falls back to the uid the client claims for itself, which is only
+// trustworthy on a server that has authentication turned off.
+func CallerUID(r *http.Request, claimed string) string {
+	if identity, ok := FromContext(r.Context()); ok {
+		return identity.UID
+	}
+	return claimed
+}
@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+)
+
+// jsonWebKey is one key of a JWKS document, as far as it is needed to verify
+// signatures
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	// Symmetric
+	K string `json:"k"`
+}
+
+// loadJWKS reads the signing keys from a local JWKS file. Keys for other
+// uses or algorithms are skipped with a warning.
+func loadJWKS(path string) ([]verificationKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS file: %w", err)
+	}
+	var document struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS file %s: %w", path, err)
+	}
+
+	var keys []verificationKey
+	for i, jwk := range document.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.verificationKey()
+		if err != nil {
+			log.Printf("⚠️  Skipping key %d (%q) of %s: %v", i, jwk.Kid, path, err)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// verificationKey converts a JWK into a key bound to its algorithm
+func (jwk jsonWebKey) verificationKey() (verificationKey, error) {
+	key := verificationKey{id: jwk.Kid}
+	var err error
+	switch jwk.Kty {
+	case "RSA":
+		key.alg = AlgRS256
+		key.key, err = jwk.rsaKey()
+	case "EC":
+		key.alg = AlgES256
+		key.key, err = jwk.ecKey()
+	case "oct":
+		key.alg = AlgHS256
+		key.key, err = base64.RawURLEncoding.DecodeString(jwk.K)
+	default:
+		return key, fmt.Errorf("unsupported key type %q", jwk.Kty)
+	}
+	if err != nil {
+		return key, err
+	}
+	if jwk.Alg != "" && jwk.Alg != key.alg {
+		return key, fmt.Errorf("unsupported algorithm %q for %s keys", jwk.Alg, jwk.Kty)
+	}
+	return key, nil
+}
+
+// rsaKey decodes an RSA public key
+func (jwk jsonWebKey) rsaKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+	if err != nil || len(n) == 0 {
+		return nil, errors.New("invalid modulus")
+	}
+	e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+	if err != nil || len(e) == 0 || len(e) > 4 {
+		return nil, errors.New("invalid exponent")
+	}
+	key := &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}
+	if key.N.BitLen() < 2048 {
+		return nil, fmt.Errorf("RSA key of %d bits is too small", key.N.BitLen())
+	}
+	return key, nil
+}
+
+// ecKey decodes a P-256 public key, checking that the point is on the curve
+func (jwk jsonWebKey) ecKey() (*ecdsa.PublicKey, error) {
+	if jwk.Crv != "P-256" {
+		return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+	}
+	x, errX := base64.RawURLEncoding.DecodeString(jwk.X)
+	y, errY := base64.RawURLEncoding.DecodeString(jwk.Y)
+	if errX != nil || errY != nil || len(x) != 32 || len(y) != 32 {
+		return nil, errors.New("invalid coordinates")
+	}
+	point := append(append([]byte{4}, x...), y...)
+	if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+		return nil, errors.New("point is not on the curve")
+	}
+	return &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(x),
+		Y:     new(big.Int).SetBytes(y),
+	}, nil
+}
@@ -0,0 +1,210 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/logoes0/peeriodic.git/config"
+)
+
+// ErrInvalidToken is wrapped by every verification failure
+var ErrInvalidToken = errors.New("invalid token")
+
+// Supported signing algorithms
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+)
+
+// verificationKey is a key and the one algorithm it may verify. Tying the
+// algorithm to the key keeps a token from choosing how it is checked, e.g.
+// HS256 with an RSA public key as the secret.
+type verificationKey struct {
+	id  string
+	alg string
+	// key is a []byte secret, *rsa.PublicKey or *ecdsa.PublicKey
+	key any
+}
+
+// Verifier checks signed JWTs against the configured keys and claims
+type Verifier struct {
+	keys      []verificationKey
+	issuer    string
+	audience  string
+	uidClaim  string
+	clockSkew time.Duration
+	now       func() time.Time
+}
+
+// NewVerifier builds a verifier from the configured secret and JWKS file. It
+// returns nil when neither is set, meaning authentication is off.
+func NewVerifier(cfg config.AuthConfig) (*Verifier, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+
+	v := &Verifier{
+		issuer:    cfg.Issuer,
+		audience:  cfg.Audience,
+		uidClaim:  cfg.UIDClaim,
+		clockSkew: cfg.ClockSkew,
+		now:       time.Now,
+	}
+	if v.uidClaim == "" {
+		v.uidClaim = "sub"
+	}
+	if cfg.JWTSecret != "" {
+		v.keys = append(v.keys, verificationKey{alg: AlgHS256, key: []byte(cfg.JWTSecret)})
+	}
+	if cfg.JWKSFile != "" {
+		keys, err := loadJWKS(cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		v.keys = append(v.keys, keys...)
+	}
+	if len(v.keys) == 0 {
+		return nil, fmt.Errorf("no usable signing keys in %s", cfg.JWKSFile)
+	}
+	return v, nil
+}
+
+// tokenHeader is the JOSE header of a JWT
+type tokenHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Verify checks a token's signature, expiry, issuer and audience and returns
+// the identity it carries
+func (v *Verifier) Verify(token string) (*Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed", ErrInvalidToken)
+	}
+
+	var header tokenHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrInvalidToken, err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature: %v", ErrInvalidToken, err)
+	}
+	if !v.verifySignature(header, parts[0]+"."+parts[1], signature) {
+		return nil, fmt.Errorf("%w: signature does not match any %s key", ErrInvalidToken, header.Alg)
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: claims: %v", ErrInvalidToken, err)
+	}
+	if err := v.checkClaims(claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	uid, _ := claims[v.uidClaim].(string)
+	if uid == "" {
+		return nil, fmt.Errorf("%w: missing %s claim", ErrInvalidToken, v.uidClaim)
+	}
+	identity := &Identity{UID: uid}
+	identity.Email, _ = claims["email"].(string)
+	identity.Name, _ = claims["name"].(string)
+	return identity, nil
+}
+
+// verifySignature reports whether one of the keys for the header's algorithm,
+// and key ID when it names one, signed the input
+func (v *Verifier) verifySignature(header tokenHeader, input string, signature []byte) bool {
+	digest := sha256.Sum256([]byte(input))
+	for _, k := range v.keys {
+		if k.alg != header.Alg || (header.Kid != "" && k.id != "" && k.id != header.Kid) {
+			continue
+		}
+		switch key := k.key.(type) {
+		case []byte:
+			mac := hmac.New(sha256.New, key)
+			mac.Write([]byte(input))
+			if hmac.Equal(signature, mac.Sum(nil)) {
+				return true
+			}
+		case *rsa.PublicKey:
+			if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil {
+				return true
+			}
+		case *ecdsa.PublicKey:
+			// JWS carries the two halves of the signature side by side
+			if len(signature) == 64 {
+				r := new(big.Int).SetBytes(signature[:32])
+				s := new(big.Int).SetBytes(signature[32:])
+				if ecdsa.Verify(key, digest[:], r, s) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// checkClaims validates the registered claims. exp is required; nbf, iss and
+// aud are checked when present or configured.
+func (v *Verifier) checkClaims(claims map[string]any) error {
+	now := v.now()
+
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return errors.New("missing exp claim")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(v.clockSkew)) {
+		return errors.New("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(v.clockSkew).Before(time.Unix(int64(nbf), 0)) {
+		return errors.New("token not valid yet")
+	}
+
+	if v.issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != v.issuer {
+			return fmt.Errorf("unexpected issuer %q", iss)
+		}
+	}
+	if v.audience != "" && !hasAudience(claims["aud"], v.audience) {
+		return errors.New("token not meant for this audience")
+	}
+	return nil
+}
+
+// hasAudience reports whether an aud claim, a string or list of strings,
+// names the audience
+func hasAudience(aud any, audience string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == audience
+	case []any:
+		for _, a := range aud {
+			if a == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// decodeSegment decodes a base64url JSON segment of a token
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
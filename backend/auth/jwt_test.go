@@ -0,0 +1,271 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/logoes0/peeriodic.git/config"
+)
+
+const testSecret = "test-secret"
+
+var testNow = time.Unix(1_700_000_000, 0)
+
+// sign builds a token with the given header and claims, signing it with key:
+// a []byte secret, *rsa.PrivateKey or *ecdsa.PrivateKey
+func sign(t *testing.T, header map[string]string, claims map[string]any, key any) string {
+	t.Helper()
+	h, _ := json.Marshal(header)
+	c, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	digest := sha256.Sum256([]byte(input))
+
+	var signature []byte
+	switch key := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(input))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatalf("SignPKCS1v15() error = %v", err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			t.Fatalf("ecdsa.Sign() error = %v", err)
+		}
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// validClaims returns claims the test verifiers accept
+func validClaims() map[string]any {
+	return map[string]any{
+		"sub":   "user-1",
+		"email": "user@example.com",
+		"iss":   "https://issuer.example.com",
+		"aud":   "peeriodic",
+		"exp":   testNow.Add(time.Hour).Unix(),
+	}
+}
+
+// withClaim returns the valid claims with one changed, or removed when value
+// is nil
+func withClaim(name string, value any) map[string]any {
+	claims := validClaims()
+	if value == nil {
+		delete(claims, name)
+	} else {
+		claims[name] = value
+	}
+	return claims
+}
+
+func newTestVerifier(t *testing.T, cfg config.AuthConfig) *Verifier {
+	t.Helper()
+	cfg.Issuer = "https://issuer.example.com"
+	cfg.Audience = "peeriodic"
+	cfg.ClockSkew = time.Minute
+	v, err := NewVerifier(cfg)
+	if err != nil {
+		t.Fatalf("NewVerifier() error = %v", err)
+	}
+	v.now = func() time.Time { return testNow }
+	return v
+}
+
+func TestVerifyHS256(t *testing.T) {
+	v := newTestVerifier(t, config.AuthConfig{JWTSecret: testSecret})
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	hs256 := map[string]string{"alg": AlgHS256, "typ": "JWT"}
+
+	tests := []struct {
+		name   string
+		token  string
+		wantOK bool
+	}{
+		{"valid", sign(t, hs256, validClaims(), []byte(testSecret)), true},
+		{"wrong secret", sign(t, hs256, validClaims(), []byte("other")), false},
+		{"none algorithm", noneToken(validClaims()), false},
+		{"RS256 against the secret", sign(t, map[string]string{"alg": AlgRS256}, validClaims(), rsaKey), false},
+		{"expired", sign(t, hs256, withClaim("exp", testNow.Add(-2*time.Minute).Unix()), []byte(testSecret)), false},
+		{"expired within the skew", sign(t, hs256, withClaim("exp", testNow.Add(-30*time.Second).Unix()), []byte(testSecret)), true},
+		{"missing exp", sign(t, hs256, withClaim("exp", nil), []byte(testSecret)), false},
+		{"not valid yet", sign(t, hs256, withClaim("nbf", testNow.Add(2*time.Minute).Unix()), []byte(testSecret)), false},
+		{"valid soon within the skew", sign(t, hs256, withClaim("nbf", testNow.Add(30*time.Second).Unix()), []byte(testSecret)), true},
+		{"wrong issuer", sign(t, hs256, withClaim("iss", "https://evil.example.com"), []byte(testSecret)), false},
+		{"wrong audience", sign(t, hs256, withClaim("aud", "other"), []byte(testSecret)), false},
+		{"audience list", sign(t, hs256, withClaim("aud", []string{"other", "peeriodic"}), []byte(testSecret)), true},
+		{"missing audience", sign(t, hs256, withClaim("aud", nil), []byte(testSecret)), false},
+		{"missing subject", sign(t, hs256, withClaim("sub", nil), []byte(testSecret)), false},
+		{"malformed", "not-a-token", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := v.Verify(tt.token)
+			if !tt.wantOK {
+				if !errors.Is(err, ErrInvalidToken) {
+					t.Fatalf("Verify() error = %v, want ErrInvalidToken", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			if identity.UID != "user-1" || identity.Email != "user@example.com" {
+				t.Errorf("Verify() = %+v, want user-1 <user@example.com>", identity)
+			}
+		})
+	}
+}
+
+// noneToken builds an unsigned token
+func noneToken(claims map[string]any) string {
+	h, _ := json.Marshal(map[string]string{"alg": "none"})
+	c, _ := json.Marshal(claims)
+	return base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c) + "."
+}
+
+// writeJWKS writes a JWKS file holding keys and returns its path
+func writeJWKS(t *testing.T, keys ...map[string]string) string {
+	t.Helper()
+	data, err := json.Marshal(map[string]any{"keys": keys})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	return path
+}
+
+func rsaJWK(kid string, key *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA",
+		"kid": kid,
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func ecJWK(kid string, key *ecdsa.PublicKey) map[string]string {
+	x, y := make([]byte, 32), make([]byte, 32)
+	key.X.FillBytes(x)
+	key.Y.FillBytes(y)
+	return map[string]string{
+		"kty": "EC",
+		"kid": kid,
+		"crv": "P-256",
+		"x":   base64.RawURLEncoding.EncodeToString(x),
+		"y":   base64.RawURLEncoding.EncodeToString(y),
+	}
+}
+
+func TestVerifyJWKS(t *testing.T) {
+	rsaKey1, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	rsaKey2, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	path := writeJWKS(t,
+		rsaJWK("rsa-1", &rsaKey1.PublicKey),
+		rsaJWK("rsa-2", &rsaKey2.PublicKey),
+		ecJWK("ec-1", &ecKey.PublicKey),
+		map[string]string{"kty": "RSA", "kid": "enc", "use": "enc"},
+	)
+	v := newTestVerifier(t, config.AuthConfig{JWKSFile: path})
+
+	// The RSA public key as an HMAC secret, as in the classic key confusion
+	// attack
+	rsaPublic := x509.MarshalPKCS1PublicKey(&rsaKey1.PublicKey)
+
+	tests := []struct {
+		name   string
+		header map[string]string
+		key    any
+		wantOK bool
+	}{
+		{"RS256 with its kid", map[string]string{"alg": AlgRS256, "kid": "rsa-1"}, rsaKey1, true},
+		{"RS256 with the other kid", map[string]string{"alg": AlgRS256, "kid": "rsa-2"}, rsaKey2, true},
+		{"RS256 without a kid", map[string]string{"alg": AlgRS256}, rsaKey2, true},
+		{"RS256 with another key's kid", map[string]string{"alg": AlgRS256, "kid": "rsa-1"}, rsaKey2, false},
+		{"RS256 with an unknown kid", map[string]string{"alg": AlgRS256, "kid": "rsa-3"}, rsaKey1, false},
+		{"ES256 with its kid", map[string]string{"alg": AlgES256, "kid": "ec-1"}, ecKey, true},
+		{"ES256 claimed for an RSA key", map[string]string{"alg": AlgES256, "kid": "rsa-1"}, ecKey, false},
+		{"RS256 claimed for an EC key", map[string]string{"alg": AlgRS256, "kid": "ec-1"}, rsaKey1, false},
+		{"HS256 with the RSA public key", map[string]string{"alg": AlgHS256, "kid": "rsa-1"}, rsaPublic, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := v.Verify(sign(t, tt.header, validClaims(), tt.key))
+			if tt.wantOK && err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			if !tt.wantOK && !errors.Is(err, ErrInvalidToken) {
+				t.Fatalf("Verify() error = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+}
+
+func TestNewVerifierRejectsUnusableJWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	tests := []struct {
+		name string
+		key  map[string]string
+	}{
+		{"small RSA key", rsaJWK("small", &rsaKey.PublicKey)},
+		{"point off the curve", map[string]string{
+			"kty": "EC", "crv": "P-256",
+			"x": base64.RawURLEncoding.EncodeToString(make([]byte, 32)),
+			"y": base64.RawURLEncoding.EncodeToString(make([]byte, 32)),
+		}},
+		{"unsupported curve", map[string]string{"kty": "EC", "crv": "P-384"}},
+		{"algorithm not matching the key type", map[string]string{"kty": "oct", "alg": AlgRS256, "k": "c2VjcmV0"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewVerifier(config.AuthConfig{JWKSFile: writeJWKS(t, tt.key)}); err == nil {
+				t.Error("NewVerifier() error = nil, want no usable keys")
+			}
+		})
+	}
+}
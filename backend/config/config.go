@@ -4,13 +4,20 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // Config holds all application configuration
 type Config struct {
-	Server    ServerConfig
-	Database  DatabaseConfig
-	WebSocket WebSocketConfig
+	Server      ServerConfig
+	Database    DatabaseConfig
+	WebSocket   WebSocketConfig
+	Persistence PersistenceConfig
+	Trash       TrashConfig
+	Auth        AuthConfig
+	CORS        CORSConfig
+	RateLimit   RateLimitConfig
 }
 
 // ServerConfig holds server-related configuration
@@ -19,47 +26,202 @@ type ServerConfig struct {
 	Host string
 }
 
-// DatabaseConfig holds database-related configuration
+// DatabaseConfig holds database-related configuration. Driver selects the
+// storage backend: "postgres" uses the connection fields, "sqlite" the file
+// at Path and "memory" keeps everything in process.
 type DatabaseConfig struct {
-	User     string
-	Password string
-	Name     string
-	Host     string
-	Port     string
-	SSLMode  string
+	Driver      string
+	Path        string
+	AutoMigrate bool
+	User        string
+	Password    string
+	Name        string
+	Host        string
+	Port        string
+	SSLMode     string
+
+	// QueryTimeout bounds every database call that has no earlier deadline
+	QueryTimeout time.Duration
+
+	// Connection pool, PostgreSQL only
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
 }
 
 // WebSocketConfig holds WebSocket-related configuration
 type WebSocketConfig struct {
-	ReadBufferSize  int
-	WriteBufferSize int
-	CheckOrigin     bool
+	ReadBufferSize   int
+	WriteBufferSize  int
+	CheckOrigin      bool
+	YjsTextName      string
+	HistorySize      int
+	CursorThrottleMs int
+	SendQueueSize    int
+	PingInterval     time.Duration
+	PongTimeout      time.Duration
+	IdleTimeout      time.Duration
+	MaxMessageSize   int
+	MaxDocumentSize  int
+	MessageRate      int
+	MessageBurst     int
+}
+
+// PersistenceConfig controls how live room edits are written to the database
+type PersistenceConfig struct {
+	Interval   time.Duration
+	MaxPending int
+	MaxBackoff time.Duration
+	// SnapshotInterval is how often a version of an edited room is kept
+	SnapshotInterval time.Duration
+}
+
+// TrashConfig controls how long deleted rooms stay restorable. A zero
+// Retention keeps them forever.
+type TrashConfig struct {
+	Retention     time.Duration
+	PurgeInterval time.Duration
+}
+
+// AuthConfig controls bearer-token authentication. Tokens are checked
+// against JWTSecret (HS256) and the keys in JWKSFile (RS256, ES256 or HS256).
+// Authentication is only off, with clients trusted to name themselves, when
+// Disabled is set instead.
+type AuthConfig struct {
+	Disabled  bool
+	JWTSecret string
+	JWKSFile  string
+	Issuer    string
+	Audience  string
+	// UIDClaim names the claim that holds the user's uid
+	UIDClaim  string
+	ClockSkew time.Duration
+}
+
+// CORSConfig lists the origins browsers may call the API and open WebSockets
+// from. An entry is "*", an exact origin such as "https://app.example.com" or
+// a wildcard subdomain origin such as "https://*.example.com".
+type CORSConfig struct {
+	AllowedOrigins []string
+	// AllowCredentials lets browsers send cookies and Authorization headers
+	AllowCredentials bool
+}
+
+// RateLimitConfig throttles clients. Request limits apply to the API and to
+// WebSocket handshakes; zero disables a limit.
+type RateLimitConfig struct {
+	IPRequestsPerMinute   int
+	UserRequestsPerMinute int
+	// Concurrent WebSocket connections, across /ws and /yjs
+	MaxConnsPerIP   int
+	MaxConnsPerRoom int
+}
+
+// Enabled reports whether requests must carry a valid token
+func (a AuthConfig) Enabled() bool {
+	return a.JWTSecret != "" || a.JWKSFile != ""
 }
 
 // Load loads configuration from environment variables
 func Load() (*Config, error) {
+	config, err := LoadDatabase()
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate required fields
+	if ws := config.WebSocket; ws.PingInterval > 0 && ws.PongTimeout > 0 && ws.PongTimeout <= ws.PingInterval {
+		return nil, fmt.Errorf("WS_PONG_TIMEOUT must be longer than WS_PING_INTERVAL")
+	}
+	// Fail closed: a forgotten secret must not silently open the API
+	if !config.Auth.Enabled() && !config.Auth.Disabled {
+		return nil, fmt.Errorf("AUTH_JWT_SECRET or AUTH_JWKS_FILE is required, or set AUTH_DISABLED=true to run without authentication")
+	}
+	if config.Auth.Enabled() && config.Auth.Disabled {
+		return nil, fmt.Errorf("AUTH_DISABLED cannot be combined with AUTH_JWT_SECRET or AUTH_JWKS_FILE")
+	}
+	if secret := config.Auth.JWTSecret; secret != "" && len(secret) < 32 {
+		return nil, fmt.Errorf("AUTH_JWT_SECRET must be at least 32 bytes")
+	}
+	if err := config.CORS.validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// LoadDatabase loads configuration like Load but only validates the database
+// settings, for commands such as migrate that do not serve requests
+func LoadDatabase() (*Config, error) {
 	config := &Config{
 		Server: ServerConfig{
 			Port: getEnv("PORT", "5000"),
 			Host: getEnv("HOST", "localhost"),
 		},
 		Database: DatabaseConfig{
-			User:     getEnv("DB_USER", ""),
-			Password: getEnv("DB_PASSWORD", ""),
-			Name:     getEnv("DB_NAME", "peeriodic"),
-			Host:     getEnv("DB_HOST", "localhost"),
-			Port:     getEnv("DB_PORT", "5432"),
-			SSLMode:  getEnv("DB_SSLMODE", "disable"),
+			Driver:      getEnv("DB_DRIVER", "postgres"),
+			Path:        getEnv("DB_PATH", "peeriodic.db"),
+			AutoMigrate: getEnvAsBool("DB_AUTO_MIGRATE", true),
+			User:        getEnv("DB_USER", ""),
+			Password:    getEnv("DB_PASSWORD", ""),
+			Name:        getEnv("DB_NAME", "peeriodic"),
+			Host:        getEnv("DB_HOST", "localhost"),
+			Port:        getEnv("DB_PORT", "5432"),
+			SSLMode:     getEnv("DB_SSLMODE", "disable"),
+
+			QueryTimeout:    getEnvAsDuration("DB_QUERY_TIMEOUT", 5*time.Second),
+			MaxOpenConns:    getEnvAsInt("DB_MAX_OPEN_CONNS", 25),
+			MaxIdleConns:    getEnvAsInt("DB_MAX_IDLE_CONNS", 5),
+			ConnMaxLifetime: getEnvAsDuration("DB_CONN_MAX_LIFETIME", 5*time.Minute),
 		},
 		WebSocket: WebSocketConfig{
-			ReadBufferSize:  getEnvAsInt("WS_READ_BUFFER_SIZE", 1024),
-			WriteBufferSize: getEnvAsInt("WS_WRITE_BUFFER_SIZE", 1024),
-			CheckOrigin:     getEnvAsBool("WS_CHECK_ORIGIN", false),
+			ReadBufferSize:   getEnvAsInt("WS_READ_BUFFER_SIZE", 1024),
+			WriteBufferSize:  getEnvAsInt("WS_WRITE_BUFFER_SIZE", 1024),
+			CheckOrigin:      getEnvAsBool("WS_CHECK_ORIGIN", true),
+			YjsTextName:      getEnv("WS_YJS_TEXT_NAME", "content"),
+			HistorySize:      getEnvAsInt("WS_HISTORY_SIZE", 1000),
+			CursorThrottleMs: getEnvAsInt("WS_CURSOR_THROTTLE_MS", 50),
+			SendQueueSize:    getEnvAsInt("WS_SEND_QUEUE_SIZE", 256),
+			PingInterval:     getEnvAsDuration("WS_PING_INTERVAL", 25*time.Second),
+			PongTimeout:      getEnvAsDuration("WS_PONG_TIMEOUT", 60*time.Second),
+			IdleTimeout:      getEnvAsDuration("WS_IDLE_TIMEOUT", 0),
+			MaxMessageSize:   getEnvAsInt("WS_MAX_MESSAGE_SIZE", 2<<20),
+			MaxDocumentSize:  getEnvAsInt("WS_MAX_DOCUMENT_SIZE", 1<<20),
+			MessageRate:      getEnvAsInt("WS_MESSAGE_RATE", 50),
+			MessageBurst:     getEnvAsInt("WS_MESSAGE_BURST", 100),
+		},
+		Persistence: PersistenceConfig{
+			Interval:         getEnvAsDuration("PERSIST_INTERVAL", 2*time.Second),
+			MaxPending:       getEnvAsInt("PERSIST_MAX_PENDING", 100),
+			MaxBackoff:       getEnvAsDuration("PERSIST_MAX_BACKOFF", 30*time.Second),
+			SnapshotInterval: getEnvAsDuration("PERSIST_SNAPSHOT_INTERVAL", 5*time.Minute),
+		},
+		Trash: TrashConfig{
+			Retention:     getEnvAsDuration("TRASH_RETENTION", 30*24*time.Hour),
+			PurgeInterval: getEnvAsDuration("TRASH_PURGE_INTERVAL", time.Hour),
+		},
+		Auth: AuthConfig{
+			Disabled:  getEnvAsBool("AUTH_DISABLED", false),
+			JWTSecret: getEnv("AUTH_JWT_SECRET", ""),
+			JWKSFile:  getEnv("AUTH_JWKS_FILE", ""),
+			Issuer:    getEnv("AUTH_ISSUER", ""),
+			Audience:  getEnv("AUTH_AUDIENCE", ""),
+			UIDClaim:  getEnv("AUTH_UID_CLAIM", "sub"),
+			ClockSkew: getEnvAsDuration("AUTH_CLOCK_SKEW", time.Minute),
+		},
+		CORS: CORSConfig{
+			AllowedOrigins:   getEnvAsList("CORS_ALLOWED_ORIGINS", []string{"*"}),
+			AllowCredentials: getEnvAsBool("CORS_ALLOW_CREDENTIALS", false),
+		},
+		RateLimit: RateLimitConfig{
+			IPRequestsPerMinute:   getEnvAsInt("RATE_LIMIT_IP_RPM", 600),
+			UserRequestsPerMinute: getEnvAsInt("RATE_LIMIT_USER_RPM", 300),
+			MaxConnsPerIP:         getEnvAsInt("WS_MAX_CONNS_PER_IP", 50),
+			MaxConnsPerRoom:       getEnvAsInt("WS_MAX_CONNS_PER_ROOM", 200),
 		},
 	}
 
-	// Validate required fields
-	if config.Database.User == "" {
+	if config.Database.Driver == "postgres" && config.Database.User == "" {
 		return nil, fmt.Errorf("DB_USER environment variable is required")
 	}
 
@@ -107,3 +269,24 @@ func getEnvAsBool(key string, defaultValue bool) bool {
 	return defaultValue
 }
 
+func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if durationValue, err := time.ParseDuration(value); err == nil {
+			return durationValue
+		}
+	}
+	return defaultValue
+}
+
+func getEnvAsList(key string, defaultValue []string) []string {
+	if value := os.Getenv(key); value != "" {
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		return list
+	}
+	return defaultValue
+}
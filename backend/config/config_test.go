@@ -0,0 +1,27 @@
+package config
+
+import "testing"
+
+func TestLoadDatabaseIgnoresServerSettings(t *testing.T) {
+	t.Setenv("DB_DRIVER", "sqlite")
+	t.Setenv("AUTH_DISABLED", "")
+	t.Setenv("AUTH_JWT_SECRET", "")
+	t.Setenv("AUTH_JWKS_FILE", "")
+
+	if _, err := Load(); err == nil {
+		t.Fatal("Load() without auth settings error = nil, want an error")
+	}
+	cfg, err := LoadDatabase()
+	if err != nil {
+		t.Fatalf("LoadDatabase() error = %v", err)
+	}
+	if cfg.Database.Driver != "sqlite" {
+		t.Errorf("Driver = %q, want %q", cfg.Database.Driver, "sqlite")
+	}
+
+	t.Setenv("DB_DRIVER", "postgres")
+	t.Setenv("DB_USER", "")
+	if _, err := LoadDatabase(); err == nil {
+		t.Error("LoadDatabase() for postgres without DB_USER error = nil, want an error")
+	}
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// AllowsAnyOrigin reports whether every origin is allowed
+func (c CORSConfig) AllowsAnyOrigin() bool {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsOrigin reports whether a request's Origin header names an allowed
+// origin
+func (c CORSConfig) AllowsOrigin(origin string) bool {
+	o, err := parseOrigin(origin)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" {
+			return true
+		}
+		pattern, err := parseOrigin(allowed)
+		if err != nil {
+			continue
+		}
+		if originMatches(pattern, o) {
+			return true
+		}
+	}
+	return false
+}
+
+// validate checks every allowed origin is well formed
+func (c CORSConfig) validate() error {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" {
+			continue
+		}
+		u, err := parseOrigin(allowed)
+		if err != nil {
+			return fmt.Errorf("CORS_ALLOWED_ORIGINS: %w", err)
+		}
+		if host := strings.TrimPrefix(u.Hostname(), "*."); host == "" || strings.Contains(host, "*") {
+			return fmt.Errorf("CORS_ALLOWED_ORIGINS: %q may only use a wildcard as its first label", allowed)
+		}
+	}
+	if c.AllowCredentials && c.AllowsAnyOrigin() {
+		return fmt.Errorf("CORS_ALLOW_CREDENTIALS cannot be combined with a \"*\" origin")
+	}
+	return nil
+}
+
+// parseOrigin parses a scheme://host[:port] origin
+func parseOrigin(origin string) (*url.URL, error) {
+	u, err := url.Parse(strings.ToLower(strings.TrimSuffix(origin, "/")))
+	if err != nil {
+		return nil, fmt.Errorf("invalid origin %q: %w", origin, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" || u.RawQuery != "" || u.User != nil {
+		return nil, fmt.Errorf("invalid origin %q", origin)
+	}
+	return u, nil
+}
+
+// originMatches reports whether an origin matches an allowed one. A "*."
+// host matches any subdomain of the rest, but not the bare domain.
+func originMatches(pattern, origin *url.URL) bool {
+	if pattern.Scheme != origin.Scheme || pattern.Port() != origin.Port() {
+		return false
+	}
+	host := pattern.Hostname()
+	if suffix, ok := strings.CutPrefix(host, "*"); ok {
+		return strings.HasSuffix(origin.Hostname(), suffix) && len(origin.Hostname()) > len(suffix)
+	}
+	return host == origin.Hostname()
+}
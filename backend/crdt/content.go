@@ -0,0 +1,269 @@
+package crdt
+
+import (
+	"fmt"
+	"unicode/utf16"
+)
+
+// Content type references as they appear in the low five bits of a struct's info byte
+const (
+	refGC      = 0
+	refDeleted = 1
+	refJSON    = 2
+	refBinary  = 3
+	refString  = 4
+	refEmbed   = 5
+	refFormat  = 6
+	refType    = 7
+	refAny     = 8
+	refDoc     = 9
+	refSkip    = 10
+)
+
+// Shared type references used by ContentType
+const (
+	typeRefXMLElement = 3
+	typeRefXMLHook    = 5
+)
+
+// content is the payload carried by an item
+type content interface {
+	ref() byte
+	length() uint64
+	countable() bool
+	// splice cuts the content at offset, keeps the left part and returns the right part
+	splice(offset uint64) content
+	write(e *encoder, offset uint64)
+}
+
+// contentDeleted marks a run of deleted content whose payload was discarded
+type contentDeleted struct {
+	len uint64
+}
+
+func (c *contentDeleted) ref() byte       { return refDeleted }
+func (c *contentDeleted) length() uint64  { return c.len }
+func (c *contentDeleted) countable() bool { return false }
+
+func (c *contentDeleted) splice(offset uint64) content {
+	right := &contentDeleted{len: c.len - offset}
+	c.len = offset
+	return right
+}
+
+func (c *contentDeleted) write(e *encoder, offset uint64) {
+	e.writeVarUint(c.len - offset)
+}
+
+// contentJSON holds JSON encoded array entries (legacy Y.Array content)
+type contentJSON struct {
+	values []string
+}
+
+func (c *contentJSON) ref() byte       { return refJSON }
+func (c *contentJSON) length() uint64  { return uint64(len(c.values)) }
+func (c *contentJSON) countable() bool { return true }
+
+func (c *contentJSON) splice(offset uint64) content {
+	right := &contentJSON{values: c.values[offset:]}
+	c.values = c.values[:offset:offset]
+	return right
+}
+
+func (c *contentJSON) write(e *encoder, offset uint64) {
+	e.writeVarUint(uint64(len(c.values)) - offset)
+	for _, v := range c.values[offset:] {
+		e.writeVarString(v)
+	}
+}
+
+// contentAny holds raw lib0 encoded array entries
+type contentAny struct {
+	values [][]byte
+}
+
+func (c *contentAny) ref() byte       { return refAny }
+func (c *contentAny) length() uint64  { return uint64(len(c.values)) }
+func (c *contentAny) countable() bool { return true }
+
+func (c *contentAny) splice(offset uint64) content {
+	right := &contentAny{values: c.values[offset:]}
+	c.values = c.values[:offset:offset]
+	return right
+}
+
+func (c *contentAny) write(e *encoder, offset uint64) {
+	e.writeVarUint(uint64(len(c.values)) - offset)
+	for _, v := range c.values[offset:] {
+		e.writeRaw(v)
+	}
+}
+
+// contentString holds text, stored as UTF-16 code units because Yjs measures
+// text lengths in UTF-16
+type contentString struct {
+	units []uint16
+}
+
+func newContentString(s string) *contentString {
+	return &contentString{units: utf16.Encode([]rune(s))}
+}
+
+func (c *contentString) ref() byte       { return refString }
+func (c *contentString) length() uint64  { return uint64(len(c.units)) }
+func (c *contentString) countable() bool { return true }
+
+func (c *contentString) splice(offset uint64) content {
+	right := &contentString{units: append([]uint16(nil), c.units[offset:]...)}
+	c.units = c.units[:offset:offset]
+
+	// Splitting a surrogate pair leaves two invalid halves; Yjs replaces both
+	// with the replacement character and so must we to stay convergent
+	if offset > 0 && isHighSurrogate(c.units[offset-1]) {
+		c.units[offset-1] = 0xfffd
+		right.units[0] = 0xfffd
+	}
+	return right
+}
+
+func (c *contentString) write(e *encoder, offset uint64) {
+	e.writeVarString(string(utf16.Decode(c.units[offset:])))
+}
+
+func (c *contentString) String() string {
+	return string(utf16.Decode(c.units))
+}
+
+// isHighSurrogate reports whether u is the first half of a surrogate pair
+func isHighSurrogate(u uint16) bool {
+	return u >= 0xd800 && u < 0xdc00
+}
+
+// contentOpaque holds single-length content the server only stores and
+// forwards: binary blobs, embeds, formatting marks and subdocuments
+type contentOpaque struct {
+	kind byte
+	raw  []byte
+}
+
+func (c *contentOpaque) ref() byte      { return c.kind }
+func (c *contentOpaque) length() uint64 { return 1 }
+
+func (c *contentOpaque) countable() bool {
+	return c.kind != refFormat
+}
+
+func (c *contentOpaque) splice(offset uint64) content {
+	panic("crdt: opaque content cannot be split")
+}
+
+func (c *contentOpaque) write(e *encoder, offset uint64) {
+	e.writeRaw(c.raw)
+}
+
+// contentType holds a nested shared type
+type contentType struct {
+	typeRef uint64
+	key     string
+	t       *sharedType
+}
+
+func (c *contentType) ref() byte       { return refType }
+func (c *contentType) length() uint64  { return 1 }
+func (c *contentType) countable() bool { return true }
+
+func (c *contentType) splice(offset uint64) content {
+	panic("crdt: type content cannot be split")
+}
+
+func (c *contentType) write(e *encoder, offset uint64) {
+	e.writeVarUint(c.typeRef)
+	if c.typeRef == typeRefXMLElement || c.typeRef == typeRefXMLHook {
+		e.writeVarString(c.key)
+	}
+}
+
+// readContent reads the content of an item with the given info byte
+func readContent(d *decoder, info byte) (content, error) {
+	switch ref := info & 0x1f; ref {
+	case refDeleted:
+		n, err := d.readVarUint()
+		if err != nil {
+			return nil, err
+		}
+		return &contentDeleted{len: n}, nil
+
+	case refJSON:
+		n, err := d.readLength()
+		if err != nil {
+			return nil, err
+		}
+		values := make([]string, 0, n)
+		for i := 0; i < n; i++ {
+			v, err := d.readVarString()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return &contentJSON{values: values}, nil
+
+	case refAny:
+		n, err := d.readLength()
+		if err != nil {
+			return nil, err
+		}
+		values := make([][]byte, 0, n)
+		for i := 0; i < n; i++ {
+			v, err := d.readAny()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return &contentAny{values: values}, nil
+
+	case refString:
+		s, err := d.readVarString()
+		if err != nil {
+			return nil, err
+		}
+		return newContentString(s), nil
+
+	case refType:
+		typeRef, err := d.readVarUint()
+		if err != nil {
+			return nil, err
+		}
+		c := &contentType{typeRef: typeRef}
+		if typeRef == typeRefXMLElement || typeRef == typeRefXMLHook {
+			if c.key, err = d.readVarString(); err != nil {
+				return nil, err
+			}
+		}
+		return c, nil
+
+	case refBinary, refEmbed, refFormat, refDoc:
+		start := d.pos
+		var err error
+		switch ref {
+		case refBinary, refEmbed:
+			_, err = d.readVarBytes()
+		case refFormat:
+			if _, err = d.readVarBytes(); err == nil {
+				_, err = d.readVarBytes()
+			}
+		case refDoc:
+			if _, err = d.readVarBytes(); err == nil {
+				err = d.skipAny()
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &contentOpaque{kind: ref, raw: d.buf[start:d.pos]}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown content type: %d", ref)
+	}
+}
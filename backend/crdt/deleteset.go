@@ -0,0 +1,93 @@
+package crdt
+
+import (
+	"fmt"
+	"sort"
+)
+
+// deleteRange is a run of deleted clock ticks of one client
+type deleteRange struct {
+	clock uint64
+	len   uint64
+}
+
+// deleteSet maps clients to their deleted clock ranges
+type deleteSet map[uint64][]deleteRange
+
+// add records a deleted range, extending the previous range when they touch
+func (ds *deleteSet) add(client, clock, length uint64) {
+	if length == 0 {
+		return
+	}
+	if *ds == nil {
+		*ds = make(deleteSet)
+	}
+	ranges := (*ds)[client]
+	if n := len(ranges); n > 0 && ranges[n-1].clock+ranges[n-1].len == clock {
+		ranges[n-1].len += length
+		return
+	}
+	(*ds)[client] = append(ranges, deleteRange{clock: clock, len: length})
+}
+
+// merge adds all ranges of other to ds
+func (ds *deleteSet) merge(other deleteSet) {
+	for client, ranges := range other {
+		for _, r := range ranges {
+			ds.add(client, r.clock, r.len)
+		}
+	}
+}
+
+// write encodes the delete set in the Yjs v1 format
+func (ds deleteSet) write(e *encoder) {
+	clients := make([]uint64, 0, len(ds))
+	for client := range ds {
+		clients = append(clients, client)
+	}
+	sort.Slice(clients, func(i, j int) bool { return clients[i] > clients[j] })
+
+	e.writeVarUint(uint64(len(clients)))
+	for _, client := range clients {
+		e.writeVarUint(client)
+		e.writeVarUint(uint64(len(ds[client])))
+		for _, r := range ds[client] {
+			e.writeVarUint(r.clock)
+			e.writeVarUint(r.len)
+		}
+	}
+}
+
+// readDeleteSet decodes a delete set in the Yjs v1 format
+func readDeleteSet(d *decoder) (deleteSet, error) {
+	var ds deleteSet
+	numClients, err := d.readVarUint()
+	if err != nil {
+		return nil, err
+	}
+	for i := uint64(0); i < numClients; i++ {
+		client, err := d.readVarUint()
+		if err != nil {
+			return nil, err
+		}
+		numRanges, err := d.readVarUint()
+		if err != nil {
+			return nil, err
+		}
+		for j := uint64(0); j < numRanges; j++ {
+			clock, err := d.readVarUint()
+			if err != nil {
+				return nil, err
+			}
+			length, err := d.readVarUint()
+			if err != nil {
+				return nil, err
+			}
+			if clock > maxClock || length > maxClock-clock {
+				return nil, fmt.Errorf("deleted range %d:%d is past the largest clock", client, clock)
+			}
+			ds.add(client, clock, length)
+		}
+	}
+	return ds, nil
+}
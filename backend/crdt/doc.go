@@ -0,0 +1,855 @@
+package crdt
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// maxClock bounds the clocks accepted from updates. Yjs clocks are JavaScript
+// numbers, so no genuine update goes past 2^53, and the bound keeps clock
+// arithmetic from overflowing.
+const maxClock = 1 << 53
+
+// ID identifies a struct by the client that created it and its logical clock
+type ID struct {
+	Client uint64
+	Clock  uint64
+}
+
+// item is a struct in the document store. GC structs are placeholders for
+// garbage collected content and only occupy a clock range.
+type item struct {
+	id     ID
+	length uint64
+	gc     bool
+
+	origin      *ID
+	rightOrigin *ID
+	left        *item
+	right       *item
+	parent      *sharedType
+	parentSub   *string
+	content     content
+	deleted     bool
+
+	// Set while decoding, before the parent is resolved
+	parentName *string
+	parentID   *ID
+}
+
+// lastID returns the ID of the last clock tick covered by the item
+func (it *item) lastID() ID {
+	return ID{Client: it.id.Client, Clock: it.id.Clock + it.length - 1}
+}
+
+// sharedType is a Yjs shared type: a sequence of items plus keyed entries
+type sharedType struct {
+	name    string
+	item    *item
+	start   *item
+	entries map[string]*item
+}
+
+func newSharedType() *sharedType {
+	return &sharedType{entries: make(map[string]*item)}
+}
+
+// Doc is a Yjs compatible document. It integrates updates produced by Yjs
+// clients and can encode its state back into Yjs updates. Doc is not safe for
+// concurrent use.
+type Doc struct {
+	clientID       uint64
+	clients        map[uint64][]*item
+	roots          map[string]*sharedType
+	pending        map[uint64][]*item
+	pendingDeletes deleteSet
+	localDeletes   deleteSet
+}
+
+// NewDoc creates an empty document with a random client ID for local edits
+func NewDoc() *Doc {
+	return &Doc{
+		clientID: uint64(rand.Uint32()),
+		clients:  make(map[uint64][]*item),
+		roots:    make(map[string]*sharedType),
+		pending:  make(map[uint64][]*item),
+	}
+}
+
+// LoadDoc creates a document holding the state encoded in update, as returned
+// by EncodeStateAsUpdate. Its local edits use a client ID the state does not
+// contain yet.
+func LoadDoc(update []byte) (*Doc, error) {
+	doc := NewDoc()
+	if err := doc.ApplyUpdate(update); err != nil {
+		return nil, err
+	}
+	for doc.clients[doc.clientID] != nil || doc.pending[doc.clientID] != nil {
+		doc.clientID = uint64(rand.Uint32())
+	}
+	return doc, nil
+}
+
+// root returns the root type with the given name, creating it if necessary
+func (doc *Doc) root(name string) *sharedType {
+	t, exists := doc.roots[name]
+	if !exists {
+		t = newSharedType()
+		t.name = name
+		doc.roots[name] = t
+	}
+	return t
+}
+
+// Text returns the text of the root Y.Text with the given name
+func (doc *Doc) Text(name string) string {
+	t, exists := doc.roots[name]
+	if !exists {
+		return ""
+	}
+	var sb strings.Builder
+	for it := t.start; it != nil; it = it.right {
+		if s, ok := it.content.(*contentString); ok && !it.deleted {
+			sb.WriteString(s.String())
+		}
+	}
+	return sb.String()
+}
+
+// getState returns the next expected clock for a client
+func (doc *Doc) getState(client uint64) uint64 {
+	structs := doc.clients[client]
+	if len(structs) == 0 {
+		return 0
+	}
+	last := structs[len(structs)-1]
+	return last.id.Clock + last.length
+}
+
+// findIndex returns the index of the struct containing clock
+func findIndex(structs []*item, clock uint64) int {
+	return sort.Search(len(structs), func(i int) bool {
+		return structs[i].id.Clock+structs[i].length > clock
+	})
+}
+
+// getItem returns the struct containing id
+func (doc *Doc) getItem(id ID) *item {
+	structs := doc.clients[id.Client]
+	i := findIndex(structs, id.Clock)
+	if i >= len(structs) || structs[i].id.Clock > id.Clock {
+		return nil
+	}
+	return structs[i]
+}
+
+// getItemCleanStart returns the struct starting exactly at id, splitting if
+// needed, or nil when the document does not contain id
+func (doc *Doc) getItemCleanStart(id ID) *item {
+	structs := doc.clients[id.Client]
+	i := findIndex(structs, id.Clock)
+	if i >= len(structs) || structs[i].id.Clock > id.Clock {
+		return nil
+	}
+	it := structs[i]
+	if it.id.Clock < id.Clock && !it.gc {
+		right := doc.splitItem(it, id.Clock-it.id.Clock)
+		doc.insertStruct(i+1, right)
+		return right
+	}
+	return it
+}
+
+// getItemCleanEnd returns the struct ending exactly at id, splitting if
+// needed, or nil when the document does not contain id
+func (doc *Doc) getItemCleanEnd(id ID) *item {
+	structs := doc.clients[id.Client]
+	i := findIndex(structs, id.Clock)
+	if i >= len(structs) || structs[i].id.Clock > id.Clock {
+		return nil
+	}
+	it := structs[i]
+	if id.Clock != it.id.Clock+it.length-1 && !it.gc {
+		doc.insertStruct(i+1, doc.splitItem(it, id.Clock-it.id.Clock+1))
+	}
+	return it
+}
+
+// insertStruct inserts a struct into its client's list at index i
+func (doc *Doc) insertStruct(i int, it *item) {
+	structs := doc.clients[it.id.Client]
+	structs = append(structs, nil)
+	copy(structs[i+1:], structs[i:])
+	structs[i] = it
+	doc.clients[it.id.Client] = structs
+}
+
+// splitItem splits it at diff and returns the new right half
+func (doc *Doc) splitItem(it *item, diff uint64) *item {
+	origin := ID{Client: it.id.Client, Clock: it.id.Clock + diff - 1}
+	right := &item{
+		id:          ID{Client: it.id.Client, Clock: it.id.Clock + diff},
+		length:      it.length - diff,
+		origin:      &origin,
+		rightOrigin: it.rightOrigin,
+		left:        it,
+		right:       it.right,
+		parent:      it.parent,
+		parentSub:   it.parentSub,
+		content:     it.content.splice(diff),
+		deleted:     it.deleted,
+	}
+	it.right = right
+	if right.right != nil {
+		right.right.left = right
+	}
+	if right.parentSub != nil && right.right == nil {
+		right.parent.entries[*right.parentSub] = right
+	}
+	it.length = diff
+	return right
+}
+
+// missingDependency reports whether it references a struct the document has
+// not seen yet. Unlike Yjs this includes the item's own client, whose earlier
+// structs a well-formed update always precedes it with but a hostile one may not.
+func (doc *Doc) missingDependency(it *item) bool {
+	for _, dep := range []*ID{it.origin, it.rightOrigin, it.parentID} {
+		if dep != nil && dep.Clock >= doc.getState(dep.Client) {
+			return true
+		}
+	}
+	return false
+}
+
+// integrate inserts a decoded struct into the document, skipping the first
+// offset clock ticks that the document already knows. It fails when the
+// struct refers to one the document does not contain, which missingDependency
+// should have caught.
+func (doc *Doc) integrate(it *item, offset uint64) error {
+	if it.gc {
+		if offset > 0 {
+			it.id.Clock += offset
+			it.length -= offset
+		}
+		doc.clients[it.id.Client] = append(doc.clients[it.id.Client], it)
+		return nil
+	}
+
+	if offset > 0 {
+		it.id.Clock += offset
+		it.length -= offset
+		it.content = it.content.splice(offset)
+		origin := ID{Client: it.id.Client, Clock: it.id.Clock - 1}
+		it.origin = &origin
+	}
+
+	// Resolve neighbours and parent, as Yjs does in Item.getMissing
+	if it.origin != nil {
+		if it.left = doc.getItemCleanEnd(*it.origin); it.left == nil {
+			return fmt.Errorf("struct %d:%d has unknown origin %d:%d", it.id.Client, it.id.Clock, it.origin.Client, it.origin.Clock)
+		}
+		last := it.left.lastID()
+		it.origin = &last
+	}
+	if it.rightOrigin != nil {
+		if it.right = doc.getItemCleanStart(*it.rightOrigin); it.right == nil {
+			return fmt.Errorf("struct %d:%d has unknown right origin %d:%d", it.id.Client, it.id.Clock, it.rightOrigin.Client, it.rightOrigin.Clock)
+		}
+		it.rightOrigin = &it.right.id
+	}
+	switch {
+	case (it.left != nil && it.left.gc) || (it.right != nil && it.right.gc):
+		it.parent = nil
+	case it.parentName != nil:
+		it.parent = doc.root(*it.parentName)
+	case it.parentID != nil:
+		if parentItem := doc.getItem(*it.parentID); parentItem != nil {
+			if c, ok := parentItem.content.(*contentType); ok {
+				it.parent = c.t
+			}
+		}
+	case it.left != nil:
+		it.parent = it.left.parent
+		it.parentSub = it.left.parentSub
+	case it.right != nil:
+		it.parent = it.right.parent
+		it.parentSub = it.right.parentSub
+	}
+	it.parentName = nil
+	it.parentID = nil
+
+	if it.parent == nil {
+		// The parent is gone, so the content can never be reached
+		doc.clients[it.id.Client] = append(doc.clients[it.id.Client], &item{id: it.id, length: it.length, gc: true})
+		return nil
+	}
+
+	doc.resolveConflicts(it)
+	doc.link(it)
+
+	if c, ok := it.content.(*contentType); ok {
+		c.t = newSharedType()
+		c.t.item = it
+	}
+	doc.clients[it.id.Client] = append(doc.clients[it.id.Client], it)
+
+	parentDeleted := it.parent.item != nil && it.parent.item.deleted
+	if parentDeleted || (it.parentSub != nil && it.right != nil) {
+		doc.deleteItem(it)
+	}
+	return nil
+}
+
+// resolveConflicts finds the left neighbour of it among concurrently inserted
+// items, following the YATA rules implemented by Yjs
+func (doc *Doc) resolveConflicts(it *item) {
+	if !((it.left == nil && (it.right == nil || it.right.left != nil)) || (it.left != nil && it.left.right != it.right)) {
+		return
+	}
+
+	left := it.left
+	var o *item
+	switch {
+	case left != nil:
+		o = left.right
+	case it.parentSub != nil:
+		o = it.parent.entries[*it.parentSub]
+		for o != nil && o.left != nil {
+			o = o.left
+		}
+	default:
+		o = it.parent.start
+	}
+
+	conflicting := make(map[*item]bool)
+	beforeOrigin := make(map[*item]bool)
+	for o != nil && o != it.right {
+		beforeOrigin[o] = true
+		conflicting[o] = true
+		if sameID(it.origin, o.origin) {
+			if o.id.Client < it.id.Client {
+				left = o
+				clear(conflicting)
+			} else if sameID(it.rightOrigin, o.rightOrigin) {
+				break
+			}
+		} else if o.origin != nil && beforeOrigin[doc.getItem(*o.origin)] {
+			if !conflicting[doc.getItem(*o.origin)] {
+				left = o
+				clear(conflicting)
+			}
+		} else {
+			break
+		}
+		o = o.right
+	}
+	it.left = left
+}
+
+// link connects it to its neighbours and its parent
+func (doc *Doc) link(it *item) {
+	if it.left != nil {
+		it.right = it.left.right
+		it.left.right = it
+	} else {
+		var r *item
+		if it.parentSub != nil {
+			r = it.parent.entries[*it.parentSub]
+			for r != nil && r.left != nil {
+				r = r.left
+			}
+		} else {
+			r = it.parent.start
+			it.parent.start = it
+		}
+		it.right = r
+	}
+
+	if it.right != nil {
+		it.right.left = it
+	} else if it.parentSub != nil {
+		it.parent.entries[*it.parentSub] = it
+		if it.left != nil {
+			doc.deleteItem(it.left)
+		}
+	}
+}
+
+// deleteItem marks an item and, for nested types, all of its children as deleted
+func (doc *Doc) deleteItem(it *item) {
+	if it.deleted || it.gc {
+		return
+	}
+	it.deleted = true
+	if c, ok := it.content.(*contentType); ok && c.t != nil {
+		for child := c.t.start; child != nil; child = child.right {
+			doc.deleteItem(child)
+		}
+		for _, child := range c.t.entries {
+			for ; child != nil; child = child.left {
+				doc.deleteItem(child)
+			}
+		}
+	}
+}
+
+// sameID compares two optional IDs
+func sameID(a, b *ID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// ApplyUpdate integrates a Yjs update (v1 encoding). Structs whose
+// dependencies are not known yet are kept and retried with later updates.
+func (doc *Doc) ApplyUpdate(update []byte) error {
+	d := newDecoder(append([]byte(nil), update...))
+	structs, err := readStructs(d)
+	if err != nil {
+		return fmt.Errorf("failed to read update structs: %w", err)
+	}
+	ds, err := readDeleteSet(d)
+	if err != nil {
+		return fmt.Errorf("failed to read update delete set: %w", err)
+	}
+
+	for client, pending := range doc.pending {
+		structs[client] = append(structs[client], pending...)
+		sort.SliceStable(structs[client], func(i, j int) bool {
+			return structs[client][i].id.Clock < structs[client][j].id.Clock
+		})
+	}
+	doc.pending = make(map[uint64][]*item)
+	if err := doc.integrateStructs(structs); err != nil {
+		return fmt.Errorf("failed to integrate update: %w", err)
+	}
+
+	doc.pendingDeletes.merge(ds)
+	doc.pendingDeletes = doc.applyDeleteSet(doc.pendingDeletes)
+	return nil
+}
+
+// integrateStructs integrates as many structs as possible and keeps the rest pending
+func (doc *Doc) integrateStructs(structs map[uint64][]*item) error {
+	for progress := true; progress; {
+		progress = false
+		for client, queue := range structs {
+			for len(queue) > 0 {
+				it := queue[0]
+				state := doc.getState(client)
+				if it.id.Clock+it.length <= state {
+					queue = queue[1:]
+					continue
+				}
+				if it.id.Clock > state || doc.missingDependency(it) {
+					break
+				}
+				if err := doc.integrate(it, state-it.id.Clock); err != nil {
+					return err
+				}
+				queue = queue[1:]
+				progress = true
+			}
+			structs[client] = queue
+		}
+	}
+
+	for client, queue := range structs {
+		if len(queue) > 0 {
+			doc.pending[client] = queue
+		}
+	}
+	return nil
+}
+
+// applyDeleteSet deletes every known item in ds and returns the ranges that
+// refer to structs the document has not seen yet
+func (doc *Doc) applyDeleteSet(ds deleteSet) deleteSet {
+	var unapplied deleteSet
+	for client, ranges := range ds {
+		state := doc.getState(client)
+		for _, r := range ranges {
+			start, end := r.clock, r.clock+r.len
+			if end > state {
+				unapplied.add(client, max(start, state), end-max(start, state))
+				end = state
+			}
+			if start >= end {
+				continue
+			}
+
+			structs := doc.clients[client]
+			i := findIndex(structs, start)
+			for ; i < len(structs); i++ {
+				it := structs[i]
+				if it.id.Clock >= end {
+					break
+				}
+				if it.deleted || it.gc {
+					continue
+				}
+				if it.id.Clock < start {
+					doc.insertStruct(i+1, doc.splitItem(it, start-it.id.Clock))
+					structs = doc.clients[client]
+					continue
+				}
+				if it.id.Clock+it.length > end {
+					doc.insertStruct(i+1, doc.splitItem(it, end-it.id.Clock))
+					structs = doc.clients[client]
+				}
+				doc.deleteItem(it)
+			}
+		}
+	}
+	return unapplied
+}
+
+// stateVector returns the next expected clock of every known client
+func (doc *Doc) stateVector() map[uint64]uint64 {
+	sv := make(map[uint64]uint64, len(doc.clients))
+	for client := range doc.clients {
+		sv[client] = doc.getState(client)
+	}
+	return sv
+}
+
+// EncodeStateVector returns the encoded state vector of the document
+func (doc *Doc) EncodeStateVector() []byte {
+	sv := doc.stateVector()
+	e := &encoder{}
+	e.writeVarUint(uint64(len(sv)))
+	for _, client := range sortedClients(sv) {
+		e.writeVarUint(client)
+		e.writeVarUint(sv[client])
+	}
+	return e.bytes()
+}
+
+// EncodeStateAsUpdate encodes everything the document knows beyond the given
+// encoded state vector. An empty state vector encodes the whole document.
+func (doc *Doc) EncodeStateAsUpdate(encodedStateVector []byte) ([]byte, error) {
+	sv := make(map[uint64]uint64)
+	if len(encodedStateVector) > 0 {
+		d := newDecoder(encodedStateVector)
+		n, err := d.readVarUint()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read state vector: %w", err)
+		}
+		for i := uint64(0); i < n; i++ {
+			client, err := d.readVarUint()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read state vector: %w", err)
+			}
+			clock, err := d.readVarUint()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read state vector: %w", err)
+			}
+			sv[client] = clock
+		}
+	}
+
+	e := &encoder{}
+	doc.writeStructs(e, sv)
+	doc.deleteSetFromStore().write(e)
+	return e.bytes(), nil
+}
+
+// writeStructs writes all structs beyond the given state vector
+func (doc *Doc) writeStructs(e *encoder, sv map[uint64]uint64) {
+	changed := make(map[uint64]uint64)
+	for client := range doc.clients {
+		if clock := sv[client]; doc.getState(client) > clock {
+			changed[client] = clock
+		}
+	}
+
+	e.writeVarUint(uint64(len(changed)))
+	for _, client := range sortedClients(changed) {
+		structs := doc.clients[client]
+		clock := max(changed[client], structs[0].id.Clock)
+		i := findIndex(structs, clock)
+
+		e.writeVarUint(uint64(len(structs) - i))
+		e.writeVarUint(client)
+		e.writeVarUint(clock)
+		doc.writeStruct(e, structs[i], clock-structs[i].id.Clock)
+		for _, it := range structs[i+1:] {
+			doc.writeStruct(e, it, 0)
+		}
+	}
+}
+
+// writeStruct writes a single struct, skipping its first offset clock ticks
+func (doc *Doc) writeStruct(e *encoder, it *item, offset uint64) {
+	if it.gc {
+		e.writeUint8(refGC)
+		e.writeVarUint(it.length - offset)
+		return
+	}
+
+	origin := it.origin
+	if offset > 0 {
+		origin = &ID{Client: it.id.Client, Clock: it.id.Clock + offset - 1}
+	}
+
+	info := it.content.ref() & 0x1f
+	if origin != nil {
+		info |= 0x80
+	}
+	if it.rightOrigin != nil {
+		info |= 0x40
+	}
+	if it.parentSub != nil {
+		info |= 0x20
+	}
+	e.writeUint8(info)
+
+	if origin != nil {
+		e.writeID(*origin)
+	}
+	if it.rightOrigin != nil {
+		e.writeID(*it.rightOrigin)
+	}
+	if origin == nil && it.rightOrigin == nil {
+		if it.parent.item == nil {
+			e.writeVarUint(1)
+			e.writeVarString(it.parent.name)
+		} else {
+			e.writeVarUint(0)
+			e.writeID(it.parent.item.id)
+		}
+		if it.parentSub != nil {
+			e.writeVarString(*it.parentSub)
+		}
+	}
+	it.content.write(e, offset)
+}
+
+// deleteSetFromStore collects the ranges of all deleted structs
+func (doc *Doc) deleteSetFromStore() deleteSet {
+	ds := make(deleteSet)
+	for client, structs := range doc.clients {
+		for _, it := range structs {
+			if it.deleted || it.gc {
+				ds.add(client, it.id.Clock, it.length)
+			}
+		}
+	}
+	return ds
+}
+
+// readStructs reads the struct section of an update
+func readStructs(d *decoder) (map[uint64][]*item, error) {
+	structs := make(map[uint64][]*item)
+	numClients, err := d.readVarUint()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := uint64(0); i < numClients; i++ {
+		numStructs, err := d.readVarUint()
+		if err != nil {
+			return nil, err
+		}
+		client, err := d.readVarUint()
+		if err != nil {
+			return nil, err
+		}
+		clock, err := d.readVarUint()
+		if err != nil {
+			return nil, err
+		}
+
+		for j := uint64(0); j < numStructs; j++ {
+			it, err := readStruct(d, ID{Client: client, Clock: clock})
+			if err != nil {
+				return nil, err
+			}
+			if clock > maxClock || it.length > maxClock-clock {
+				return nil, fmt.Errorf("struct %d:%d is past the largest clock", client, clock)
+			}
+			clock += it.length
+			if it.content == nil && !it.gc {
+				// Skip structs only mark a gap in the update
+				continue
+			}
+			structs[client] = append(structs[client], it)
+		}
+	}
+	return structs, nil
+}
+
+// readStruct reads a single struct starting at id
+func readStruct(d *decoder, id ID) (*item, error) {
+	info, err := d.readUint8()
+	if err != nil {
+		return nil, err
+	}
+
+	switch info & 0x1f {
+	case refGC, refSkip:
+		n, err := d.readVarUint()
+		if err != nil {
+			return nil, err
+		}
+		return &item{id: id, length: n, gc: info&0x1f == refGC}, nil
+	}
+
+	it := &item{id: id}
+	if info&0x80 != 0 {
+		origin, err := d.readID()
+		if err != nil {
+			return nil, err
+		}
+		it.origin = &origin
+	}
+	if info&0x40 != 0 {
+		rightOrigin, err := d.readID()
+		if err != nil {
+			return nil, err
+		}
+		it.rightOrigin = &rightOrigin
+	}
+	// A client can only build on what it created before
+	for _, dep := range []*ID{it.origin, it.rightOrigin} {
+		if dep != nil && dep.Client == id.Client && dep.Clock >= id.Clock {
+			return nil, fmt.Errorf("struct %d:%d refers to its own later clock %d", id.Client, id.Clock, dep.Clock)
+		}
+	}
+	if info&0xc0 == 0 {
+		parentInfo, err := d.readVarUint()
+		if err != nil {
+			return nil, err
+		}
+		if parentInfo == 1 {
+			name, err := d.readVarString()
+			if err != nil {
+				return nil, err
+			}
+			it.parentName = &name
+		} else {
+			parentID, err := d.readID()
+			if err != nil {
+				return nil, err
+			}
+			it.parentID = &parentID
+		}
+		if info&0x20 != 0 {
+			sub, err := d.readVarString()
+			if err != nil {
+				return nil, err
+			}
+			it.parentSub = &sub
+		}
+	}
+
+	if it.content, err = readContent(d, info); err != nil {
+		return nil, err
+	}
+	it.length = it.content.length()
+	if it.length == 0 {
+		return nil, fmt.Errorf("empty struct %d:%d", id.Client, id.Clock)
+	}
+	return it, nil
+}
+
+// sortedClients returns the client IDs of a state map in descending order, as Yjs writes them
+func sortedClients(m map[uint64]uint64) []uint64 {
+	clients := make([]uint64, 0, len(m))
+	for client := range m {
+		clients = append(clients, client)
+	}
+	sort.Slice(clients, func(i, j int) bool { return clients[i] > clients[j] })
+	return clients
+}
+
+// Transact runs fn, which may edit the document through InsertText and
+// DeleteText, and returns a Yjs update with the changes it made. It returns
+// nil when nothing changed.
+func (doc *Doc) Transact(fn func()) []byte {
+	sv := doc.stateVector()
+	sv[doc.clientID] = doc.getState(doc.clientID)
+	doc.localDeletes = make(deleteSet)
+	defer func() { doc.localDeletes = nil }()
+
+	fn()
+
+	if doc.getState(doc.clientID) == sv[doc.clientID] && len(doc.localDeletes) == 0 {
+		return nil
+	}
+	e := &encoder{}
+	doc.writeStructs(e, sv)
+	doc.localDeletes.write(e)
+	return e.bytes()
+}
+
+// InsertText inserts text into the root Y.Text name at a UTF-16 index. Indexes
+// past the end append to the text.
+func (doc *Doc) InsertText(name string, index uint64, text string) {
+	if text == "" {
+		return
+	}
+	t := doc.root(name)
+	left, right := doc.findPosition(t, index)
+
+	it := &item{
+		id:      ID{Client: doc.clientID, Clock: doc.getState(doc.clientID)},
+		left:    left,
+		right:   right,
+		parent:  t,
+		content: newContentString(text),
+	}
+	it.length = it.content.length()
+	if left != nil {
+		origin := left.lastID()
+		it.origin = &origin
+	}
+	if right != nil {
+		it.rightOrigin = &right.id
+	}
+
+	doc.link(it)
+	doc.clients[it.id.Client] = append(doc.clients[it.id.Client], it)
+}
+
+// DeleteText deletes length UTF-16 code units from the root Y.Text name
+// starting at index
+func (doc *Doc) DeleteText(name string, index, length uint64) {
+	t := doc.root(name)
+	_, it := doc.findPosition(t, index)
+
+	for ; it != nil && length > 0; it = it.right {
+		if it.deleted || !it.content.countable() {
+			continue
+		}
+		if length < it.length {
+			doc.getItemCleanStart(ID{Client: it.id.Client, Clock: it.id.Clock + length})
+		}
+		length -= it.length
+		doc.deleteItem(it)
+		if doc.localDeletes != nil {
+			doc.localDeletes.add(it.id.Client, it.id.Clock, it.length)
+		}
+	}
+}
+
+// findPosition returns the neighbours of a UTF-16 index in a text, splitting
+// the item that contains it
+func (doc *Doc) findPosition(t *sharedType, index uint64) (*item, *item) {
+	var left *item
+	right := t.start
+	for right != nil && index > 0 {
+		if !right.deleted && right.content.countable() {
+			if index < right.length {
+				doc.getItemCleanStart(ID{Client: right.id.Client, Clock: right.id.Clock + index})
+			}
+			index -= right.length
+		}
+		left, right = right, right.right
+	}
+	return left, right
+}
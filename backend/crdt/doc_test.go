@@ -0,0 +1,349 @@
+package crdt
+
+import (
+	"testing"
+)
+
+// update builds a raw v1 update from encoder calls
+func update(write func(e *encoder)) []byte {
+	e := &encoder{}
+	write(e)
+	return e.bytes()
+}
+
+// textUpdate returns an update from a fresh client inserting text
+func textUpdate(text string) []byte {
+	doc := NewDoc()
+	return doc.Transact(func() {
+		doc.InsertText("content", 0, text)
+	})
+}
+
+func TestApplyUpdateMalformed(t *testing.T) {
+	tests := []struct {
+		name    string
+		update  []byte
+		wantErr bool
+	}{
+		{
+			name:    "empty",
+			update:  nil,
+			wantErr: true,
+		},
+		{
+			name:    "truncated struct",
+			update:  []byte{1, 1, 5, 0, 0x04},
+			wantErr: true,
+		},
+		{
+			name:    "origin at a later clock of the same client",
+			update:  []byte{1, 1, 5, 0, 0x84, 5, 100, 1, 'a', 0},
+			wantErr: true,
+		},
+		{
+			name:    "origin at its own clock",
+			update:  []byte{1, 1, 5, 0, 0x84, 5, 0, 1, 'a', 0},
+			wantErr: true,
+		},
+		{
+			name:    "right origin at a later clock of the same client",
+			update:  []byte{1, 1, 5, 0, 0x44, 5, 7, 1, 'a', 0},
+			wantErr: true,
+		},
+		{
+			name: "struct clock past the largest clock",
+			update: update(func(e *encoder) {
+				e.writeVarUint(1)
+				e.writeVarUint(1)
+				e.writeVarUint(5)
+				e.writeVarUint(maxClock)
+				e.writeUint8(refGC)
+				e.writeVarUint(2)
+				e.writeVarUint(0)
+			}),
+			wantErr: true,
+		},
+		{
+			name: "deleted range past the largest clock",
+			update: update(func(e *encoder) {
+				e.writeVarUint(0)
+				e.writeVarUint(1)
+				e.writeVarUint(5)
+				e.writeVarUint(1)
+				e.writeVarUint(1 << 62)
+				e.writeVarUint(1 << 62)
+			}),
+			wantErr: true,
+		},
+		{
+			name:    "unknown content type",
+			update:  []byte{1, 1, 5, 0, 0x1f, 0},
+			wantErr: true,
+		},
+		{
+			name:    "string longer than the message",
+			update:  []byte{1, 1, 5, 0, 0x84, 9, 0, 100, 'a'},
+			wantErr: true,
+		},
+		{
+			name: "origin of an unknown client stays pending",
+			update: update(func(e *encoder) {
+				e.writeVarUint(1)
+				e.writeVarUint(1)
+				e.writeVarUint(5)
+				e.writeVarUint(0)
+				e.writeUint8(0x80 | refString)
+				e.writeID(ID{Client: 9, Clock: 3})
+				e.writeVarString("a")
+				e.writeVarUint(0)
+			}),
+		},
+		{
+			name: "deletion of unknown structs stays pending",
+			update: update(func(e *encoder) {
+				e.writeVarUint(0)
+				e.writeVarUint(1)
+				e.writeVarUint(5)
+				e.writeVarUint(1)
+				e.writeVarUint(0)
+				e.writeVarUint(3)
+			}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := NewDoc()
+			err := doc.ApplyUpdate(tt.update)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ApplyUpdate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := doc.Text("content"); got != "" {
+				t.Errorf("Text() = %q, want empty", got)
+			}
+		})
+	}
+}
+
+func TestApplyUpdateResolvesPending(t *testing.T) {
+	a := NewDoc()
+	first := a.Transact(func() { a.InsertText("content", 0, "hello") })
+	second := a.Transact(func() { a.InsertText("content", 5, " world") })
+
+	doc := NewDoc()
+	if err := doc.ApplyUpdate(second); err != nil {
+		t.Fatalf("ApplyUpdate(second) error = %v", err)
+	}
+	if got := doc.Text("content"); got != "" {
+		t.Fatalf("Text() before its dependency = %q, want empty", got)
+	}
+	if err := doc.ApplyUpdate(first); err != nil {
+		t.Fatalf("ApplyUpdate(first) error = %v", err)
+	}
+	if got := doc.Text("content"); got != "hello world" {
+		t.Errorf("Text() = %q, want %q", got, "hello world")
+	}
+}
+
+func TestDecodeMessageMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"missing sync type", []byte{MessageSync}},
+		{"unknown sync type", []byte{MessageSync, 7, 0}},
+		{"payload longer than the message", []byte{MessageSync, SyncUpdate, 10, 1}},
+		{"varuint overflow", []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}},
+		{"truncated awareness", []byte{MessageAwareness, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeMessage(tt.data); err == nil {
+				t.Error("DecodeMessage() error = nil, want an error")
+			}
+		})
+	}
+}
+
+// FuzzApplyUpdate checks that no update, however malformed, panics a document
+// that already holds text, and that whatever it integrates survives a round
+// trip through EncodeStateAsUpdate
+func FuzzApplyUpdate(f *testing.F) {
+	f.Add(textUpdate("hello"))
+	f.Add([]byte{1, 1, 5, 0, 0x84, 5, 100, 1, 'a', 0})
+	f.Add([]byte{1, 1, 5, 0, 0x44, 5, 7, 1, 'a', 0})
+	f.Add([]byte{0, 1, 5, 1, 0, 3})
+
+	base := NewDoc()
+	seed := base.Transact(func() { base.InsertText("content", 0, "seed text") })
+	edit := base.Transact(func() { base.DeleteText("content", 2, 3) })
+	f.Add(edit)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		doc := NewDoc()
+		if err := doc.ApplyUpdate(seed); err != nil {
+			t.Fatalf("ApplyUpdate(seed) error = %v", err)
+		}
+		if err := doc.ApplyUpdate(data); err != nil {
+			return
+		}
+
+		state, err := doc.EncodeStateAsUpdate(nil)
+		if err != nil {
+			t.Fatalf("EncodeStateAsUpdate() error = %v", err)
+		}
+		replica := NewDoc()
+		if err := replica.ApplyUpdate(state); err != nil {
+			t.Fatalf("ApplyUpdate(state) error = %v", err)
+		}
+		if got, want := replica.Text("content"), doc.Text("content"); got != want {
+			t.Errorf("replica Text() = %q, want %q", got, want)
+		}
+	})
+}
+
+func TestLoadDoc(t *testing.T) {
+	saved := NewDoc()
+	saved.Transact(func() { saved.InsertText("content", 0, "hello") })
+	state, err := saved.EncodeStateAsUpdate(nil)
+	if err != nil {
+		t.Fatalf("EncodeStateAsUpdate() error = %v", err)
+	}
+
+	doc, err := LoadDoc(state)
+	if err != nil {
+		t.Fatalf("LoadDoc() error = %v", err)
+	}
+	if got := doc.Text("content"); got != "hello" {
+		t.Errorf("Text() = %q, want %q", got, "hello")
+	}
+	if doc.clientID == saved.clientID {
+		t.Errorf("LoadDoc() reused client ID %d of the saved state", doc.clientID)
+	}
+
+	// The saved client's later edits still merge with the loaded document's
+	edit := saved.Transact(func() { saved.InsertText("content", 5, "!") })
+	doc.Transact(func() { doc.InsertText("content", 0, ">") })
+	if err := doc.ApplyUpdate(edit); err != nil {
+		t.Fatalf("ApplyUpdate() error = %v", err)
+	}
+	if got := doc.Text("content"); got != ">hello!" {
+		t.Errorf("Text() = %q, want %q", got, ">hello!")
+	}
+
+	if _, err := LoadDoc([]byte{1, 1}); err == nil {
+		t.Error("LoadDoc() of a truncated state error = nil, want an error")
+	}
+}
+
+func TestEncodeStateAsUpdateRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		edits func(doc *Doc)
+		want  string
+	}{
+		{"empty", func(doc *Doc) {}, ""},
+		{"insert", func(doc *Doc) { doc.InsertText("content", 0, "hello") }, "hello"},
+		{"inserts in the middle", func(doc *Doc) {
+			doc.InsertText("content", 0, "hlo")
+			doc.InsertText("content", 1, "el")
+		}, "hello"},
+		{"deletes", func(doc *Doc) {
+			doc.InsertText("content", 0, "hello world")
+			doc.DeleteText("content", 5, 6)
+			doc.DeleteText("content", 0, 1)
+		}, "ello"},
+		{"everything deleted", func(doc *Doc) {
+			doc.InsertText("content", 0, "gone")
+			doc.DeleteText("content", 0, 4)
+		}, ""},
+		{"surrogate pairs", func(doc *Doc) {
+			doc.InsertText("content", 0, "a😀b")
+			doc.DeleteText("content", 3, 1)
+		}, "a😀"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := NewDoc()
+			doc.Transact(func() { tt.edits(doc) })
+			state, err := doc.EncodeStateAsUpdate(nil)
+			if err != nil {
+				t.Fatalf("EncodeStateAsUpdate() error = %v", err)
+			}
+
+			replica := NewDoc()
+			if err := replica.ApplyUpdate(state); err != nil {
+				t.Fatalf("ApplyUpdate() error = %v", err)
+			}
+			if got := replica.Text("content"); got != tt.want {
+				t.Errorf("Text() = %q, want %q", got, tt.want)
+			}
+
+			// Encoding the replica gives back an equivalent state
+			again, err := replica.EncodeStateAsUpdate(nil)
+			if err != nil {
+				t.Fatalf("EncodeStateAsUpdate() of the replica error = %v", err)
+			}
+			if got, err := LoadDoc(again); err != nil || got.Text("content") != tt.want {
+				t.Errorf("LoadDoc() of the replica's state = %v, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyncByStateVector(t *testing.T) {
+	a := NewDoc()
+	shared := a.Transact(func() { a.InsertText("content", 0, "shared") })
+	b := NewDoc()
+	if err := b.ApplyUpdate(shared); err != nil {
+		t.Fatalf("ApplyUpdate() error = %v", err)
+	}
+
+	// Concurrent edits, then each side sends what the other is missing
+	a.Transact(func() { a.InsertText("content", 0, "a:") })
+	b.Transact(func() {
+		b.DeleteText("content", 0, 1)
+		b.InsertText("content", 5, ":b")
+	})
+	toB, err := a.EncodeStateAsUpdate(b.EncodeStateVector())
+	if err != nil {
+		t.Fatalf("EncodeStateAsUpdate() error = %v", err)
+	}
+	toA, err := b.EncodeStateAsUpdate(a.EncodeStateVector())
+	if err != nil {
+		t.Fatalf("EncodeStateAsUpdate() error = %v", err)
+	}
+	if err := a.ApplyUpdate(toA); err != nil {
+		t.Fatalf("ApplyUpdate() error = %v", err)
+	}
+	if err := b.ApplyUpdate(toB); err != nil {
+		t.Fatalf("ApplyUpdate() error = %v", err)
+	}
+
+	if got, want := a.Text("content"), "a:hared:b"; got != want {
+		t.Errorf("a Text() = %q, want %q", got, want)
+	}
+	if got, want := b.Text("content"), a.Text("content"); got != want {
+		t.Errorf("b Text() = %q, want %q", got, want)
+	}
+
+	if _, err := a.EncodeStateAsUpdate([]byte{3, 1}); err == nil {
+		t.Error("EncodeStateAsUpdate() of a truncated state vector error = nil, want an error")
+	}
+}
+
+func TestSyncMessageRoundTrip(t *testing.T) {
+	update := textUpdate("hello")
+	for _, syncType := range []uint64{SyncStep1, SyncStep2, SyncUpdate} {
+		msg, err := DecodeMessage(EncodeSyncMessage(syncType, update))
+		if err != nil {
+			t.Fatalf("DecodeMessage() error = %v", err)
+		}
+		if msg.Type != MessageSync || msg.SyncType != syncType || string(msg.Payload) != string(update) {
+			t.Errorf("DecodeMessage() = %+v, want sync type %d with the update", msg, syncType)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package crdt
+
+import (
+	"errors"
+	"fmt"
+)
+
+// errUnexpectedEnd is returned when a message ends in the middle of a value
+var errUnexpectedEnd = errors.New("unexpected end of message")
+
+// encoder writes values in the lib0 binary encoding used by Yjs
+type encoder struct {
+	buf []byte
+}
+
+// bytes returns the encoded message
+func (e *encoder) bytes() []byte {
+	return e.buf
+}
+
+// writeUint8 writes a single byte
+func (e *encoder) writeUint8(b byte) {
+	e.buf = append(e.buf, b)
+}
+
+// writeVarUint writes an unsigned integer using 7 bits per byte
+func (e *encoder) writeVarUint(n uint64) {
+	for n > 0x7f {
+		e.buf = append(e.buf, byte(n&0x7f)|0x80)
+		n >>= 7
+	}
+	e.buf = append(e.buf, byte(n))
+}
+
+// writeVarBytes writes a length-prefixed byte array
+func (e *encoder) writeVarBytes(b []byte) {
+	e.writeVarUint(uint64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+// writeVarString writes a length-prefixed UTF-8 string
+func (e *encoder) writeVarString(s string) {
+	e.writeVarUint(uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+// writeRaw writes already encoded bytes
+func (e *encoder) writeRaw(b []byte) {
+	e.buf = append(e.buf, b...)
+}
+
+// writeID writes a struct ID
+func (e *encoder) writeID(id ID) {
+	e.writeVarUint(id.Client)
+	e.writeVarUint(id.Clock)
+}
+
+// decoder reads values in the lib0 binary encoding used by Yjs
+type decoder struct {
+	buf []byte
+	pos int
+}
+
+// newDecoder creates a decoder over buf
+func newDecoder(buf []byte) *decoder {
+	return &decoder{buf: buf}
+}
+
+// hasContent reports whether there are unread bytes left
+func (d *decoder) hasContent() bool {
+	return d.pos < len(d.buf)
+}
+
+// readUint8 reads a single byte
+func (d *decoder) readUint8() (byte, error) {
+	if d.pos >= len(d.buf) {
+		return 0, errUnexpectedEnd
+	}
+	b := d.buf[d.pos]
+	d.pos++
+	return b, nil
+}
+
+// readVarUint reads an unsigned integer written with writeVarUint
+func (d *decoder) readVarUint() (uint64, error) {
+	var n uint64
+	for shift := uint(0); ; shift += 7 {
+		if shift > 63 {
+			return 0, fmt.Errorf("varuint overflow")
+		}
+		b, err := d.readUint8()
+		if err != nil {
+			return 0, err
+		}
+		n |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			return n, nil
+		}
+	}
+}
+
+// readLength reads a length and checks it against the remaining input
+func (d *decoder) readLength() (int, error) {
+	n, err := d.readVarUint()
+	if err != nil {
+		return 0, err
+	}
+	if n > uint64(len(d.buf)-d.pos) {
+		return 0, errUnexpectedEnd
+	}
+	return int(n), nil
+}
+
+// readVarBytes reads a length-prefixed byte array
+func (d *decoder) readVarBytes() ([]byte, error) {
+	n, err := d.readLength()
+	if err != nil {
+		return nil, err
+	}
+	return d.readRaw(n)
+}
+
+// readVarString reads a length-prefixed UTF-8 string
+func (d *decoder) readVarString() (string, error) {
+	b, err := d.readVarBytes()
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// readRaw reads n bytes
+func (d *decoder) readRaw(n int) ([]byte, error) {
+	if n > len(d.buf)-d.pos {
+		return nil, errUnexpectedEnd
+	}
+	b := d.buf[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+// readID reads a struct ID
+func (d *decoder) readID() (ID, error) {
+	client, err := d.readVarUint()
+	if err != nil {
+		return ID{}, err
+	}
+	clock, err := d.readVarUint()
+	if err != nil {
+		return ID{}, err
+	}
+	return ID{Client: client, Clock: clock}, nil
+}
+
+// readAny reads a lib0 "any" value and returns its raw encoding, which is all
+// the server needs to store and forward it
+func (d *decoder) readAny() ([]byte, error) {
+	start := d.pos
+	if err := d.skipAny(); err != nil {
+		return nil, err
+	}
+	return d.buf[start:d.pos], nil
+}
+
+// skipAny advances past a lib0 "any" value
+func (d *decoder) skipAny() error {
+	tag, err := d.readUint8()
+	if err != nil {
+		return err
+	}
+
+	switch tag {
+	case 127, 126, 121, 120: // undefined, null, false, true
+		return nil
+	case 125: // varint
+		for {
+			b, err := d.readUint8()
+			if err != nil {
+				return err
+			}
+			if b < 0x80 {
+				return nil
+			}
+		}
+	case 124: // float32
+		_, err := d.readRaw(4)
+		return err
+	case 123, 122: // float64, bigint64
+		_, err := d.readRaw(8)
+		return err
+	case 119, 116: // string, Uint8Array
+		_, err := d.readVarBytes()
+		return err
+	case 118: // object
+		n, err := d.readVarUint()
+		if err != nil {
+			return err
+		}
+		for i := uint64(0); i < n; i++ {
+			if _, err := d.readVarBytes(); err != nil {
+				return err
+			}
+			if err := d.skipAny(); err != nil {
+				return err
+			}
+		}
+		return nil
+	case 117: // array
+		n, err := d.readVarUint()
+		if err != nil {
+			return err
+		}
+		for i := uint64(0); i < n; i++ {
+			if err := d.skipAny(); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown any type: %d", tag)
+	}
+}
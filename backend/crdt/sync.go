@@ -0,0 +1,64 @@
+package crdt
+
+import "fmt"
+
+// Message types of the y-websocket protocol
+const (
+	MessageSync           = 0
+	MessageAwareness      = 1
+	MessageAuth           = 2
+	MessageQueryAwareness = 3
+)
+
+// Sync message types of the y-protocols sync protocol
+const (
+	SyncStep1  = 0
+	SyncStep2  = 1
+	SyncUpdate = 2
+)
+
+// Message is a decoded y-websocket message. Payload holds the state vector
+// for sync step 1, the update for sync step 2 and update messages, and the
+// awareness update for awareness messages.
+type Message struct {
+	Type     uint64
+	SyncType uint64
+	Payload  []byte
+}
+
+// DecodeMessage decodes a binary y-websocket message
+func DecodeMessage(data []byte) (*Message, error) {
+	d := newDecoder(data)
+	msgType, err := d.readVarUint()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read message type: %w", err)
+	}
+
+	msg := &Message{Type: msgType}
+	switch msgType {
+	case MessageSync:
+		if msg.SyncType, err = d.readVarUint(); err != nil {
+			return nil, fmt.Errorf("failed to read sync message type: %w", err)
+		}
+		if msg.SyncType > SyncUpdate {
+			return nil, fmt.Errorf("unknown sync message type: %d", msg.SyncType)
+		}
+		if msg.Payload, err = d.readVarBytes(); err != nil {
+			return nil, fmt.Errorf("failed to read sync payload: %w", err)
+		}
+	case MessageAwareness:
+		if msg.Payload, err = d.readVarBytes(); err != nil {
+			return nil, fmt.Errorf("failed to read awareness payload: %w", err)
+		}
+	}
+	return msg, nil
+}
+
+// EncodeSyncMessage encodes a sync protocol message
+func EncodeSyncMessage(syncType uint64, payload []byte) []byte {
+	e := &encoder{}
+	e.writeVarUint(MessageSync)
+	e.writeVarUint(syncType)
+	e.writeVarBytes(payload)
+	return e.bytes()
+}
@@ -0,0 +1,331 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Line operations
+const (
+	OpEqual  = "equal"
+	OpInsert = "insert"
+	OpDelete = "delete"
+)
+
+// Limits on the Myers search. workBudget bounds the lines compared and
+// maxEditCost the changed lines, which also bounds the memory kept for
+// backtracking. Documents that differ by more get a coarser, but still
+// correct, diff.
+const (
+	workBudget  = 20_000_000
+	maxEditCost = 2048
+)
+
+// Line is one line of a diff. Text excludes the line break.
+type Line struct {
+	Op        string `json:"op"`
+	Text      string `json:"text"`
+	NoNewline bool   `json:"noNewline,omitempty"`
+}
+
+// Hunk is a group of changed lines with their surrounding context. Starts are
+// 1-based; an empty range starts at the line before it, as in unified diffs.
+type Hunk struct {
+	OldStart int    `json:"oldStart"`
+	OldLines int    `json:"oldLines"`
+	NewStart int    `json:"newStart"`
+	NewLines int    `json:"newLines"`
+	Lines    []Line `json:"lines"`
+}
+
+// Result is the line diff between two texts
+type Result struct {
+	Hunks []Hunk `json:"hunks"`
+	// Exact is false when the texts differed too much for a minimal diff and
+	// the changed region was reported as one block
+	Exact bool `json:"exact"`
+}
+
+// Compute diffs old against new line by line and groups the changes into
+// hunks with the given number of context lines
+func Compute(old, new string, context int) Result {
+	a, b := splitLines(old), splitLines(new)
+	lines, exact := diffLines(a, b)
+	hunks := group(lines, max(context, 0))
+	if hunks == nil {
+		hunks = []Hunk{}
+	}
+	return Result{Hunks: hunks, Exact: exact}
+}
+
+// Unified renders hunks as a unified diff between the named texts. It returns
+// an empty string when there are no changes.
+func Unified(fromName, toName string, hunks []Hunk) string {
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromName, toName)
+	for _, h := range hunks {
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", formatRange(h.OldStart, h.OldLines), formatRange(h.NewStart, h.NewLines))
+		for _, l := range h.Lines {
+			switch l.Op {
+			case OpInsert:
+				sb.WriteByte('+')
+			case OpDelete:
+				sb.WriteByte('-')
+			default:
+				sb.WriteByte(' ')
+			}
+			sb.WriteString(l.Text)
+			sb.WriteByte('\n')
+			if l.NoNewline {
+				sb.WriteString("\\ No newline at end of file\n")
+			}
+		}
+	}
+	return sb.String()
+}
+
+// formatRange formats a hunk range the way diff -u does
+func formatRange(start, lines int) string {
+	if lines == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, lines)
+}
+
+// splitLines splits s into lines that keep their line break
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// newLine converts a split line into a diff line
+func newLine(op, text string) Line {
+	if trimmed, ok := strings.CutSuffix(text, "\n"); ok {
+		return Line{Op: op, Text: trimmed}
+	}
+	return Line{Op: op, Text: text, NoNewline: true}
+}
+
+// diffLines returns the edit script turning a into b and whether it is minimal
+func diffLines(a, b []string) ([]Line, bool) {
+	// Common prefix and suffix need no search
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	lines := make([]Line, 0, len(a)+len(b)-prefix-suffix)
+	for _, text := range a[:prefix] {
+		lines = append(lines, newLine(OpEqual, text))
+	}
+
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	middle, exact := myers(midA, midB)
+	if !exact {
+		middle = middle[:0]
+		for _, text := range midA {
+			middle = append(middle, newLine(OpDelete, text))
+		}
+		for _, text := range midB {
+			middle = append(middle, newLine(OpInsert, text))
+		}
+	}
+	lines = append(lines, middle...)
+
+	for _, text := range a[len(a)-suffix:] {
+		lines = append(lines, newLine(OpEqual, text))
+	}
+	return lines, exact
+}
+
+// myers finds a shortest edit script with Myers' O(ND) algorithm. It gives up
+// and returns false once the search exceeds its limits.
+func myers(a, b []string) ([]Line, bool) {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		lines := make([]Line, 0, n+m)
+		for _, text := range a {
+			lines = append(lines, newLine(OpDelete, text))
+		}
+		for _, text := range b {
+			lines = append(lines, newLine(OpInsert, text))
+		}
+		return lines, true
+	}
+
+	// Compare line IDs instead of strings
+	ids := make(map[string]int)
+	intern := func(lines []string) []int {
+		out := make([]int, len(lines))
+		for i, text := range lines {
+			id, ok := ids[text]
+			if !ok {
+				id = len(ids)
+				ids[text] = id
+			}
+			out[i] = id
+		}
+		return out
+	}
+	x, y := intern(a), intern(b)
+
+	limit := min(n+m, maxEditCost)
+	work := 0
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	// trace[d] holds v[-d..d] as it was before round d
+	trace := make([][]int, 0, 16)
+
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var i int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				i = v[offset+k+1]
+			} else {
+				i = v[offset+k-1] + 1
+			}
+			j := i - k
+			for i < n && j < m && x[i] == y[j] {
+				i++
+				j++
+				work++
+			}
+			v[offset+k] = i
+			if work++; work > workBudget {
+				return nil, false
+			}
+			if i >= n && j >= m {
+				return backtrack(a, b, trace, d), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// backtrack walks the Myers trace back from the end of both inputs and
+// returns the edit script in order
+func backtrack(a, b []string, trace [][]int, d int) []Line {
+	i, j := len(a), len(b)
+	reversed := make([]Line, 0, len(a)+len(b))
+
+	for ; d > 0; d-- {
+		v := trace[d]
+		get := func(k int) int { return v[k+d] }
+		k := i - j
+
+		var prevK int
+		if k == -d || (k != d && get(k-1) < get(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevI := get(prevK)
+		prevJ := prevI - prevK
+
+		for i > prevI && j > prevJ {
+			i--
+			j--
+			reversed = append(reversed, newLine(OpEqual, a[i]))
+		}
+		if i == prevI {
+			j--
+			reversed = append(reversed, newLine(OpInsert, b[j]))
+		} else {
+			i--
+			reversed = append(reversed, newLine(OpDelete, a[i]))
+		}
+	}
+	for i > 0 {
+		i--
+		reversed = append(reversed, newLine(OpEqual, a[i]))
+	}
+
+	for l, r := 0, len(reversed)-1; l < r; l, r = l+1, r-1 {
+		reversed[l], reversed[r] = reversed[r], reversed[l]
+	}
+	return reversed
+}
+
+// group splits an edit script into hunks, keeping up to context unchanged
+// lines around each change
+func group(lines []Line, context int) []Hunk {
+	var hunks []Hunk
+	oldNo, newNo := 1, 1
+
+	for i := 0; i < len(lines); {
+		if lines[i].Op == OpEqual {
+			i++
+			oldNo++
+			newNo++
+			continue
+		}
+
+		// Start the hunk up to context lines before the first change
+		start := i
+		for start > 0 && i-start < context && lines[start-1].Op == OpEqual {
+			start--
+		}
+		h := Hunk{OldStart: oldNo - (i - start), NewStart: newNo - (i - start)}
+
+		// Extend it while the unchanged gap to the next change is short
+		end := i
+		for end < len(lines) {
+			if lines[end].Op != OpEqual {
+				end++
+				continue
+			}
+			gap := end
+			for gap < len(lines) && lines[gap].Op == OpEqual {
+				gap++
+			}
+			if gap == len(lines) || gap-end > 2*context {
+				end = min(end+context, gap)
+				break
+			}
+			end = gap
+		}
+
+		h.Lines = lines[start:end]
+		for _, l := range h.Lines {
+			if l.Op != OpInsert {
+				h.OldLines++
+			}
+			if l.Op != OpDelete {
+				h.NewLines++
+			}
+		}
+		if h.OldLines == 0 {
+			h.OldStart--
+		}
+		if h.NewLines == 0 {
+			h.NewStart--
+		}
+		hunks = append(hunks, h)
+
+		for _, l := range lines[i:end] {
+			if l.Op != OpInsert {
+				oldNo++
+			}
+			if l.Op != OpDelete {
+				newNo++
+			}
+		}
+		i = end
+	}
+	return hunks
+}
@@ -0,0 +1,154 @@
+package diff
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// rebuild joins the lines of hunks computed with enough context to cover the
+// whole texts back into the old and the new text
+func rebuild(hunks []Hunk) (string, string) {
+	var old, new strings.Builder
+	for _, h := range hunks {
+		for _, l := range h.Lines {
+			text := l.Text
+			if !l.NoNewline {
+				text += "\n"
+			}
+			if l.Op != OpInsert {
+				old.WriteString(text)
+			}
+			if l.Op != OpDelete {
+				new.WriteString(text)
+			}
+		}
+	}
+	return old.String(), new.String()
+}
+
+func TestComputeUnified(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		context  int
+		want     string
+	}{
+		{
+			name: "unchanged",
+			old:  "a\nb\n",
+			new:  "a\nb\n",
+			want: "",
+		},
+		{
+			name:    "changed line",
+			old:     "a\nb\nc\n",
+			new:     "a\nB\nc\n",
+			context: 1,
+			want:    "--- old\n+++ new\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			name:    "context is trimmed",
+			old:     "1\n2\n3\n4\n5\n",
+			new:     "1\n2\nx\n4\n5\n",
+			context: 1,
+			want:    "--- old\n+++ new\n@@ -2,3 +2,3 @@\n 2\n-3\n+x\n 4\n",
+		},
+		{
+			name:    "distant changes get separate hunks",
+			old:     "1\n2\n3\n4\n5\n6\n7\n",
+			new:     "x\n2\n3\n4\n5\n6\ny\n",
+			context: 1,
+			want:    "--- old\n+++ new\n@@ -1,2 +1,2 @@\n-1\n+x\n 2\n@@ -6,2 +6,2 @@\n 6\n-7\n+y\n",
+		},
+		{
+			name:    "insert into empty text",
+			old:     "",
+			new:     "a\n",
+			context: 3,
+			want:    "--- old\n+++ new\n@@ -0,0 +1 @@\n+a\n",
+		},
+		{
+			name:    "missing final newline",
+			old:     "a\nb",
+			new:     "a\nb\n",
+			context: 0,
+			want:    "--- old\n+++ new\n@@ -2 +2 @@\n-b\n\\ No newline at end of file\n+b\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Compute(tt.old, tt.new, tt.context)
+			if !result.Exact {
+				t.Errorf("Compute() Exact = false, want true")
+			}
+			if got := Unified("old", "new", result.Hunks); got != tt.want {
+				t.Errorf("Unified() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComputeIsMinimal(t *testing.T) {
+	result := Compute("a\nb\nc\nd\n", "a\nc\nd\ne\n", 10)
+	changes := 0
+	for _, h := range result.Hunks {
+		for _, l := range h.Lines {
+			if l.Op != OpEqual {
+				changes++
+			}
+		}
+	}
+	if changes != 2 {
+		t.Errorf("Compute() changed %d lines, want 2", changes)
+	}
+}
+
+func TestComputeRebuildsBothTexts(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	words := []string{"a\n", "b\n", "c\n", "d\n"}
+	random := func() string {
+		var sb strings.Builder
+		for range rng.Intn(12) {
+			sb.WriteString(words[rng.Intn(len(words))])
+		}
+		if rng.Intn(4) == 0 {
+			sb.WriteString("tail")
+		}
+		return sb.String()
+	}
+
+	for range 1000 {
+		old, new := random(), random()
+		result := Compute(old, new, len(old)+len(new))
+		gotOld, gotNew := rebuild(result.Hunks)
+		if old == new {
+			if len(result.Hunks) != 0 {
+				t.Fatalf("Compute(%q, %q) = %v, want no hunks", old, new, result.Hunks)
+			}
+			continue
+		}
+		if gotOld != old || gotNew != new {
+			t.Fatalf("Compute(%q, %q) rebuilds %q and %q", old, new, gotOld, gotNew)
+		}
+	}
+}
+
+func TestComputeFallsBackForLargeChanges(t *testing.T) {
+	var old, new strings.Builder
+	for i := range maxEditCost {
+		fmt.Fprintf(&old, "old %d\n", i)
+		fmt.Fprintf(&new, "new %d\n", i)
+	}
+
+	result := Compute("same\n"+old.String(), "same\n"+new.String(), 1<<20)
+	if result.Exact {
+		t.Error("Compute() Exact = true, want false")
+	}
+	gotOld, gotNew := rebuild(result.Hunks)
+	if gotOld != "same\n"+old.String() || gotNew != "same\n"+new.String() {
+		t.Error("Compute() hunks do not rebuild the texts")
+	}
+}
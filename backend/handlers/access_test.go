@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/logoes0/peeriodic.git/auth"
+	"github.com/logoes0/peeriodic.git/config"
+	"github.com/logoes0/peeriodic.git/services"
+)
+
+const testRoomID = "room-1"
+
+// newTestRoom returns a memory store holding testRoomID, owned by "owner"
+// with "editor" and "viewer" as members
+func newTestRoom(t *testing.T) (*services.MemoryStore, *services.WebSocketService) {
+	t.Helper()
+	ctx := context.Background()
+	store := services.NewMemoryStore()
+	if _, err := store.CreateUser(ctx, "owner", "owner@example.com", "Owner"); err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	owner := "owner"
+	if _, err := store.CreateRoom(ctx, testRoomID, "Room", &owner); err != nil {
+		t.Fatalf("CreateRoom() error = %v", err)
+	}
+	for uid, role := range map[string]string{"editor": services.RoleEditor, "viewer": services.RoleViewer} {
+		if _, err := store.SetRoomMember(ctx, testRoomID, uid, role); err != nil {
+			t.Fatalf("SetRoomMember() error = %v", err)
+		}
+	}
+	return store, services.NewWebSocketService(&config.Config{}, nil)
+}
+
+// newRequest builds a request from a verified caller, or an anonymous one
+// when uid is empty
+func newRequest(method, target, body, uid string) *http.Request {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	if uid != "" {
+		r = r.WithContext(auth.WithIdentity(r.Context(), &auth.Identity{UID: uid}))
+	}
+	return r
+}
+
+// decodeData decodes the data of a successful response into v
+func decodeData(t *testing.T, w *httptest.ResponseRecorder, v any) {
+	t.Helper()
+	var response struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("decoding response error = %v", err)
+	}
+	if err := json.Unmarshal(response.Data, v); err != nil {
+		t.Fatalf("decoding response data error = %v", err)
+	}
+}
+
+func TestRoomAccess(t *testing.T) {
+	tests := []struct {
+		name       string
+		target     string
+		uid        string
+		wantStatus int
+		wantRole   string
+	}{
+		{"owner", "/api/rooms/room-1", "owner", http.StatusOK, services.RoleOwner},
+		{"viewer", "/api/rooms/room-1", "viewer", http.StatusOK, services.RoleViewer},
+		{"stranger", "/api/rooms/room-1", "stranger", http.StatusForbidden, ""},
+		{"anonymous", "/api/rooms/room-1", "", http.StatusForbidden, ""},
+		{"claimed uid", "/api/rooms/room-1?uid=owner", "", http.StatusOK, services.RoleOwner},
+		{"claimed uid under a verified one", "/api/rooms/room-1?uid=owner", "stranger", http.StatusForbidden, ""},
+		{"invalid link", "/api/rooms/room-1?token=nope", "stranger", http.StatusForbidden, ""},
+	}
+
+	store, wsService := newTestRoom(t)
+	handler := NewRoomHandler(store, wsService)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.HandleRoomByID(w, newRequest(http.MethodGet, tt.target, "", tt.uid))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var room RoomResponse
+			decodeData(t, w, &room)
+			if room.Role != tt.wantRole {
+				t.Errorf("role = %q, want %q", room.Role, tt.wantRole)
+			}
+		})
+	}
+}
+
+// TestRoomWithoutMembersCanBeClaimed covers rooms left without members, as
+// the members migration leaves rooms that were opened without a creator
+func TestRoomWithoutMembersCanBeClaimed(t *testing.T) {
+	ctx := context.Background()
+	store, wsService := newTestRoom(t)
+	if _, err := store.CreateRoom(ctx, "orphan", "Orphan", nil); err != nil {
+		t.Fatalf("CreateRoom() error = %v", err)
+	}
+	handler := NewRoomHandler(store, wsService)
+
+	w := httptest.NewRecorder()
+	handler.HandleRoomByID(w, newRequest(http.MethodGet, "/api/rooms/orphan", "", "owner"))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status before claiming = %d, want %d", w.Code, http.StatusForbidden)
+	}
+
+	if err := services.ClaimRoom(ctx, store, "orphan", "claimer"); err != nil {
+		t.Fatalf("ClaimRoom() error = %v", err)
+	}
+	w = httptest.NewRecorder()
+	handler.HandleRoomByID(w, newRequest(http.MethodGet, "/api/rooms/orphan", "", "claimer"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status after claiming = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	var room RoomResponse
+	decodeData(t, w, &room)
+	if room.Role != services.RoleOwner {
+		t.Errorf("role after claiming = %q, want %q", room.Role, services.RoleOwner)
+	}
+
+	// Rooms with members cannot be taken over
+	for _, id := range []string{"orphan", testRoomID} {
+		if err := services.ClaimRoom(ctx, store, id, "stranger"); !errors.Is(err, services.ErrRoomClaimed) {
+			t.Errorf("ClaimRoom(%q) error = %v, want ErrRoomClaimed", id, err)
+		}
+	}
+	if err := services.ClaimRoom(ctx, store, "missing", "stranger"); !errors.Is(err, services.ErrRoomNotFound) {
+		t.Errorf("ClaimRoom() of a missing room error = %v, want ErrRoomNotFound", err)
+	}
+}
+
+func TestOwnerActionsRequireAuthentication(t *testing.T) {
+	tests := []struct {
+		name       string
+		target     string
+		uid        string
+		trusted    bool
+		wantStatus int
+	}{
+		{"verified owner", "/api/rooms/room-1/links", "owner", false, http.StatusOK},
+		{"verified editor", "/api/rooms/room-1/links", "editor", false, http.StatusForbidden},
+		{"claimed owner", "/api/rooms/room-1/links?uid=owner", "", false, http.StatusForbidden},
+		{"claimed owner with authentication disabled", "/api/rooms/room-1/links?uid=owner", "", true, http.StatusOK},
+		{"claimed editor with authentication disabled", "/api/rooms/room-1/links?uid=editor", "", true, http.StatusForbidden},
+	}
+
+	store, wsService := newTestRoom(t)
+	handler := NewLinkHandler(store, wsService)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newRequest(http.MethodGet, tt.target, "", tt.uid)
+			if tt.trusted {
+				r = r.WithContext(auth.TrustClaims(r.Context()))
+			}
+			w := httptest.NewRecorder()
+			handler.HandleLinks(w, r)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+		})
+	}
+}
+
+func TestShareLinks(t *testing.T) {
+	tests := []struct {
+		permission string
+		wantStatus int
+		wantRole   string
+	}{
+		{"view", http.StatusOK, services.RoleViewer},
+		{"edit", http.StatusOK, services.RoleEditor},
+		{services.RoleViewer, http.StatusOK, services.RoleViewer},
+		{services.RoleEditor, http.StatusOK, services.RoleEditor},
+		{services.RoleOwner, http.StatusBadRequest, ""},
+		{"", http.StatusBadRequest, ""},
+	}
+
+	store, wsService := newTestRoom(t)
+	links := NewLinkHandler(store, wsService)
+	rooms := NewRoomHandler(store, wsService)
+	for _, tt := range tests {
+		t.Run(tt.permission, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			links.HandleLinks(w, newRequest(http.MethodPost, "/api/rooms/room-1/links", `{"role":"`+tt.permission+`"}`, "owner"))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("creating link status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var link services.RoomLink
+			decodeData(t, w, &link)
+			if link.Role != tt.wantRole || link.Token == "" {
+				t.Fatalf("link = role %q with token %q, want role %q with a token", link.Role, link.Token, tt.wantRole)
+			}
+
+			// A stranger holding the link gets its role
+			w = httptest.NewRecorder()
+			rooms.HandleRoomByID(w, newRequest(http.MethodGet, "/api/rooms/room-1?token="+link.Token, "", "stranger"))
+			if w.Code != http.StatusOK {
+				t.Fatalf("opening link status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+			}
+			var room RoomResponse
+			decodeData(t, w, &room)
+			if room.Role != tt.wantRole {
+				t.Errorf("role through link = %q, want %q", room.Role, tt.wantRole)
+			}
+		})
+	}
+}
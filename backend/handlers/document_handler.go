@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"strings"
@@ -12,13 +14,15 @@ import (
 
 // DocumentHandler handles document-related HTTP requests
 type DocumentHandler struct {
-	dbService *services.DatabaseService
+	store     services.Store
+	wsService *services.WebSocketService
 }
 
 // NewDocumentHandler creates a new document handler instance
-func NewDocumentHandler(dbService *services.DatabaseService) *DocumentHandler {
+func NewDocumentHandler(store services.Store, wsService *services.WebSocketService) *DocumentHandler {
 	return &DocumentHandler{
-		dbService: dbService,
+		store:     store,
+		wsService: wsService,
 	}
 }
 
@@ -32,9 +36,14 @@ type SaveDocumentResponse struct {
 	Status        string `json:"status"`
 	RoomID        string `json:"roomId"`
 	ContentLength int    `json:"contentLength"`
+	Version       int64  `json:"version"`
+	// Live is true when connected editors received the saved document
+	Live bool `json:"live"`
 }
 
-// HandleSave handles document saving
+// HandleSave handles document saving. A request with an If-Match header only
+// saves while the room is still at that version, so it cannot overwrite
+// changes made since the client last read the room.
 func (dh *DocumentHandler) HandleSave(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		utils.MethodNotAllowed(w)
@@ -48,6 +57,10 @@ func (dh *DocumentHandler) HandleSave(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if _, ok := authorizeRoom(w, r, dh.store, roomID, services.RoleEditor); !ok {
+		return
+	}
+
 	// Parse request body
 	var req SaveDocumentRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -61,25 +74,62 @@ func (dh *DocumentHandler) HandleSave(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Save document to database
+	versions, matchAny, err := parseIfMatch(r.Header.Get("If-Match"))
+	if err != nil {
+		utils.BadRequest(w, err.Error())
+		return
+	}
+
+	var ifVersion int64
+	if !matchAny {
+		if ifVersion, err = resolveIfMatch(r.Context(), dh.store, roomID, versions); err != nil {
+			writeSaveError(w, roomID, ifVersion, err)
+			return
+		}
+	}
+
+	// Save document to database and push it to connected editors
 	log.Printf("Saving document for room %s, content length: %d", roomID, len(req.Content))
-	err := dh.dbService.UpdateRoomContent(roomID, req.Content)
+	version, live, err := dh.wsService.SaveDocument(r.Context(), dh.store, roomID, req.Content, ifVersion)
 	if err != nil {
-		log.Printf("Failed to save document for room %s: %v", roomID, err)
-		utils.InternalServerError(w, "Failed to save document")
+		writeSaveError(w, roomID, version, err)
 		return
 	}
 	log.Printf("Successfully saved document for room %s", roomID)
+	recordAudit(r, dh.store, services.AuditEvent{
+		RoomID: roomID,
+		Action: services.AuditSave,
+		Detail: fmt.Sprintf("version %d", version),
+	})
 
 	response := SaveDocumentResponse{
 		Status:        "success",
 		RoomID:        roomID,
 		ContentLength: len(req.Content),
+		Version:       version,
+		Live:          live,
 	}
+	w.Header().Set("ETag", roomETag(version))
 
 	utils.SuccessResponse(w, response)
 }
 
+// writeSaveError answers a failed save. A save refused by If-Match gets 412
+// with the ETag of the room's current version.
+func writeSaveError(w http.ResponseWriter, roomID string, version int64, err error) {
+	switch {
+	case errors.Is(err, services.ErrVersionMismatch):
+		log.Printf("Rejected stale save for room %s: %v", roomID, err)
+		w.Header().Set("ETag", roomETag(version))
+		utils.PreconditionFailed(w, "Room has changed since the given version")
+	case strings.Contains(err.Error(), "not found"):
+		utils.NotFound(w, "Room not found")
+	default:
+		log.Printf("Failed to save document for room %s: %v", roomID, err)
+		utils.InternalServerError(w, "Failed to save document")
+	}
+}
+
 // HandleGetDocument handles retrieving a document
 func (dh *DocumentHandler) HandleGetDocument(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -100,7 +150,7 @@ func (dh *DocumentHandler) HandleGetDocument(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	room, err := dh.dbService.GetRoom(roomID)
+	room, err := dh.store.GetRoom(r.Context(), roomID)
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			utils.NotFound(w, "Room not found")
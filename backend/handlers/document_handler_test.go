@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestParseIfMatch(t *testing.T) {
+	tests := []struct {
+		name         string
+		header       string
+		wantVersions []int64
+		wantAny      bool
+		wantErr      bool
+	}{
+		{"absent", "", nil, true, false},
+		{"wildcard", " * ", nil, true, false},
+		{"one tag", `"3"`, []int64{3}, false, false},
+		{"list", `"3", "5" ,"7"`, []int64{3, 5, 7}, false, false},
+		{"weak tag", `W/"3"`, nil, false, false},
+		{"weak and strong tags", `W/"3", "4"`, []int64{4}, false, false},
+		{"foreign tag", `"abc"`, nil, false, false},
+		{"zero version", `"0"`, nil, false, false},
+		{"unquoted", `3`, nil, false, true},
+		{"unterminated", `"3`, nil, false, true},
+		{"missing comma", `"3" "4"`, nil, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			versions, matchAny, err := parseIfMatch(tt.header)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseIfMatch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !slices.Equal(versions, tt.wantVersions) || matchAny != tt.wantAny {
+				t.Errorf("parseIfMatch() = %v, %v, want %v, %v", versions, matchAny, tt.wantVersions, tt.wantAny)
+			}
+		})
+	}
+}
+
+func TestHandleSave(t *testing.T) {
+	// Each case saves to a new room, at version 1
+	tests := []struct {
+		name       string
+		uid        string
+		ifMatch    string
+		body       string
+		wantStatus int
+		wantETag   string
+	}{
+		{"unconditional", "editor", "", `{"content":"new"}`, http.StatusOK, `"2"`},
+		{"wildcard", "editor", "*", `{"content":"new"}`, http.StatusOK, `"2"`},
+		{"current version", "editor", `"1"`, `{"content":"new"}`, http.StatusOK, `"2"`},
+		{"list with the current version", "editor", `"7", "1"`, `{"content":"new"}`, http.StatusOK, `"2"`},
+		{"stale version", "editor", `"7"`, `{"content":"new"}`, http.StatusPreconditionFailed, `"1"`},
+		{"list without the current version", "editor", `"7", "8"`, `{"content":"new"}`, http.StatusPreconditionFailed, `"1"`},
+		{"only a weak tag", "editor", `W/"1"`, `{"content":"new"}`, http.StatusPreconditionFailed, `"1"`},
+		{"malformed If-Match", "editor", `1`, `{"content":"new"}`, http.StatusBadRequest, ""},
+		{"empty content", "editor", "", `{"content":""}`, http.StatusBadRequest, ""},
+		{"viewer", "viewer", "", `{"content":"new"}`, http.StatusForbidden, ""},
+		{"stranger", "stranger", "", `{"content":"new"}`, http.StatusForbidden, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, wsService := newTestRoom(t)
+			r := newRequest(http.MethodPost, "/api/save?room="+testRoomID, tt.body, tt.uid)
+			if tt.ifMatch != "" {
+				r.Header.Set("If-Match", tt.ifMatch)
+			}
+
+			w := httptest.NewRecorder()
+			NewDocumentHandler(store, wsService).HandleSave(w, r)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if got := w.Header().Get("ETag"); got != tt.wantETag {
+				t.Errorf("ETag = %s, want %s", got, tt.wantETag)
+			}
+
+			room, err := store.GetRoom(context.Background(), testRoomID)
+			if err != nil {
+				t.Fatalf("GetRoom() error = %v", err)
+			}
+			wantContent := ""
+			if tt.wantStatus == http.StatusOK {
+				wantContent = "new"
+			}
+			if room.Content != wantContent {
+				t.Errorf("content = %q, want %q", room.Content, wantContent)
+			}
+		})
+	}
+}
+
+func TestHandleSaveRejectsLostUpdate(t *testing.T) {
+	store, wsService := newTestRoom(t)
+	handler := NewDocumentHandler(store, wsService)
+
+	// Two editors read the room at version 1 and both save
+	for _, tt := range []struct {
+		content    string
+		wantStatus int
+	}{
+		{"first", http.StatusOK},
+		{"second", http.StatusPreconditionFailed},
+	} {
+		r := newRequest(http.MethodPost, "/api/save?room="+testRoomID, `{"content":"`+tt.content+`"}`, "editor")
+		r.Header.Set("If-Match", `"1"`)
+		w := httptest.NewRecorder()
+		handler.HandleSave(w, r)
+		if w.Code != tt.wantStatus {
+			t.Fatalf("saving %q status = %d, want %d", tt.content, w.Code, tt.wantStatus)
+		}
+	}
+
+	room, err := store.GetRoom(context.Background(), testRoomID)
+	if err != nil {
+		t.Fatalf("GetRoom() error = %v", err)
+	}
+	if room.Content != "first" || room.Version != 2 {
+		t.Errorf("room = %q at version %d, want %q at version 2", room.Content, room.Version, "first")
+	}
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/logoes0/peeriodic.git/services"
+)
+
+// roomETag is the entity tag of a room at a content version
+func roomETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// parseIfMatch reads the room versions an If-Match header accepts. It
+// reports matchAny for an absent header or "*", which match any existing room.
+// Weak tags and tags that name no room version are left out, since If-Match
+// needs a strong comparison, so a header of only those matches nothing.
+func parseIfMatch(header string) (versions []int64, matchAny bool, err error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return nil, true, nil
+	}
+
+	for header != "" {
+		weak := strings.HasPrefix(header, "W/")
+		header = strings.TrimPrefix(header, "W/")
+		if !strings.HasPrefix(header, `"`) {
+			return nil, false, errors.New("If-Match must be a list of entity tags")
+		}
+		end := strings.IndexByte(header[1:], '"')
+		if end < 0 {
+			return nil, false, errors.New("If-Match has an unterminated entity tag")
+		}
+		tag := header[1 : end+1]
+		header = strings.TrimSpace(header[end+2:])
+
+		if version, err := strconv.ParseInt(tag, 10, 64); err == nil && version > 0 && !weak {
+			versions = append(versions, version)
+		}
+
+		if header == "" {
+			break
+		}
+		if !strings.HasPrefix(header, ",") {
+			return nil, false, errors.New("If-Match must be a list of entity tags")
+		}
+		header = strings.TrimSpace(header[1:])
+	}
+	return versions, false, nil
+}
+
+// resolveIfMatch picks the one version a conditional save checks for. With
+// several acceptable versions it is the room's current one, if listed; when
+// none is, it fails with services.ErrVersionMismatch and the current version.
+func resolveIfMatch(ctx context.Context, store services.Store, roomID string, versions []int64) (int64, error) {
+	if len(versions) == 1 {
+		return versions[0], nil
+	}
+
+	room, err := store.GetRoom(ctx, roomID)
+	if err != nil {
+		return 0, err
+	}
+	if !slices.Contains(versions, room.Version) {
+		return room.Version, fmt.Errorf("%w: room is at version %d", services.ErrVersionMismatch, room.Version)
+	}
+	return room.Version, nil
+}
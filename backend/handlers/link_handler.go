@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/logoes0/peeriodic.git/auth"
+	"github.com/logoes0/peeriodic.git/services"
+	"github.com/logoes0/peeriodic.git/utils"
+)
+
+// LinkHandler handles a room's share links
+type LinkHandler struct {
+	store     services.Store
+	wsService *services.WebSocketService
+}
+
+// NewLinkHandler creates a new link handler instance
+func NewLinkHandler(store services.Store, wsService *services.WebSocketService) *LinkHandler {
+	return &LinkHandler{
+		store:     store,
+		wsService: wsService,
+	}
+}
+
+// CreateLinkRequest represents the request body for creating a share link
+type CreateLinkRequest struct {
+	Role      string     `json:"role"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	MaxUses   int        `json:"max_uses,omitempty"`
+}
+
+// HandleLinks handles /api/rooms/{id}/links and /api/rooms/{id}/links/{linkId}.
+// Only owners may see and manage links.
+func (lh *LinkHandler) HandleLinks(w http.ResponseWriter, r *http.Request) {
+	// Path parts: "", "api", "rooms", {id}, "links"[, {linkId}]
+	pathParts := strings.Split(strings.TrimSuffix(r.URL.Path, "/"), "/")
+	if len(pathParts) < 5 || pathParts[3] == "" {
+		utils.BadRequest(w, "Missing room ID")
+		return
+	}
+	roomID := pathParts[3]
+
+	if _, ok := authorizeRoom(w, r, lh.store, roomID, services.RoleOwner); !ok {
+		return
+	}
+
+	switch {
+	case len(pathParts) == 5 && r.Method == http.MethodGet:
+		lh.handleListLinks(w, r, roomID)
+	case len(pathParts) == 5 && r.Method == http.MethodPost:
+		lh.handleCreateLink(w, r, roomID)
+	case len(pathParts) == 6 && r.Method == http.MethodDelete:
+		lh.handleRevokeLink(w, r, roomID, pathParts[5])
+	case len(pathParts) == 5 || len(pathParts) == 6:
+		utils.MethodNotAllowed(w)
+	default:
+		utils.NotFound(w, "Not found")
+	}
+}
+
+// handleListLinks lists a room's share links without their tokens
+func (lh *LinkHandler) handleListLinks(w http.ResponseWriter, r *http.Request, roomID string) {
+	links, err := lh.store.GetRoomLinks(r.Context(), roomID)
+	if err != nil {
+		log.Printf("Failed to list links of room %s: %v", roomID, err)
+		utils.InternalServerError(w, "Failed to retrieve links")
+		return
+	}
+
+	utils.SuccessResponse(w, links)
+}
+
+// handleCreateLink mints a share link. The response is the only place its
+// token is ever shown.
+func (lh *LinkHandler) handleCreateLink(w http.ResponseWriter, r *http.Request, roomID string) {
+	var req CreateLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body")
+		return
+	}
+	role, ok := services.LinkRole(req.Role)
+	if !ok {
+		utils.BadRequest(w, "Role must be view or edit")
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		utils.BadRequest(w, "Expiry must be in the future")
+		return
+	}
+	if req.MaxUses < 0 {
+		utils.BadRequest(w, "Max uses cannot be negative")
+		return
+	}
+
+	if _, err := lh.store.GetRoom(r.Context(), roomID); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			utils.NotFound(w, "Room not found")
+		} else {
+			utils.InternalServerError(w, "Failed to retrieve room")
+		}
+		return
+	}
+
+	token, hash, err := services.NewLinkToken()
+	if err != nil {
+		utils.InternalServerError(w, "Failed to create link")
+		return
+	}
+	link := &services.RoomLink{
+		ID:        uuid.New().String(),
+		RoomID:    roomID,
+		Token:     token,
+		TokenHash: hash,
+		Role:      role,
+		CreatedBy: auth.CallerUID(r, r.URL.Query().Get("uid")),
+		ExpiresAt: req.ExpiresAt,
+		MaxUses:   req.MaxUses,
+	}
+	if err := lh.store.CreateRoomLink(r.Context(), link); err != nil {
+		log.Printf("Failed to create link to room %s: %v", roomID, err)
+		utils.InternalServerError(w, "Failed to create link")
+		return
+	}
+	recordAudit(r, lh.store, services.AuditEvent{
+		RoomID: roomID,
+		Action: services.AuditLinkCreate,
+		Detail: "link " + link.ID + " as " + link.Role,
+	})
+
+	utils.SuccessResponse(w, link)
+}
+
+// handleRevokeLink deletes a share link and disconnects the clients that
+// joined through it
+func (lh *LinkHandler) handleRevokeLink(w http.ResponseWriter, r *http.Request, roomID, linkID string) {
+	if err := lh.store.DeleteRoomLink(r.Context(), roomID, linkID); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			utils.NotFound(w, "Link not found")
+		} else {
+			log.Printf("Failed to revoke link %s of room %s: %v", linkID, roomID, err)
+			utils.InternalServerError(w, "Failed to revoke link")
+		}
+		return
+	}
+
+	lh.wsService.RevokeLink(roomID, linkID)
+	recordAudit(r, lh.store, services.AuditEvent{
+		RoomID: roomID,
+		Action: services.AuditLinkRevoke,
+		Detail: "link " + linkID,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/logoes0/peeriodic.git/auth"
+	"github.com/logoes0/peeriodic.git/services"
+	"github.com/logoes0/peeriodic.git/utils"
+)
+
+// MemberHandler handles sharing a room with collaborators
+type MemberHandler struct {
+	store     services.Store
+	wsService *services.WebSocketService
+}
+
+// NewMemberHandler creates a new member handler instance
+func NewMemberHandler(store services.Store, wsService *services.WebSocketService) *MemberHandler {
+	return &MemberHandler{
+		store:     store,
+		wsService: wsService,
+	}
+}
+
+// SetMemberRequest represents the request body for adding or changing a member
+type SetMemberRequest struct {
+	Role string `json:"role"`
+}
+
+// HandleMembers handles /api/rooms/{id}/members and
+// /api/rooms/{id}/members/{uid}
+func (mh *MemberHandler) HandleMembers(w http.ResponseWriter, r *http.Request) {
+	// Path parts: "", "api", "rooms", {id}, "members"[, {uid}]
+	pathParts := strings.Split(r.URL.Path, "/")
+	if len(pathParts) < 5 || pathParts[3] == "" {
+		utils.BadRequest(w, "Missing room ID")
+		return
+	}
+	roomID := pathParts[3]
+
+	if len(pathParts) == 5 || (len(pathParts) == 6 && pathParts[5] == "") {
+		if r.Method != http.MethodGet {
+			utils.MethodNotAllowed(w)
+			return
+		}
+		mh.handleListMembers(w, r, roomID)
+		return
+	}
+	if len(pathParts) != 6 {
+		utils.NotFound(w, "Not found")
+		return
+	}
+	userUID := pathParts[5]
+
+	switch r.Method {
+	case http.MethodPut:
+		mh.handleSetMember(w, r, roomID, userUID)
+	case http.MethodDelete:
+		mh.handleRemoveMember(w, r, roomID, userUID)
+	default:
+		utils.MethodNotAllowed(w)
+	}
+}
+
+// handleListMembers lists who has access to a room
+func (mh *MemberHandler) handleListMembers(w http.ResponseWriter, r *http.Request, roomID string) {
+	if _, ok := authorizeRoom(w, r, mh.store, roomID, services.RoleViewer); !ok {
+		return
+	}
+
+	members, err := mh.store.GetRoomMembers(r.Context(), roomID)
+	if err != nil {
+		log.Printf("Failed to list members of room %s: %v", roomID, err)
+		utils.InternalServerError(w, "Failed to retrieve members")
+		return
+	}
+
+	utils.SuccessResponse(w, members)
+}
+
+// handleSetMember shares a room with a user or changes their role. Only
+// owners may do this.
+func (mh *MemberHandler) handleSetMember(w http.ResponseWriter, r *http.Request, roomID, userUID string) {
+	var req SetMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body")
+		return
+	}
+	if !services.ValidRole(req.Role) {
+		utils.BadRequest(w, "Role must be owner, editor or viewer")
+		return
+	}
+
+	if _, ok := authorizeRoom(w, r, mh.store, roomID, services.RoleOwner); !ok {
+		return
+	}
+
+	member, err := mh.store.SetRoomMember(r.Context(), roomID, userUID, req.Role)
+	if err != nil {
+		if errors.Is(err, services.ErrLastOwner) {
+			utils.ErrorResponse(w, http.StatusConflict, "A room must keep at least one owner")
+		} else {
+			log.Printf("Failed to set member %s of room %s: %v", userUID, roomID, err)
+			utils.InternalServerError(w, "Failed to update member")
+		}
+		return
+	}
+
+	mh.wsService.UpdateMemberRole(roomID, userUID, member.Role)
+	recordAudit(r, mh.store, services.AuditEvent{
+		RoomID: roomID,
+		Action: services.AuditShare,
+		Detail: userUID + " as " + member.Role,
+	})
+
+	utils.SuccessResponse(w, member)
+}
+
+// handleRemoveMember takes away a user's access to a room. Owners may remove
+// anyone; other members may only remove themselves.
+func (mh *MemberHandler) handleRemoveMember(w http.ResponseWriter, r *http.Request, roomID, userUID string) {
+	need := services.RoleOwner
+	if userUID == auth.CallerUID(r, r.URL.Query().Get("uid")) {
+		need = services.RoleViewer
+	}
+	if _, ok := authorizeRoom(w, r, mh.store, roomID, need); !ok {
+		return
+	}
+
+	if err := mh.store.RemoveRoomMember(r.Context(), roomID, userUID); err != nil {
+		switch {
+		case errors.Is(err, services.ErrLastOwner):
+			utils.ErrorResponse(w, http.StatusConflict, "A room must keep at least one owner")
+		case strings.Contains(err.Error(), "not found"):
+			utils.NotFound(w, "Member not found")
+		default:
+			log.Printf("Failed to remove member %s of room %s: %v", userUID, roomID, err)
+			utils.InternalServerError(w, "Failed to remove member")
+		}
+		return
+	}
+
+	mh.wsService.UpdateMemberRole(roomID, userUID, "")
+	recordAudit(r, mh.store, services.AuditEvent{
+		RoomID: roomID,
+		Action: services.AuditUnshare,
+		Detail: userUID,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -6,19 +6,22 @@ import (
 	"strings"
 
 	"github.com/google/uuid"
+	"github.com/logoes0/peeriodic.git/auth"
 	"github.com/logoes0/peeriodic.git/services"
 	"github.com/logoes0/peeriodic.git/utils"
 )
 
 // RoomHandler handles room-related HTTP requests
 type RoomHandler struct {
-	DBService *services.DatabaseService
+	Store     services.Store
+	wsService *services.WebSocketService
 }
 
 // NewRoomHandler creates a new room handler instance
-func NewRoomHandler(dbService *services.DatabaseService) *RoomHandler {
+func NewRoomHandler(store services.Store, wsService *services.WebSocketService) *RoomHandler {
 	return &RoomHandler{
-		DBService: dbService,
+		Store:     store,
+		wsService: wsService,
 	}
 }
 
@@ -37,6 +40,11 @@ type RoomResponse struct {
 	Content   string `json:"content,omitempty"`
 	UserUID   string `json:"user_uid,omitempty"`
 	CreatedAt string `json:"created_at,omitempty"`
+	UpdatedAt string `json:"updated_at,omitempty"`
+	DeletedAt string `json:"deleted_at,omitempty"`
+	Version   int64  `json:"version,omitempty"`
+	// Role is the caller's role in the room
+	Role string `json:"role,omitempty"`
 }
 
 // HandleRooms handles room listing and creation
@@ -51,35 +59,68 @@ func (rh *RoomHandler) HandleRooms(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// handleGetRooms retrieves rooms for a specific user
+// handleGetRooms retrieves a page of a user's rooms, or the rooms in their
+// trash with trashed=true
 func (rh *RoomHandler) handleGetRooms(w http.ResponseWriter, r *http.Request) {
-	uid := r.URL.Query().Get("uid")
+	uid := auth.CallerUID(r, r.URL.Query().Get("uid"))
 	if uid == "" {
 		utils.BadRequest(w, "Missing uid parameter")
 		return
 	}
 
-	rooms, err := rh.DBService.GetRoomsByUser(uid)
+	if r.URL.Query().Get("trashed") == "true" {
+		rooms, err := rh.Store.GetTrashedRoomsByUser(r.Context(), uid)
+		if err != nil {
+			utils.InternalServerError(w, "Failed to retrieve rooms")
+			return
+		}
+		utils.SuccessResponse(w, toRoomResponses(rooms))
+		return
+	}
+
+	opts, err := parseRoomListOptions(r.URL.Query())
+	if err != nil {
+		utils.BadRequest(w, "Invalid parameters: "+err.Error())
+		return
+	}
+
+	// One extra room tells whether there is a next page
+	limit := opts.Limit
+	opts.Limit++
+	rooms, err := rh.Store.ListRooms(r.Context(), uid, opts)
 	if err != nil {
 		utils.InternalServerError(w, "Failed to retrieve rooms")
 		return
 	}
 
-	// Convert to response format
-	var response []RoomResponse
+	var nextCursor string
+	if len(rooms) > limit {
+		rooms = rooms[:limit]
+		nextCursor = encodeListCursor(opts, rooms[limit-1])
+	}
+
+	utils.PageResponse(w, toRoomResponses(rooms), nextCursor)
+}
+
+// toRoomResponses converts listed rooms to the response format
+func toRoomResponses(rooms []*services.Room) []RoomResponse {
+	response := []RoomResponse{}
 	for _, room := range rooms {
 		roomResponse := RoomResponse{
 			ID:        room.ID,
 			Title:     room.Title,
 			CreatedAt: room.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+			UpdatedAt: room.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
 		}
 		if room.UserUID != nil {
 			roomResponse.UserUID = *room.UserUID
 		}
+		if room.DeletedAt != nil {
+			roomResponse.DeletedAt = room.DeletedAt.Format("2006-01-02T15:04:05Z07:00")
+		}
 		response = append(response, roomResponse)
 	}
-
-	utils.SuccessResponse(w, response)
+	return response
 }
 
 // handleCreateRoom creates a new room
@@ -90,6 +131,16 @@ func (rh *RoomHandler) handleCreateRoom(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// A verified caller can only create rooms for themselves
+	req.UID = auth.CallerUID(r, req.UID)
+	if identity, ok := auth.FromContext(r.Context()); ok {
+		if identity.Email != "" {
+			req.Email = identity.Email
+		}
+		if identity.Name != "" {
+			req.Name = identity.Name
+		}
+	}
 	if req.UID == "" {
 		utils.BadRequest(w, "UID is required")
 		return
@@ -102,7 +153,7 @@ func (rh *RoomHandler) handleCreateRoom(w http.ResponseWriter, r *http.Request)
 	// Ensure user exists
 	var userUID *string
 	if req.UID != "" {
-		user, err := rh.DBService.EnsureUserExists(req.UID, req.Email, req.Name)
+		user, err := rh.Store.EnsureUserExists(r.Context(), req.UID, req.Email, req.Name)
 		if err != nil {
 			utils.InternalServerError(w, "Failed to create user")
 			return
@@ -111,11 +162,17 @@ func (rh *RoomHandler) handleCreateRoom(w http.ResponseWriter, r *http.Request)
 	}
 
 	roomID := uuid.New().String()
-	room, err := rh.DBService.CreateRoom(roomID, req.Title, userUID)
+	room, err := rh.Store.CreateRoom(r.Context(), roomID, req.Title, userUID)
 	if err != nil {
 		utils.InternalServerError(w, "Failed to create room")
 		return
 	}
+	recordAudit(r, rh.Store, services.AuditEvent{
+		RoomID:   room.ID,
+		Action:   services.AuditCreate,
+		ActorUID: req.UID,
+		Detail:   room.Title,
+	})
 
 	response := RoomResponse{
 		ID:    room.ID,
@@ -128,7 +185,8 @@ func (rh *RoomHandler) handleCreateRoom(w http.ResponseWriter, r *http.Request)
 	utils.SuccessResponse(w, response)
 }
 
-// HandleRoomByID handles getting a specific room by ID
+// HandleRoomByID handles getting a specific room by ID, to members or holders
+// of a share link
 func (rh *RoomHandler) HandleRoomByID(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		utils.MethodNotAllowed(w)
@@ -148,7 +206,12 @@ func (rh *RoomHandler) HandleRoomByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	room, err := rh.DBService.GetRoom(roomID)
+	role, ok := authorizeRoomWithLink(w, r, rh.Store, roomID, services.RoleViewer)
+	if !ok {
+		return
+	}
+
+	room, err := rh.Store.GetRoom(r.Context(), roomID)
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			utils.NotFound(w, "Room not found")
@@ -158,10 +221,19 @@ func (rh *RoomHandler) HandleRoomByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	etag := roomETag(room.Version)
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
 	response := RoomResponse{
 		ID:      room.ID,
 		Title:   room.Title,
 		Content: room.Content,
+		Version: room.Version,
+		Role:    role,
 	}
 	if room.UserUID != nil {
 		response.UserUID = *room.UserUID
@@ -170,7 +242,7 @@ func (rh *RoomHandler) HandleRoomByID(w http.ResponseWriter, r *http.Request) {
 	utils.SuccessResponse(w, response)
 }
 
-// HandleDeleteRoom handles room deletion
+// HandleDeleteRoom moves a room to the trash and disconnects its editors
 func (rh *RoomHandler) HandleDeleteRoom(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
 		utils.MethodNotAllowed(w)
@@ -190,7 +262,11 @@ func (rh *RoomHandler) HandleDeleteRoom(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err := rh.DBService.DeleteRoom(roomID)
+	if _, ok := authorizeRoom(w, r, rh.Store, roomID, services.RoleOwner); !ok {
+		return
+	}
+
+	err := rh.Store.DeleteRoom(r.Context(), roomID)
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			utils.NotFound(w, "Room not found")
@@ -200,5 +276,55 @@ func (rh *RoomHandler) HandleDeleteRoom(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	rh.wsService.CloseRoom(roomID)
+	recordAudit(r, rh.Store, services.AuditEvent{RoomID: roomID, Action: services.AuditDelete})
+
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// HandleRestoreRoom takes a room back out of the trash
+func (rh *RoomHandler) HandleRestoreRoom(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.MethodNotAllowed(w)
+		return
+	}
+
+	// Path parts: "", "api", "rooms", {id}, "restore"
+	pathParts := strings.Split(r.URL.Path, "/")
+	if len(pathParts) != 5 || pathParts[3] == "" {
+		utils.BadRequest(w, "Invalid room ID")
+		return
+	}
+	roomID := pathParts[3]
+
+	if _, ok := authorizeRoom(w, r, rh.Store, roomID, services.RoleOwner); !ok {
+		return
+	}
+
+	if err := rh.Store.RestoreRoom(r.Context(), roomID); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			utils.NotFound(w, "Room not found in trash")
+		} else {
+			utils.InternalServerError(w, "Failed to restore room")
+		}
+		return
+	}
+	recordAudit(r, rh.Store, services.AuditEvent{RoomID: roomID, Action: services.AuditRestore})
+
+	room, err := rh.Store.GetRoom(r.Context(), roomID)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to retrieve room")
+		return
+	}
+
+	response := RoomResponse{
+		ID:        room.ID,
+		Title:     room.Title,
+		CreatedAt: room.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+	if room.UserUID != nil {
+		response.UserUID = *room.UserUID
+	}
+
+	utils.SuccessResponse(w, response)
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/logoes0/peeriodic.git/services"
+)
+
+// Room list page sizes
+const (
+	defaultRoomListLimit = 50
+	maxRoomListLimit     = 200
+)
+
+// listCursor is the decoded form of the opaque cursor handed to clients. It
+// remembers the sort it belongs to, so it cannot be replayed against another.
+type listCursor struct {
+	Sort       string `json:"s"`
+	Descending bool   `json:"d,omitempty"`
+	services.RoomCursor
+}
+
+// parseRoomListOptions reads the paging, sorting and filtering parameters of
+// GET /api/rooms
+func parseRoomListOptions(query url.Values) (services.RoomListOptions, error) {
+	opts := services.RoomListOptions{Limit: defaultRoomListLimit}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxRoomListLimit {
+			return opts, fmt.Errorf("limit must be between 1 and %d", maxRoomListLimit)
+		}
+		opts.Limit = limit
+	}
+
+	opts.Sort = query.Get("sort")
+	switch opts.Sort {
+	case "":
+		opts.Sort = services.RoomSortUpdated
+		opts.Descending = true
+	case services.RoomSortUpdated, services.RoomSortCreated:
+		opts.Descending = true
+	case services.RoomSortTitle:
+	default:
+		return opts, errors.New("sort must be updated, created or title")
+	}
+
+	switch query.Get("order") {
+	case "":
+	case "asc":
+		opts.Descending = false
+	case "desc":
+		opts.Descending = true
+	default:
+		return opts, errors.New("order must be asc or desc")
+	}
+
+	opts.TitlePrefix = query.Get("title_prefix")
+
+	for _, bound := range []struct {
+		name  string
+		value *time.Time
+	}{
+		{"created_after", &opts.CreatedAfter},
+		{"created_before", &opts.CreatedBefore},
+		{"updated_after", &opts.UpdatedAfter},
+		{"updated_before", &opts.UpdatedBefore},
+	} {
+		if value := query.Get(bound.name); value != "" {
+			t, err := parseListTime(value)
+			if err != nil {
+				return opts, fmt.Errorf("%s must be an RFC 3339 time or a date", bound.name)
+			}
+			*bound.value = t
+		}
+	}
+
+	if value := query.Get("cursor"); value != "" {
+		cursor, err := decodeListCursor(value)
+		if err != nil || cursor.Sort != opts.Sort || cursor.Descending != opts.Descending {
+			return opts, errors.New("cursor is malformed or belongs to another sort")
+		}
+		opts.After = &cursor.RoomCursor
+	}
+
+	return opts, nil
+}
+
+// parseListTime accepts an RFC 3339 time or a date, which means midnight UTC
+func parseListTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
+
+// encodeListCursor returns the cursor of the page after room
+func encodeListCursor(opts services.RoomListOptions, room *services.Room) string {
+	data, _ := json.Marshal(listCursor{
+		Sort:       opts.Sort,
+		Descending: opts.Descending,
+		RoomCursor: *opts.CursorFor(room),
+	})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeListCursor reverses encodeListCursor
+func decodeListCursor(value string) (*listCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	var cursor listCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	if cursor.ID == "" {
+		return nil, errors.New("cursor without room ID")
+	}
+	return &cursor, nil
+}
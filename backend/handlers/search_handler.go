@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/logoes0/peeriodic.git/auth"
+	"github.com/logoes0/peeriodic.git/services"
+	"github.com/logoes0/peeriodic.git/utils"
+)
+
+// SearchHandler handles full-text search over rooms
+type SearchHandler struct {
+	store services.Store
+}
+
+// NewSearchHandler creates a new search handler instance
+func NewSearchHandler(store services.Store) *SearchHandler {
+	return &SearchHandler{
+		store: store,
+	}
+}
+
+// Search limits
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+	maxSearchQuery     = 256
+)
+
+// HandleSearch handles GET /api/search?uid=..&q=..[&limit=..]
+func (sh *SearchHandler) HandleSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.MethodNotAllowed(w)
+		return
+	}
+
+	query := r.URL.Query()
+	uid := auth.CallerUID(r, query.Get("uid"))
+	if uid == "" {
+		utils.BadRequest(w, "Missing uid parameter")
+		return
+	}
+	q := strings.TrimSpace(query.Get("q"))
+	if q == "" {
+		utils.BadRequest(w, "Missing q parameter")
+		return
+	}
+	if len(q) > maxSearchQuery {
+		utils.BadRequest(w, "Search query too long")
+		return
+	}
+
+	limit := defaultSearchLimit
+	if value := query.Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > maxSearchLimit {
+			utils.BadRequest(w, "Invalid limit parameter")
+			return
+		}
+	}
+
+	results, err := sh.store.SearchRooms(r.Context(), uid, q, limit)
+	if err != nil {
+		log.Printf("Failed to search rooms of %s: %v", uid, err)
+		utils.InternalServerError(w, "Failed to search rooms")
+		return
+	}
+	if results == nil {
+		results = []*services.SearchResult{}
+	}
+
+	utils.SuccessResponse(w, results)
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/logoes0/peeriodic.git/services"
+	"github.com/logoes0/peeriodic.git/utils"
+)
+
+// StatsHandler handles requests for live connection statistics
+type StatsHandler struct {
+	wsService *services.WebSocketService
+}
+
+// NewStatsHandler creates a new stats handler instance
+func NewStatsHandler(wsService *services.WebSocketService) *StatsHandler {
+	return &StatsHandler{
+		wsService: wsService,
+	}
+}
+
+// StatsResponse represents the response for the stats endpoint
+type StatsResponse struct {
+	Rooms      map[string]int          `json:"rooms"`
+	Violations services.ViolationStats `json:"violations"`
+}
+
+// HandleStats returns the connected clients per room and the limit violations
+func (sh *StatsHandler) HandleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.MethodNotAllowed(w)
+		return
+	}
+
+	utils.SuccessResponse(w, StatsResponse{
+		Rooms:      sh.wsService.GetRoomStats(),
+		Violations: sh.wsService.GetViolationStats(),
+	})
+}
@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/logoes0/peeriodic.git/diff"
+	"github.com/logoes0/peeriodic.git/services"
+	"github.com/logoes0/peeriodic.git/utils"
+)
+
+// VersionHandler handles room version history requests
+type VersionHandler struct {
+	store     services.Store
+	wsService *services.WebSocketService
+}
+
+// NewVersionHandler creates a new version handler instance
+func NewVersionHandler(store services.Store, wsService *services.WebSocketService) *VersionHandler {
+	return &VersionHandler{
+		store:     store,
+		wsService: wsService,
+	}
+}
+
+// RestoreVersionResponse represents the response for restoring a version
+type RestoreVersionResponse struct {
+	RoomID  string `json:"roomId"`
+	Version int    `json:"version"`
+	// Backup is the version created to hold the content replaced by the
+	// restore. It is omitted when that content already was the latest version.
+	Backup *int `json:"backup,omitempty"`
+	Live   bool `json:"live"`
+}
+
+// DiffResponse represents the response for the diff endpoint
+type DiffResponse struct {
+	From    string      `json:"from"`
+	To      string      `json:"to"`
+	Unified string      `json:"unified"`
+	Hunks   []diff.Hunk `json:"hunks"`
+	// Exact is false when the documents differed too much for a minimal diff
+	Exact bool `json:"exact"`
+}
+
+// defaultDiffContext is the number of unchanged lines shown around changes
+const defaultDiffContext = 3
+
+// HandleVersions routes /api/rooms/{id}/versions[/{v}[/restore]]
+func (vh *VersionHandler) HandleVersions(w http.ResponseWriter, r *http.Request) {
+	// Path parts: "", "api", "rooms", {id}, "versions", {v}, "restore"
+	pathParts := strings.Split(strings.TrimSuffix(r.URL.Path, "/"), "/")
+	if len(pathParts) < 5 || pathParts[3] == "" {
+		utils.BadRequest(w, "Missing room ID")
+		return
+	}
+	roomID := pathParts[3]
+
+	// Reading history needs a viewer, restoring it an editor
+	need := services.RoleViewer
+	if r.Method != http.MethodGet {
+		need = services.RoleEditor
+	}
+	if _, ok := authorizeRoom(w, r, vh.store, roomID, need); !ok {
+		return
+	}
+
+	if len(pathParts) == 5 {
+		vh.handleListVersions(w, r, roomID)
+		return
+	}
+
+	version, err := strconv.Atoi(pathParts[5])
+	if err != nil || version < 1 {
+		utils.BadRequest(w, "Invalid version")
+		return
+	}
+
+	switch {
+	case len(pathParts) == 6:
+		vh.handleGetVersion(w, r, roomID, version)
+	case len(pathParts) == 7 && pathParts[6] == "restore":
+		vh.handleRestoreVersion(w, r, roomID, version)
+	default:
+		utils.NotFound(w, "Not found")
+	}
+}
+
+// handleListVersions lists a room's versions without their content
+func (vh *VersionHandler) handleListVersions(w http.ResponseWriter, r *http.Request, roomID string) {
+	if r.Method != http.MethodGet {
+		utils.MethodNotAllowed(w)
+		return
+	}
+
+	if _, err := vh.store.GetRoom(r.Context(), roomID); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			utils.NotFound(w, "Room not found")
+		} else {
+			utils.InternalServerError(w, "Failed to retrieve room")
+		}
+		return
+	}
+
+	versions, err := vh.store.GetRoomVersions(r.Context(), roomID)
+	if err != nil {
+		log.Printf("Failed to list versions of room %s: %v", roomID, err)
+		utils.InternalServerError(w, "Failed to retrieve versions")
+		return
+	}
+
+	utils.SuccessResponse(w, versions)
+}
+
+// handleGetVersion returns one version including its content
+func (vh *VersionHandler) handleGetVersion(w http.ResponseWriter, r *http.Request, roomID string, version int) {
+	if r.Method != http.MethodGet {
+		utils.MethodNotAllowed(w)
+		return
+	}
+
+	v, err := vh.store.GetRoomVersion(r.Context(), roomID, version)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			utils.NotFound(w, "Version not found")
+		} else {
+			utils.InternalServerError(w, "Failed to retrieve version")
+		}
+		return
+	}
+
+	utils.SuccessResponse(w, v)
+}
+
+// handleRestoreVersion makes a version the room's current content. The
+// content being replaced is saved as a new version first, so a restore can
+// itself be undone.
+func (vh *VersionHandler) handleRestoreVersion(w http.ResponseWriter, r *http.Request, roomID string, version int) {
+	if r.Method != http.MethodPost {
+		utils.MethodNotAllowed(w)
+		return
+	}
+
+	v, err := vh.store.GetRoomVersion(r.Context(), roomID, version)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			utils.NotFound(w, "Version not found")
+		} else {
+			utils.InternalServerError(w, "Failed to retrieve version")
+		}
+		return
+	}
+
+	// Rooms with connected clients hold the latest content in memory
+	current, live := vh.wsService.GetDocument(roomID)
+	if !live {
+		room, err := vh.store.GetRoom(r.Context(), roomID)
+		if err != nil {
+			utils.InternalServerError(w, "Failed to retrieve room")
+			return
+		}
+		current = room.Content
+	}
+
+	backup, err := vh.store.CreateRoomVersion(r.Context(), roomID, current)
+	if err != nil {
+		log.Printf("Failed to keep current content of room %s: %v", roomID, err)
+		utils.InternalServerError(w, "Failed to restore version")
+		return
+	}
+	response := RestoreVersionResponse{RoomID: roomID, Version: version}
+	if backup != nil {
+		response.Backup = &backup.Version
+	}
+
+	// Live rooms push the restored text to their clients
+	response.Live, err = vh.wsService.ReplaceDocument(roomID, v.Content)
+	if err == nil && !response.Live {
+		err = vh.store.UpdateRoomContent(r.Context(), roomID, v.Content, nil)
+	}
+	if err != nil {
+		log.Printf("Failed to restore version %d of room %s: %v", version, roomID, err)
+		utils.InternalServerError(w, "Failed to restore version")
+		return
+	}
+
+	log.Printf("Restored version %d of room %s (live: %v)", version, roomID, response.Live)
+	recordAudit(r, vh.store, services.AuditEvent{
+		RoomID: roomID,
+		Action: services.AuditSave,
+		Detail: fmt.Sprintf("restored version %d", version),
+	})
+	utils.SuccessResponse(w, response)
+}
+
+// HandleDiff handles GET /api/rooms/{id}/diff?from=..&to=..[&context=..].
+// from and to are version numbers or "live" for the current document; to
+// defaults to "live".
+func (vh *VersionHandler) HandleDiff(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.MethodNotAllowed(w)
+		return
+	}
+
+	pathParts := strings.Split(r.URL.Path, "/")
+	if len(pathParts) < 4 || pathParts[3] == "" {
+		utils.BadRequest(w, "Missing room ID")
+		return
+	}
+	roomID := pathParts[3]
+
+	if _, ok := authorizeRoom(w, r, vh.store, roomID, services.RoleViewer); !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	from, to := query.Get("from"), query.Get("to")
+	if from == "" {
+		utils.BadRequest(w, "Missing from parameter")
+		return
+	}
+	if to == "" {
+		to = "live"
+	}
+
+	context := defaultDiffContext
+	if value := query.Get("context"); value != "" {
+		var err error
+		if context, err = strconv.Atoi(value); err != nil || context < 0 || context > 100 {
+			utils.BadRequest(w, "Invalid context parameter")
+			return
+		}
+	}
+
+	oldContent, ok := vh.resolveContent(w, r, roomID, from)
+	if !ok {
+		return
+	}
+	newContent, ok := vh.resolveContent(w, r, roomID, to)
+	if !ok {
+		return
+	}
+
+	result := diff.Compute(oldContent, newContent, context)
+	utils.SuccessResponse(w, DiffResponse{
+		From:    from,
+		To:      to,
+		Unified: diff.Unified(diffLabel(roomID, from), diffLabel(roomID, to), result.Hunks),
+		Hunks:   result.Hunks,
+		Exact:   result.Exact,
+	})
+}
+
+// resolveContent returns the content of a version, or of the current
+// document for "live". It writes the error response and returns false when
+// the content cannot be found.
+func (vh *VersionHandler) resolveContent(w http.ResponseWriter, r *http.Request, roomID, ref string) (string, bool) {
+	if ref == "live" {
+		if content, live := vh.wsService.GetDocument(roomID); live {
+			return content, true
+		}
+		room, err := vh.store.GetRoom(r.Context(), roomID)
+		if err != nil {
+			if strings.Contains(err.Error(), "not found") {
+				utils.NotFound(w, "Room not found")
+			} else {
+				utils.InternalServerError(w, "Failed to retrieve room")
+			}
+			return "", false
+		}
+		return room.Content, true
+	}
+
+	version, err := strconv.Atoi(ref)
+	if err != nil || version < 1 {
+		utils.BadRequest(w, "Invalid version: "+ref)
+		return "", false
+	}
+	v, err := vh.store.GetRoomVersion(r.Context(), roomID, version)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			utils.NotFound(w, "Version not found: "+ref)
+		} else {
+			utils.InternalServerError(w, "Failed to retrieve version")
+		}
+		return "", false
+	}
+	return v.Content, true
+}
+
+// diffLabel names one side of a diff in the unified output
+func diffLabel(roomID, ref string) string {
+	if ref == "live" {
+		return roomID + "@live"
+	}
+	return roomID + "@v" + ref
+}
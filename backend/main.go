@@ -2,15 +2,20 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
+	"text/tabwriter"
 	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/logoes0/peeriodic.git/auth"
 	"github.com/logoes0/peeriodic.git/config"
+	"github.com/logoes0/peeriodic.git/migrations"
 	"github.com/logoes0/peeriodic.git/routers"
 	"github.com/logoes0/peeriodic.git/services"
 )
@@ -21,28 +26,72 @@ func main() {
 		log.Println("⚠️  .env file not found, using system environment variables")
 	}
 
+	// `migrate up|down [steps]|status` manages the schema and exits. It only
+	// needs the database settings.
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		cfg, err := config.LoadDatabase()
+		if err != nil {
+			log.Fatalf("❌ Failed to load configuration: %v", err)
+		}
+		runMigrate(cfg, os.Args[2:])
+		return
+	}
+
+	// `rooms claim <room> <uid>` gives a room without members an owner and
+	// exits
+	if len(os.Args) > 1 && os.Args[1] == "rooms" {
+		cfg, err := config.LoadDatabase()
+		if err != nil {
+			log.Fatalf("❌ Failed to load configuration: %v", err)
+		}
+		runRooms(cfg, os.Args[2:])
+		return
+	}
+
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
 		log.Fatalf("❌ Failed to load configuration: %v", err)
 	}
 
-	// Initialize database service
-	dbService, err := services.NewDatabaseService(cfg)
+	// Initialize storage
+	store, err := services.NewStore(cfg)
 	if err != nil {
-		log.Fatalf("❌ Failed to initialize database service: %v", err)
+		log.Fatalf("❌ Failed to initialize %s store: %v", cfg.Database.Driver, err)
 	}
 	defer func() {
-		if err := dbService.Close(); err != nil {
+		if err := store.Close(); err != nil {
 			log.Printf("⚠️  Failed to close database connection: %v", err)
 		}
 	}()
 
+	// Permanently delete rooms that have been in the trash too long
+	purgeCtx, stopPurge := context.WithCancel(context.Background())
+	purgeDone := make(chan struct{})
+	go func() {
+		defer close(purgeDone)
+		services.RunTrashPurge(purgeCtx, store, cfg.Trash)
+	}()
+
+	// Rate limit and connection counts are kept in process
+	limiter := services.NewMemoryLimiter()
+
 	// Initialize WebSocket service
-	wsService := services.NewWebSocketService(cfg)
+	wsService := services.NewWebSocketService(cfg, limiter)
+
+	// Initialize token verification
+	verifier, err := auth.NewVerifier(cfg.Auth)
+	if err != nil {
+		log.Fatalf("❌ Failed to initialize authentication: %v", err)
+	}
+	if verifier == nil {
+		log.Println("⚠️⚠️⚠️ AUTHENTICATION IS DISABLED (AUTH_DISABLED=true) ⚠️⚠️⚠️")
+		log.Println("⚠️  Anyone can connect as any user, including as a room's owner to delete it or share it.")
+		log.Println("⚠️  Set AUTH_JWT_SECRET or AUTH_JWKS_FILE instead before exposing this server.")
+	}
 
 	// Initialize router
-	router := routers.NewRouter(dbService, wsService)
+	router := routers.NewRouter(cfg, store, wsService, verifier, limiter)
 	router.SetupRoutes()
 
 	// Create HTTP server
@@ -77,5 +126,98 @@ func main() {
 		log.Printf("⚠️  Server forced to shutdown: %v", err)
 	}
 
+	// WebSocket connections are hijacked and not tracked by the HTTP server,
+	// so close them and save the live rooms before the database is closed
+	if err := wsService.Shutdown(ctx); err != nil {
+		log.Printf("⚠️  Live rooms not fully saved: %v", err)
+	}
+
+	stopPurge()
+	<-purgeDone
+
 	log.Println("✅ Server exited gracefully")
 }
+
+// runMigrate runs a migration command against the configured database
+func runMigrate(cfg *config.Config, args []string) {
+	if len(args) == 0 {
+		log.Fatalf("❌ Usage: migrate up | down [steps] | status")
+	}
+
+	db, err := services.OpenDatabase(cfg)
+	if err != nil {
+		log.Fatalf("❌ Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	migrator, err := migrations.New(db, cfg.Database.Driver)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			log.Fatalf("❌ Migration failed: %v", err)
+		}
+		log.Printf("✅ Applied %d migrations, schema is at version %d", len(applied), migrator.Latest())
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				log.Fatalf("❌ Invalid number of steps: %s", args[1])
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		if err != nil {
+			log.Fatalf("❌ Rollback failed: %v", err)
+		}
+		log.Printf("✅ Rolled back %d migrations", len(reverted))
+
+	case "status":
+		status, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatalf("❌ Failed to read migration status: %v", err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+		for _, m := range status.Migrations {
+			applied := "pending"
+			if m.AppliedAt != nil {
+				applied = m.AppliedAt.Format(time.RFC3339)
+			}
+			if !m.Known {
+				applied += " (unknown to this binary)"
+			}
+			fmt.Fprintf(w, "%03d\t%s\t%s\n", m.Version, m.Name, applied)
+		}
+		w.Flush()
+		fmt.Printf("\nSchema version %d, binary version %d, %d pending\n", status.Current, status.Latest, status.Pending())
+
+	default:
+		log.Fatalf("❌ Unknown migrate command %q, expected up, down or status", args[0])
+	}
+}
+
+// runRooms runs a room administration command against the configured store
+func runRooms(cfg *config.Config, args []string) {
+	if len(args) != 3 || args[0] != "claim" {
+		log.Fatalf("❌ Usage: rooms claim <room-id> <uid>")
+	}
+	roomID, uid := args[1], args[2]
+
+	store, err := services.NewStore(cfg)
+	if err != nil {
+		log.Fatalf("❌ Failed to initialize %s store: %v", cfg.Database.Driver, err)
+	}
+	defer store.Close()
+
+	if err := services.ClaimRoom(context.Background(), store, roomID, uid); err != nil {
+		log.Fatalf("❌ Failed to claim room %s: %v", roomID, err)
+	}
+	log.Printf("✅ %s now owns room %s", uid, roomID)
+}
@@ -1,32 +1,59 @@
 package middleware
 
 import (
+	"log"
 	"net/http"
+
+	"github.com/logoes0/peeriodic.git/config"
+	"github.com/logoes0/peeriodic.git/utils"
 )
 
-// CORS middleware handles Cross-Origin Resource Sharing headers
-func CORS(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		// Set CORS headers
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		w.Header().Set("Access-Control-Max-Age", "86400") // 24 hours
-
-		// Handle preflight requests
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
+// CORS returns middleware that handles Cross-Origin Resource Sharing headers
+// for the configured origins. Requests from other origins are refused.
+func CORS(cfg config.CORSConfig) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Origin")
+
+			// Requests without an Origin do not come from a browser page
+			origin := r.Header.Get("Origin")
+			if origin != "" {
+				if !cfg.AllowsOrigin(origin) {
+					log.Printf("🚫 Rejected %s %s from origin %q (%s)", r.Method, r.URL.Path, origin, r.RemoteAddr)
+					utils.Forbidden(w, "Origin not allowed")
+					return
+				}
+
+				// Set CORS headers
+				if cfg.AllowsAnyOrigin() {
+					w.Header().Set("Access-Control-Allow-Origin", "*")
+				} else {
+					w.Header().Set("Access-Control-Allow-Origin", origin)
+				}
+				if cfg.AllowCredentials {
+					w.Header().Set("Access-Control-Allow-Credentials", "true")
+				}
+				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+				w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, If-Match, If-None-Match")
+				w.Header().Set("Access-Control-Expose-Headers", "ETag, Retry-After")
+				w.Header().Set("Access-Control-Max-Age", "86400") // 24 hours
+			}
 
-		next(w, r)
+			// Handle preflight requests
+			if r.Method == "OPTIONS" {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+
+			next(w, r)
+		}
 	}
 }
 
 // CORSWrapper wraps a handler with CORS headers
-func CORSWrapper(handler http.Handler) http.Handler {
+func CORSWrapper(cfg config.CORSConfig, handler http.Handler) http.Handler {
+	cors := CORS(cfg)
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		CORS(handler.ServeHTTP)(w, r)
+		cors(handler.ServeHTTP)(w, r)
 	})
 }
-
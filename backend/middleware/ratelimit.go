@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/logoes0/peeriodic.git/auth"
+	"github.com/logoes0/peeriodic.git/config"
+	"github.com/logoes0/peeriodic.git/services"
+	"github.com/logoes0/peeriodic.git/utils"
+)
+
+// RateLimitIP returns middleware that limits requests per minute from each
+// client IP. It goes before authentication, so callers with bad tokens are
+// limited too. Refused requests get 429 with Retry-After. If the limiter
+// fails, requests are let through rather than taking the API down with it.
+func RateLimitIP(limiter services.Limiter, cfg config.RateLimitConfig) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if !allowRequest(w, r, limiter, "ip:"+services.RemoteHost(r), cfg.IPRequestsPerMinute) {
+				return
+			}
+			next(w, r)
+		}
+	}
+}
+
+// RateLimitUser returns middleware that limits requests per minute from each
+// verified user, like RateLimitIP. It goes after authentication; requests
+// without a verified caller pass.
+func RateLimitUser(limiter services.Limiter, cfg config.RateLimitConfig) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if identity, ok := auth.FromContext(r.Context()); ok {
+				if !allowRequest(w, r, limiter, "uid:"+identity.UID, cfg.UserRequestsPerMinute) {
+					return
+				}
+			}
+			next(w, r)
+		}
+	}
+}
+
+// allowRequest counts a request against key, answering it with 429 when the
+// limit is used up
+func allowRequest(w http.ResponseWriter, r *http.Request, limiter services.Limiter, key string, perMinute int) bool {
+	if perMinute <= 0 {
+		return true
+	}
+
+	allowed, retryAfter, err := limiter.Allow(r.Context(), key, perMinute, time.Minute)
+	if err != nil {
+		log.Printf("⚠️ Rate limiter failed for %s, allowing request: %v", key, err)
+		return true
+	}
+	if !allowed {
+		log.Printf("🚫 Rate limited %s %s for %s", r.Method, r.URL.Path, key)
+		utils.TooManyRequests(w, "Too many requests", retryAfter)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,409 @@
+// Package migrations holds the database schema as numbered SQL files embedded
+// in the binary and applies them, recording each applied version in the
+// schema_migrations table.
+//
+// Every dialect has its own directory of NNN_name.up.sql and NNN_name.down.sql
+// pairs. Versions only ever grow; a new schema change is a new pair of files.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed postgres/*.sql sqlite/*.sql
+var files embed.FS
+
+// Supported dialects, named after the DB_DRIVER that uses them
+const (
+	DialectPostgres = "postgres"
+	DialectSQLite   = "sqlite"
+)
+
+// lockKey identifies the PostgreSQL advisory lock held while migrating
+const lockKey int64 = 0x706565726f64
+
+// ErrSchemaTooNew is returned when the database has migrations applied that
+// this binary does not know about, i.e. it was migrated by a newer release
+var ErrSchemaTooNew = errors.New("database schema is newer than this binary")
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+const createTable = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	)
+`
+
+// Migration is one schema change
+type Migration struct {
+	Version int
+	Name    string
+	up      string
+	down    string
+}
+
+// String returns the migration's file name without direction and extension
+func (m Migration) String() string {
+	return fmt.Sprintf("%03d_%s", m.Version, m.Name)
+}
+
+// MigrationStatus describes one migration and whether it has been applied.
+// Known is false for versions recorded in the database but missing from the
+// binary.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Known     bool
+	AppliedAt *time.Time
+}
+
+// Status is the state of a database's schema
+type Status struct {
+	Current    int
+	Latest     int
+	Migrations []MigrationStatus
+}
+
+// Pending returns the number of known migrations not yet applied
+func (s *Status) Pending() int {
+	pending := 0
+	for _, m := range s.Migrations {
+		if m.Known && m.AppliedAt == nil {
+			pending++
+		}
+	}
+	return pending
+}
+
+// Migrator applies the embedded migrations of one dialect to a database
+type Migrator struct {
+	db         *sql.DB
+	dialect    string
+	migrations []Migration
+}
+
+// New creates a migrator for db using the migrations of dialect
+func New(db *sql.DB, dialect string) (*Migrator, error) {
+	if dialect != DialectPostgres && dialect != DialectSQLite {
+		return nil, fmt.Errorf("no migrations for dialect: %s", dialect)
+	}
+
+	migrations, err := load(dialect)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s migrations: %w", dialect, err)
+	}
+
+	return &Migrator{db: db, dialect: dialect, migrations: migrations}, nil
+}
+
+// load reads and pairs a dialect's migration files in version order
+func load(dialect string) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected migration file name: %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+
+		content, err := files.ReadFile(path.Join(dialect, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.up = string(content)
+		} else {
+			m.down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" || m.down == "" {
+			return nil, fmt.Errorf("migration %s needs both an up and a down file", m)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Latest returns the highest version the binary knows
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up applies all pending migrations in order and returns them. Nothing is
+// applied when the database schema is newer than the binary.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.locked(ctx, func(q querier) error {
+		done, err := m.applied(ctx, q)
+		if err != nil {
+			return err
+		}
+		if err := m.checkKnown(done); err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+			if _, err := q.ExecContext(ctx, migration.up); err != nil {
+				return fmt.Errorf("failed to apply migration %s: %w", migration, err)
+			}
+			if _, err := q.ExecContext(ctx, m.bind("INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)"),
+				migration.Version, migration.Name, time.Now().UTC()); err != nil {
+				return fmt.Errorf("failed to record migration %s: %w", migration, err)
+			}
+			log.Printf("🗃️ Applied migration %s", migration)
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+// Down rolls back the given number of most recently applied migrations and
+// returns them in the order they were rolled back
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := m.locked(ctx, func(q querier) error {
+		done, err := m.applied(ctx, q)
+		if err != nil {
+			return err
+		}
+		if err := m.checkKnown(done); err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := done[migration.Version]; !ok {
+				continue
+			}
+			if _, err := q.ExecContext(ctx, migration.down); err != nil {
+				return fmt.Errorf("failed to roll back migration %s: %w", migration, err)
+			}
+			if _, err := q.ExecContext(ctx, m.bind("DELETE FROM schema_migrations WHERE version = $1"), migration.Version); err != nil {
+				return fmt.Errorf("failed to unrecord migration %s: %w", migration, err)
+			}
+			log.Printf("↩️ Rolled back migration %s", migration)
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reverted, nil
+}
+
+// Status reports which migrations have been applied
+func (m *Migrator) Status(ctx context.Context) (*Status, error) {
+	var done map[int]appliedMigration
+	err := m.locked(ctx, func(q querier) error {
+		var err error
+		done, err = m.applied(ctx, q)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	status := &Status{Latest: m.Latest()}
+	for _, migration := range m.migrations {
+		ms := MigrationStatus{Version: migration.Version, Name: migration.Name, Known: true}
+		if a, ok := done[migration.Version]; ok {
+			ms.AppliedAt = &a.appliedAt
+			delete(done, migration.Version)
+		}
+		status.Migrations = append(status.Migrations, ms)
+	}
+	for version, a := range done {
+		status.Migrations = append(status.Migrations, MigrationStatus{Version: version, Name: a.name, AppliedAt: &a.appliedAt})
+	}
+	sort.Slice(status.Migrations, func(i, j int) bool {
+		return status.Migrations[i].Version < status.Migrations[j].Version
+	})
+
+	for _, ms := range status.Migrations {
+		if ms.AppliedAt != nil {
+			status.Current = max(status.Current, ms.Version)
+		}
+	}
+	return status, nil
+}
+
+// Check verifies the database is at exactly the binary's schema version
+// without changing it
+func (m *Migrator) Check(ctx context.Context) error {
+	status, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	for _, ms := range status.Migrations {
+		if !ms.Known {
+			return fmt.Errorf("%w: migration %03d_%s is not part of this binary", ErrSchemaTooNew, ms.Version, ms.Name)
+		}
+	}
+	if pending := status.Pending(); pending > 0 {
+		return fmt.Errorf("database schema is out of date: %d pending migrations, run `migrate up`", pending)
+	}
+	return nil
+}
+
+// checkKnown refuses to touch a database migrated by a newer binary
+func (m *Migrator) checkKnown(done map[int]appliedMigration) error {
+	known := make(map[int]bool, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+	}
+	for version, a := range done {
+		if !known[version] {
+			return fmt.Errorf("%w: migration %03d_%s is not part of this binary", ErrSchemaTooNew, version, a.name)
+		}
+	}
+	return nil
+}
+
+type appliedMigration struct {
+	name      string
+	appliedAt time.Time
+}
+
+// applied returns the versions recorded in schema_migrations
+func (m *Migrator) applied(ctx context.Context, q querier) (map[int]appliedMigration, error) {
+	if _, err := q.ExecContext(ctx, createTable); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	rows, err := q.QueryContext(ctx, "SELECT version, name, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	done := make(map[int]appliedMigration)
+	for rows.Next() {
+		var version int
+		var a appliedMigration
+		if err := rows.Scan(&version, &a.name, &a.appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan applied migration: %w", err)
+		}
+		done[version] = a
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating applied migrations: %w", err)
+	}
+	return done, nil
+}
+
+// querier is the part of *sql.Tx and *sql.Conn the migrator uses
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// locked runs fn in a single transaction that no other instance can run at
+// the same time. PostgreSQL takes a transaction-scoped advisory lock; SQLite
+// takes the database write lock up front with BEGIN IMMEDIATE.
+func (m *Migrator) locked(ctx context.Context, fn func(q querier) error) error {
+	if m.dialect == DialectSQLite {
+		return m.lockedSQLite(ctx, fn)
+	}
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin migration transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var acquired bool
+	if err := tx.QueryRowContext(ctx, "SELECT pg_try_advisory_xact_lock($1)", lockKey).Scan(&acquired); err != nil {
+		return fmt.Errorf("failed to take migration lock: %w", err)
+	}
+	if !acquired {
+		log.Printf("⏳ Waiting for another instance to finish migrating")
+		if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", lockKey); err != nil {
+			return fmt.Errorf("failed to take migration lock: %w", err)
+		}
+	}
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migrations: %w", err)
+	}
+	return nil
+}
+
+// lockedSQLite runs fn inside BEGIN IMMEDIATE on a dedicated connection,
+// which database/sql transactions cannot express
+func (m *Migrator) lockedSQLite(ctx context.Context, fn func(q querier) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "BEGIN IMMEDIATE"); err != nil {
+		return fmt.Errorf("failed to begin migration transaction: %w", err)
+	}
+	if err := fn(conn); err != nil {
+		conn.ExecContext(context.Background(), "ROLLBACK")
+		return err
+	}
+	if _, err := conn.ExecContext(ctx, "COMMIT"); err != nil {
+		conn.ExecContext(context.Background(), "ROLLBACK")
+		return fmt.Errorf("failed to commit migrations: %w", err)
+	}
+	return nil
+}
+
+// bind rewrites $n placeholders for the dialect
+func (m *Migrator) bind(query string) string {
+	if m.dialect == DialectSQLite {
+		return placeholder.ReplaceAllString(query, "?$1")
+	}
+	return query
+}
+
+var placeholder = regexp.MustCompile(`\$(\d+)`)
@@ -0,0 +1,201 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func newTestMigrator(t *testing.T) (*Migrator, *sql.DB) {
+	t.Helper()
+	db, err := sql.Open("sqlite", "file::memory:?_pragma=foreign_keys(1)")
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	// Every connection to ":memory:" gets its own database
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	m, err := New(db, DialectSQLite)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return m, db
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		dialect string
+		wantErr bool
+	}{
+		{DialectPostgres, false},
+		{DialectSQLite, false},
+		{"mysql", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dialect, func(t *testing.T) {
+			m, err := New(nil, tt.dialect)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			// Versions start at 1 and have no gaps
+			for i, migration := range m.migrations {
+				if migration.Version != i+1 {
+					t.Fatalf("migration %d is %s, want version %d", i, migration, i+1)
+				}
+				if migration.up == "" || migration.down == "" {
+					t.Errorf("migration %s is missing its up or down file", migration)
+				}
+			}
+		})
+	}
+}
+
+func TestUpDownSQLite(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestMigrator(t)
+
+	if err := m.Check(ctx); err == nil {
+		t.Fatal("Check() on an empty database error = nil, want pending migrations")
+	}
+
+	applied, err := m.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+	if len(applied) != m.Latest() {
+		t.Errorf("Up() applied %d migrations, want %d", len(applied), m.Latest())
+	}
+	if err := m.Check(ctx); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	status, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	if status.Current != m.Latest() || status.Pending() != 0 {
+		t.Errorf("Status() = current %d with %d pending, want %d with none", status.Current, status.Pending(), m.Latest())
+	}
+
+	// The schema holds the columns the stores rely on
+	if _, err := db.ExecContext(ctx, "INSERT INTO rooms (id, title, content, crdt_state, created_at, updated_at) VALUES ('r', 't', 'c', x'00', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)"); err != nil {
+		t.Fatalf("inserting a room error = %v", err)
+	}
+
+	applied, err = m.Up(ctx)
+	if err != nil {
+		t.Fatalf("second Up() error = %v", err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("second Up() applied %v, want nothing", applied)
+	}
+
+	reverted, err := m.Down(ctx, 1)
+	if err != nil {
+		t.Fatalf("Down(1) error = %v", err)
+	}
+	if len(reverted) != 1 || reverted[0].Version != m.Latest() {
+		t.Fatalf("Down(1) = %v, want the latest migration", reverted)
+	}
+	if err := m.Check(ctx); err == nil {
+		t.Error("Check() after Down(1) error = nil, want pending migrations")
+	}
+
+	reverted, err = m.Down(ctx, m.Latest())
+	if err != nil {
+		t.Fatalf("Down() error = %v", err)
+	}
+	if len(reverted) != m.Latest()-1 {
+		t.Errorf("Down() rolled back %d migrations, want %d", len(reverted), m.Latest()-1)
+	}
+
+	// Every down migration undoes its up migration, so the schema can be
+	// rebuilt from scratch
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("Up() after Down() error = %v", err)
+	}
+	if err := m.Check(ctx); err != nil {
+		t.Fatalf("Check() after Down() and Up() error = %v", err)
+	}
+}
+
+func TestSchemaTooNewSQLite(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestMigrator(t)
+
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+	if _, err := db.ExecContext(ctx, "INSERT INTO schema_migrations (version, name, applied_at) VALUES (999, 'from_the_future', CURRENT_TIMESTAMP)"); err != nil {
+		t.Fatalf("recording a newer migration error = %v", err)
+	}
+
+	if err := m.Check(ctx); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("Check() error = %v, want ErrSchemaTooNew", err)
+	}
+	if _, err := m.Up(ctx); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("Up() error = %v, want ErrSchemaTooNew", err)
+	}
+	if _, err := m.Down(ctx, 1); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("Down() error = %v, want ErrSchemaTooNew", err)
+	}
+}
+
+func TestMembersMigrationOwnsCreatedRoomsSQLite(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestMigrator(t)
+
+	// Roll back to just before room members existed
+	var members Migration
+	for _, migration := range m.migrations {
+		if migration.Name == "add_room_members" {
+			members = migration
+		}
+	}
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+	if _, err := m.Down(ctx, m.Latest()-members.Version+1); err != nil {
+		t.Fatalf("Down() error = %v", err)
+	}
+
+	for _, query := range []string{
+		"INSERT INTO users (uid, email, name, created_at, updated_at) VALUES ('alice', 'alice@example.com', 'Alice', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)",
+		"INSERT INTO rooms (id, title, content, user_uid, created_at, updated_at) VALUES ('created', 't', '', 'alice', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)",
+		"INSERT INTO rooms (id, title, content, created_at, updated_at) VALUES ('opened', 't', '', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)",
+	} {
+		if _, err := db.ExecContext(ctx, query); err != nil {
+			t.Fatalf("ExecContext(%q) error = %v", query, err)
+		}
+	}
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+
+	// The creator owns their room; a room opened without one has no members
+	// and stays closed until it is claimed
+	tests := []struct {
+		roomID string
+		want   string
+	}{
+		{"created", "alice:owner"},
+		{"opened", ""},
+	}
+	for _, tt := range tests {
+		var got string
+		err := db.QueryRowContext(ctx, "SELECT COALESCE(GROUP_CONCAT(user_uid || ':' || role), '') FROM room_members WHERE room_id = ?", tt.roomID).Scan(&got)
+		if err != nil {
+			t.Fatalf("reading members of %s error = %v", tt.roomID, err)
+		}
+		if got != tt.want {
+			t.Errorf("members of %s = %q, want %q", tt.roomID, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+-- Migration: Create rooms table
+-- The original schema: one row per document
+
+CREATE TABLE IF NOT EXISTS rooms (
+    id VARCHAR(255) PRIMARY KEY,
+    title VARCHAR(255) NOT NULL DEFAULT 'Untitled Room',
+    content TEXT DEFAULT '',
+    user_uid VARCHAR(255),
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_rooms_user_uid ON rooms(user_uid);
+CREATE INDEX IF NOT EXISTS idx_rooms_updated_at ON rooms(updated_at);
@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS update_users_updated_at ON users;
+DROP TRIGGER IF EXISTS update_rooms_updated_at ON rooms;
+DROP FUNCTION IF EXISTS update_updated_at_column();
+ALTER TABLE rooms DROP CONSTRAINT IF EXISTS fk_rooms_user_uid;
+DROP TABLE IF EXISTS users;
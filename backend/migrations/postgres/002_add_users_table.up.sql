@@ -15,16 +15,18 @@ CREATE TABLE IF NOT EXISTS users (
 CREATE INDEX IF NOT EXISTS idx_users_uid ON users(uid);
 CREATE INDEX IF NOT EXISTS idx_users_email ON users(email);
 
--- Update rooms table to reference users
--- First, add the foreign key constraint
-ALTER TABLE rooms 
-ADD CONSTRAINT fk_rooms_user_uid 
-FOREIGN KEY (user_uid) REFERENCES users(uid) ON DELETE SET NULL;
-
 -- Update existing rooms to handle NULL user_uid values
 UPDATE rooms SET user_uid = NULL WHERE user_uid = '';
 
--- Create trigger for users table
+-- Update rooms table to reference users. Databases created by the old
+-- setup.sql already have an unnamed constraint, replace it.
+ALTER TABLE rooms DROP CONSTRAINT IF EXISTS rooms_user_uid_fkey;
+ALTER TABLE rooms DROP CONSTRAINT IF EXISTS fk_rooms_user_uid;
+ALTER TABLE rooms
+ADD CONSTRAINT fk_rooms_user_uid
+FOREIGN KEY (user_uid) REFERENCES users(uid) ON DELETE SET NULL;
+
+-- Keep updated_at current on every update
 CREATE OR REPLACE FUNCTION update_updated_at_column()
 RETURNS TRIGGER AS $$
 BEGIN
@@ -33,11 +35,14 @@ BEGIN
 END;
 $$ language 'plpgsql';
 
--- Create trigger for users table
-DROP TRIGGER IF EXISTS update_users_updated_at ON users;
-CREATE TRIGGER update_users_updated_at 
-    BEFORE UPDATE ON users 
-    FOR EACH ROW 
+DROP TRIGGER IF EXISTS update_rooms_updated_at ON rooms;
+CREATE TRIGGER update_rooms_updated_at
+    BEFORE UPDATE ON rooms
+    FOR EACH ROW
     EXECUTE FUNCTION update_updated_at_column();
 
-
+DROP TRIGGER IF EXISTS update_users_updated_at ON users;
+CREATE TRIGGER update_users_updated_at
+    BEFORE UPDATE ON users
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,10 @@
+-- Migration: Add room version history
+-- Snapshots of room content taken while a room is edited and before restores
+
+CREATE TABLE IF NOT EXISTS room_versions (
+    room_id VARCHAR(255) NOT NULL REFERENCES rooms(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    content TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (room_id, version)
+);
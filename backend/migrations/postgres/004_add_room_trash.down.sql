@@ -0,0 +1,4 @@
+-- Rooms still in the trash are deleted for good, as before the trash existed
+DELETE FROM rooms WHERE deleted_at IS NOT NULL;
+DROP INDEX IF EXISTS idx_rooms_deleted_at;
+ALTER TABLE rooms DROP COLUMN IF EXISTS deleted_at;
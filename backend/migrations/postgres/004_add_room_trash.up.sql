@@ -0,0 +1,6 @@
+-- Migration: Add trash bin for rooms
+-- Deleted rooms keep their row with deleted_at set until they are purged
+
+ALTER TABLE rooms ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_rooms_deleted_at ON rooms(deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_rooms_search_vector;
+ALTER TABLE rooms DROP COLUMN IF EXISTS search_vector;
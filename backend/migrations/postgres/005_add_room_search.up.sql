@@ -0,0 +1,11 @@
+-- Migration: Add full-text search over rooms
+-- search_vector is generated from the title and content, so it follows every
+-- write without application code or triggers
+
+ALTER TABLE rooms ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', COALESCE(title, '')), 'A') ||
+        setweight(to_tsvector('english', COALESCE(content, '')), 'B')
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_rooms_search_vector ON rooms USING GIN (search_vector);
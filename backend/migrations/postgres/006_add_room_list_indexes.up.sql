@@ -0,0 +1,6 @@
+-- Migration: Add indexes for paging through a user's rooms
+-- Each matches one sort of the room list, with the ID breaking ties
+
+CREATE INDEX IF NOT EXISTS idx_rooms_user_updated ON rooms(user_uid, updated_at, id);
+CREATE INDEX IF NOT EXISTS idx_rooms_user_created ON rooms(user_uid, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_rooms_user_title ON rooms(user_uid, title, id);
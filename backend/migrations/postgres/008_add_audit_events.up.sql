@@ -0,0 +1,15 @@
+-- Migration: Add audit log
+-- Who did what to which room and from where. There is no foreign key, so the
+-- history of a room outlives it.
+
+CREATE TABLE IF NOT EXISTS audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    room_id VARCHAR(255) NOT NULL,
+    action VARCHAR(32) NOT NULL,
+    actor_uid VARCHAR(255) NOT NULL DEFAULT '',
+    remote_addr VARCHAR(64) NOT NULL DEFAULT '',
+    detail TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_room_created ON audit_events(room_id, created_at);
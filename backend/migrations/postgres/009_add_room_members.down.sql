@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS rooms_add_owner ON rooms;
+DROP FUNCTION IF EXISTS add_room_owner();
+DROP TABLE IF EXISTS room_members;
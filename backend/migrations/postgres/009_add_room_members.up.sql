@@ -0,0 +1,39 @@
+-- Migration: Add room members
+-- Who may open a room and what they may do there. Members may not have signed
+-- in yet, so user_uid does not reference users. Rooms without members, such as
+-- rooms opened before they had a creator, are closed to everyone until
+-- `rooms claim` gives them an owner.
+
+CREATE TABLE IF NOT EXISTS room_members (
+    room_id VARCHAR(255) NOT NULL REFERENCES rooms(id) ON DELETE CASCADE,
+    user_uid VARCHAR(255) NOT NULL,
+    role VARCHAR(16) NOT NULL CHECK (role IN ('owner', 'editor', 'viewer')),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (room_id, user_uid)
+);
+
+CREATE INDEX IF NOT EXISTS idx_room_members_user_uid ON room_members(user_uid);
+
+-- Whoever created a room owns it
+INSERT INTO room_members (room_id, user_uid, role, created_at, updated_at)
+SELECT id, user_uid, 'owner', created_at, created_at FROM rooms WHERE user_uid IS NOT NULL
+ON CONFLICT DO NOTHING;
+
+CREATE OR REPLACE FUNCTION add_room_owner()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.user_uid IS NOT NULL THEN
+        INSERT INTO room_members (room_id, user_uid, role, created_at, updated_at)
+        VALUES (NEW.id, NEW.user_uid, 'owner', NEW.created_at, NEW.created_at)
+        ON CONFLICT DO NOTHING;
+    END IF;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS rooms_add_owner ON rooms;
+CREATE TRIGGER rooms_add_owner
+    AFTER INSERT ON rooms
+    FOR EACH ROW
+    EXECUTE FUNCTION add_room_owner();
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS room_versions;
+DROP TABLE IF EXISTS rooms;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,31 @@
+-- Migration: Initial SQLite schema
+-- Mirrors the PostgreSQL schema up to room version history
+
+CREATE TABLE IF NOT EXISTS users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    uid TEXT UNIQUE NOT NULL,
+    email TEXT UNIQUE NOT NULL,
+    name TEXT,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS rooms (
+    id TEXT PRIMARY KEY,
+    title TEXT NOT NULL DEFAULT 'Untitled Room',
+    content TEXT NOT NULL DEFAULT '',
+    user_uid TEXT REFERENCES users(uid) ON DELETE SET NULL,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS room_versions (
+    room_id TEXT NOT NULL REFERENCES rooms(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    content TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (room_id, version)
+);
+
+CREATE INDEX IF NOT EXISTS idx_rooms_user_uid ON rooms(user_uid);
+CREATE INDEX IF NOT EXISTS idx_rooms_updated_at ON rooms(updated_at);
@@ -0,0 +1,25 @@
+-- Migration: Add full-text search over rooms
+-- rooms_fts holds a copy of every room's title and content, kept in sync by
+-- triggers. It is keyed by room ID because the rowids of rooms are not stable.
+
+CREATE VIRTUAL TABLE IF NOT EXISTS rooms_fts USING fts5(
+    room_id UNINDEXED,
+    title,
+    content,
+    tokenize = 'porter unicode61'
+);
+
+INSERT INTO rooms_fts (room_id, title, content)
+SELECT id, title, content FROM rooms;
+
+CREATE TRIGGER IF NOT EXISTS rooms_fts_insert AFTER INSERT ON rooms BEGIN
+    INSERT INTO rooms_fts (room_id, title, content) VALUES (new.id, new.title, new.content);
+END;
+
+CREATE TRIGGER IF NOT EXISTS rooms_fts_update AFTER UPDATE OF title, content ON rooms BEGIN
+    UPDATE rooms_fts SET title = new.title, content = new.content WHERE room_id = old.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS rooms_fts_delete AFTER DELETE ON rooms BEGIN
+    DELETE FROM rooms_fts WHERE room_id = old.id;
+END;
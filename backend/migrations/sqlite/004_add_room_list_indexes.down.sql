@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_rooms_user_title;
+DROP INDEX IF EXISTS idx_rooms_user_created;
+DROP INDEX IF EXISTS idx_rooms_user_updated;
@@ -0,0 +1,4 @@
+-- Migration: Add a content version to rooms
+-- Every write to content bumps it, so REST saves can detect concurrent changes
+
+ALTER TABLE rooms ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
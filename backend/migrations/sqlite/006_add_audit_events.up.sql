@@ -0,0 +1,15 @@
+-- Migration: Add audit log
+-- Who did what to which room and from where. There is no foreign key, so the
+-- history of a room outlives it.
+
+CREATE TABLE IF NOT EXISTS audit_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    room_id TEXT NOT NULL,
+    action TEXT NOT NULL,
+    actor_uid TEXT NOT NULL DEFAULT '',
+    remote_addr TEXT NOT NULL DEFAULT '',
+    detail TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_room_created ON audit_events(room_id, created_at);
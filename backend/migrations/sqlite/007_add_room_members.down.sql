@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS rooms_add_owner;
+DROP TABLE IF EXISTS room_members;
@@ -0,0 +1,26 @@
+-- Migration: Add room members
+-- Who may open a room and what they may do there. Members may not have signed
+-- in yet, so user_uid does not reference users. Rooms without members, such as
+-- rooms opened before they had a creator, are closed to everyone until
+-- `rooms claim` gives them an owner.
+
+CREATE TABLE IF NOT EXISTS room_members (
+    room_id TEXT NOT NULL REFERENCES rooms(id) ON DELETE CASCADE,
+    user_uid TEXT NOT NULL,
+    role TEXT NOT NULL CHECK (role IN ('owner', 'editor', 'viewer')),
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    PRIMARY KEY (room_id, user_uid)
+);
+
+CREATE INDEX IF NOT EXISTS idx_room_members_user_uid ON room_members(user_uid);
+
+-- Whoever created a room owns it
+INSERT OR IGNORE INTO room_members (room_id, user_uid, role, created_at, updated_at)
+SELECT id, user_uid, 'owner', created_at, created_at FROM rooms WHERE user_uid IS NOT NULL;
+
+CREATE TRIGGER IF NOT EXISTS rooms_add_owner AFTER INSERT ON rooms
+WHEN new.user_uid IS NOT NULL BEGIN
+    INSERT OR IGNORE INTO room_members (room_id, user_uid, role, created_at, updated_at)
+    VALUES (new.id, new.user_uid, 'owner', new.created_at, new.created_at);
+END;
@@ -0,0 +1,17 @@
+-- Migration: Add share links
+-- Links grant a role in a room to whoever holds their token, until they
+-- expire, run out of uses or are revoked. Only a hash of the token is kept.
+
+CREATE TABLE IF NOT EXISTS room_links (
+    id TEXT PRIMARY KEY,
+    room_id TEXT NOT NULL REFERENCES rooms(id) ON DELETE CASCADE,
+    token_hash TEXT NOT NULL UNIQUE,
+    role TEXT NOT NULL CHECK (role IN ('editor', 'viewer')),
+    created_by TEXT NOT NULL DEFAULT '',
+    expires_at DATETIME,
+    max_uses INTEGER NOT NULL DEFAULT 0,
+    uses INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_room_links_room_id ON room_links(room_id);
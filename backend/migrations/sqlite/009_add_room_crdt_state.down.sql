@@ -0,0 +1 @@
+ALTER TABLE rooms DROP COLUMN crdt_state;
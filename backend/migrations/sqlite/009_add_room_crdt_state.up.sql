@@ -0,0 +1,5 @@
+-- Migration: Add the saved Yjs state to rooms
+-- Yjs clients that reconnect keep their edits, so a room is reloaded from its
+-- CRDT state rather than rebuilt from the plain text
+
+ALTER TABLE rooms ADD COLUMN crdt_state BLOB;
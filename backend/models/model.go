@@ -5,14 +5,51 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/logoes0/peeriodic.git/ot"
 )
 
-// Message represents a WebSocket message
+// Message represents a WebSocket message. Revision is set by the server on
+// every frame it sends; BaseRevision is set by clients to the revision their
+// edit was built on.
 type Message struct {
-	Type string `json:"type"`
-	Data string `json:"data"`
+	Type         string         `json:"type"`
+	Data         string         `json:"data"`
+	Revision     int64          `json:"rev"`
+	Epoch        string         `json:"epoch,omitempty"`
+	BaseRevision *int64         `json:"baseRev,omitempty"`
+	Ops          []ot.Operation `json:"ops,omitempty"`
+	Code         string         `json:"code,omitempty"`
+	Presence     *Presence      `json:"presence,omitempty"`
+	Peers        []Presence     `json:"peers,omitempty"`
+	Cursor       *Cursor        `json:"cursor,omitempty"`
 }
 
+// Presence describes a user connected to a room. It is ephemeral and only
+// lives as long as the connection.
+type Presence struct {
+	SessionID string  `json:"sessionId"`
+	UserID    string  `json:"userId,omitempty"`
+	Name      string  `json:"name"`
+	Color     string  `json:"color"`
+	Cursor    *Cursor `json:"cursor,omitempty"`
+}
+
+// Cursor is a caret or selection in UTF-16 offsets; Anchor equals Head for a caret
+type Cursor struct {
+	Anchor int `json:"anchor"`
+	Head   int `json:"head"`
+}
+
+// Error codes sent in WebSocket error frames
+const (
+	ErrorStaleRevision    = "stale_revision"
+	ErrorInvalidRevision  = "invalid_revision"
+	ErrorInvalidOperation = "invalid_operation"
+	ErrorDocumentTooLarge = "document_too_large"
+	ErrorRateLimited      = "rate_limited"
+	ErrorReadOnly         = "read_only"
+)
+
 // User represents a user in the system
 type User struct {
 	ID        int       `json:"id"`
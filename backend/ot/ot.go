@@ -0,0 +1,221 @@
+package ot
+
+import (
+	"fmt"
+	"unicode/utf16"
+)
+
+// Operation types
+const (
+	OpInsert = "insert"
+	OpDelete = "delete"
+)
+
+// Operation represents a single insert or delete at a position in the document.
+// Positions and lengths are measured in UTF-16 code units so they line up with
+// the offsets reported by browser editors.
+type Operation struct {
+	Type string `json:"type"`
+	Pos  int    `json:"pos"`
+	Text string `json:"text,omitempty"`
+	Len  int    `json:"len,omitempty"`
+}
+
+// Insert creates an insert operation
+func Insert(pos int, text string) Operation {
+	return Operation{Type: OpInsert, Pos: pos, Text: text}
+}
+
+// Delete creates a delete operation
+func Delete(pos, length int) Operation {
+	return Operation{Type: OpDelete, Pos: pos, Len: length}
+}
+
+// TextLength returns the length of s in UTF-16 code units
+func TextLength(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
+
+// Validate checks that an operation is well formed
+func (op Operation) Validate() error {
+	if op.Pos < 0 {
+		return fmt.Errorf("invalid position: %d", op.Pos)
+	}
+	switch op.Type {
+	case OpInsert:
+		if op.Text == "" {
+			return fmt.Errorf("insert operation without text")
+		}
+	case OpDelete:
+		if op.Len <= 0 {
+			return fmt.Errorf("invalid delete length: %d", op.Len)
+		}
+	default:
+		return fmt.Errorf("unknown operation type: %s", op.Type)
+	}
+	return nil
+}
+
+// Apply applies a sequence of operations to a document, in order
+func Apply(doc string, ops []Operation) (string, error) {
+	units := utf16.Encode([]rune(doc))
+	for _, op := range ops {
+		if err := op.Validate(); err != nil {
+			return "", err
+		}
+		if op.Pos > len(units) {
+			return "", fmt.Errorf("position %d out of range (length %d)", op.Pos, len(units))
+		}
+		if splitsSurrogatePair(units, op.Pos) {
+			return "", fmt.Errorf("position %d splits a surrogate pair", op.Pos)
+		}
+
+		switch op.Type {
+		case OpInsert:
+			text := utf16.Encode([]rune(op.Text))
+			next := make([]uint16, 0, len(units)+len(text))
+			next = append(next, units[:op.Pos]...)
+			next = append(next, text...)
+			units = append(next, units[op.Pos:]...)
+		case OpDelete:
+			if op.Pos+op.Len > len(units) {
+				return "", fmt.Errorf("delete range %d+%d out of range (length %d)", op.Pos, op.Len, len(units))
+			}
+			if splitsSurrogatePair(units, op.Pos+op.Len) {
+				return "", fmt.Errorf("position %d splits a surrogate pair", op.Pos+op.Len)
+			}
+			units = append(units[:op.Pos], units[op.Pos+op.Len:]...)
+		}
+	}
+	return string(utf16.Decode(units)), nil
+}
+
+// splitsSurrogatePair reports whether pos falls between the two halves of a
+// UTF-16 surrogate pair
+func splitsSurrogatePair(units []uint16, pos int) bool {
+	return pos > 0 && pos < len(units) &&
+		units[pos-1] >= 0xd800 && units[pos-1] < 0xdc00 &&
+		units[pos] >= 0xdc00 && units[pos] < 0xe000
+}
+
+// Diff returns the operations that turn oldDoc into newDoc by replacing the
+// span between their common prefix and suffix
+func Diff(oldDoc, newDoc string) []Operation {
+	a := utf16.Encode([]rune(oldDoc))
+	b := utf16.Encode([]rune(newDoc))
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	if splitsSurrogatePair(a, prefix) || splitsSurrogatePair(b, prefix) {
+		prefix--
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	if splitsSurrogatePair(a, len(a)-suffix) || splitsSurrogatePair(b, len(b)-suffix) {
+		suffix--
+	}
+
+	var ops []Operation
+	if removed := len(a) - prefix - suffix; removed > 0 {
+		ops = append(ops, Delete(prefix, removed))
+	}
+	if inserted := b[prefix : len(b)-suffix]; len(inserted) > 0 {
+		ops = append(ops, Insert(prefix, string(utf16.Decode(inserted))))
+	}
+	return ops
+}
+
+// Transform transforms two concurrent operation sequences against each other.
+// It returns a' and b' such that applying a then b' yields the same document
+// as applying b then a'. When both insert at the same position, b's text is
+// placed first, so b should be the operation the server already applied.
+func Transform(a, b []Operation) ([]Operation, []Operation) {
+	if len(a) == 0 || len(b) == 0 {
+		return a, b
+	}
+	if len(a) == 1 && len(b) == 1 {
+		return transformPair(a[0], b[0])
+	}
+	if len(a) > 1 {
+		head, b1 := Transform(a[:1], b)
+		tail, b2 := Transform(a[1:], b1)
+		return append(head, tail...), b2
+	}
+	a1, head := Transform(a, b[:1])
+	a2, tail := Transform(a1, b[1:])
+	return a2, append(head, tail...)
+}
+
+// transformPair transforms two single operations, giving b priority on ties
+func transformPair(a, b Operation) ([]Operation, []Operation) {
+	switch {
+	case a.Type == OpInsert && b.Type == OpInsert:
+		if a.Pos < b.Pos {
+			b.Pos += TextLength(a.Text)
+		} else {
+			a.Pos += TextLength(b.Text)
+		}
+		return []Operation{a}, []Operation{b}
+
+	case a.Type == OpInsert && b.Type == OpDelete:
+		bPrime, aPrime := transformDeleteInsert(b, a)
+		return aPrime, bPrime
+
+	case a.Type == OpDelete && b.Type == OpInsert:
+		return transformDeleteInsert(a, b)
+
+	default:
+		return transformDeleteDelete(a, b), transformDeleteDelete(b, a)
+	}
+}
+
+// transformDeleteInsert transforms a delete against a concurrent insert
+func transformDeleteInsert(del, ins Operation) ([]Operation, []Operation) {
+	insLen := TextLength(ins.Text)
+
+	switch {
+	case ins.Pos <= del.Pos:
+		del.Pos += insLen
+		return []Operation{del}, []Operation{ins}
+	case ins.Pos >= del.Pos+del.Len:
+		ins.Pos -= del.Len
+		return []Operation{del}, []Operation{ins}
+	default:
+		// The insert lands inside the deleted range: keep the inserted text and
+		// delete around it
+		before := ins.Pos - del.Pos
+		ins.Pos = del.Pos
+		return []Operation{
+			Delete(del.Pos, before),
+			Delete(del.Pos+insLen, del.Len-before),
+		}, []Operation{ins}
+	}
+}
+
+// transformDeleteDelete transforms delete a against a concurrent delete b
+func transformDeleteDelete(a, b Operation) []Operation {
+	length := a.Len - overlap(a.Pos, a.Pos+a.Len, b.Pos, b.Pos+b.Len)
+	if length == 0 {
+		return nil
+	}
+	pos := a.Pos - overlap(0, a.Pos, b.Pos, b.Pos+b.Len)
+	return []Operation{Delete(pos, length)}
+}
+
+// overlap returns the length of the intersection of [s1,e1) and [s2,e2)
+func overlap(s1, e1, s2, e2 int) int {
+	start := max(s1, s2)
+	end := min(e1, e2)
+	if end < start {
+		return 0
+	}
+	return end - start
+}
@@ -0,0 +1,174 @@
+package ot
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		ops     []Operation
+		want    string
+		wantErr bool
+	}{
+		{"insert at start", "world", []Operation{Insert(0, "hello ")}, "hello world", false},
+		{"insert at end", "hello", []Operation{Insert(5, "!")}, "hello!", false},
+		{"delete", "hello world", []Operation{Delete(5, 6)}, "hello", false},
+		{"in order", "abc", []Operation{Delete(0, 1), Insert(2, "d")}, "bcd", false},
+		{"utf-16 positions", "a😀b", []Operation{Insert(3, "!")}, "a😀!b", false},
+		{"delete a surrogate pair", "a😀b", []Operation{Delete(1, 2)}, "ab", false},
+		{"splits a surrogate pair", "a😀b", []Operation{Insert(2, "x")}, "", true},
+		{"delete splits a surrogate pair", "a😀b", []Operation{Delete(0, 2)}, "", true},
+		{"insert past the end", "abc", []Operation{Insert(4, "x")}, "", true},
+		{"delete past the end", "abc", []Operation{Delete(2, 2)}, "", true},
+		{"negative position", "abc", []Operation{Insert(-1, "x")}, "", true},
+		{"empty insert", "abc", []Operation{Insert(0, "")}, "", true},
+		{"empty delete", "abc", []Operation{Delete(0, 0)}, "", true},
+		{"unknown type", "abc", []Operation{{Type: "retain", Pos: 0}}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Apply(tt.doc, tt.ops)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Apply() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Apply() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     []Operation
+	}{
+		{"unchanged", "abc", "abc", nil},
+		{"insert", "ac", "abc", []Operation{Insert(1, "b")}},
+		{"delete", "abc", "ac", []Operation{Delete(1, 1)}},
+		{"replace", "abc", "axc", []Operation{Delete(1, 1), Insert(1, "x")}},
+		{"from empty", "", "abc", []Operation{Insert(0, "abc")}},
+		{"to empty", "abc", "", []Operation{Delete(0, 3)}},
+		{"repeated text", "aaa", "aaaa", []Operation{Insert(3, "a")}},
+		{"shared high surrogate", "😀", "😁", []Operation{Delete(0, 2), Insert(0, "😁")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ops := Diff(tt.old, tt.new)
+			if len(ops) != len(tt.want) {
+				t.Fatalf("Diff() = %v, want %v", ops, tt.want)
+			}
+			for i := range ops {
+				if ops[i] != tt.want[i] {
+					t.Fatalf("Diff() = %v, want %v", ops, tt.want)
+				}
+			}
+			got, err := Apply(tt.old, ops)
+			if err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			if got != tt.new {
+				t.Errorf("Apply(Diff()) = %q, want %q", got, tt.new)
+			}
+		})
+	}
+}
+
+// checkConvergence applies a then b' and b then a' to doc and fails unless
+// both orders produce the same document
+func checkConvergence(t *testing.T, doc string, a, b []Operation) string {
+	t.Helper()
+	aPrime, bPrime := Transform(a, b)
+
+	viaA, err := Apply(doc, a)
+	if err != nil {
+		t.Fatalf("Apply(a) error = %v", err)
+	}
+	viaA, err = Apply(viaA, bPrime)
+	if err != nil {
+		t.Fatalf("Apply(b') error = %v (a=%v b=%v b'=%v)", err, a, b, bPrime)
+	}
+
+	viaB, err := Apply(doc, b)
+	if err != nil {
+		t.Fatalf("Apply(b) error = %v", err)
+	}
+	viaB, err = Apply(viaB, aPrime)
+	if err != nil {
+		t.Fatalf("Apply(a') error = %v (a=%v b=%v a'=%v)", err, a, b, aPrime)
+	}
+
+	if viaA != viaB {
+		t.Fatalf("a then b' = %q, b then a' = %q (doc=%q a=%v b=%v)", viaA, viaB, doc, a, b)
+	}
+	return viaA
+}
+
+func TestTransformConverges(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		a, b []Operation
+		want string
+	}{
+		{"inserts at different positions", "abc", []Operation{Insert(0, "x")}, []Operation{Insert(3, "y")}, "xabcy"},
+		{"inserts at the same position", "abc", []Operation{Insert(1, "x")}, []Operation{Insert(1, "y")}, "ayxbc"},
+		{"insert before a delete", "abcdef", []Operation{Insert(1, "x")}, []Operation{Delete(3, 2)}, "axbcf"},
+		{"insert after a delete", "abcdef", []Operation{Insert(5, "x")}, []Operation{Delete(1, 2)}, "adexf"},
+		{"insert inside a delete", "abcdef", []Operation{Insert(3, "x")}, []Operation{Delete(1, 4)}, "axf"},
+		{"delete around an insert", "abcdef", []Operation{Delete(1, 4)}, []Operation{Insert(3, "x")}, "axf"},
+		{"insert at the start of a delete", "abcdef", []Operation{Insert(2, "x")}, []Operation{Delete(2, 2)}, "abxef"},
+		{"insert at the end of a delete", "abcdef", []Operation{Insert(4, "x")}, []Operation{Delete(2, 2)}, "abxef"},
+		{"disjoint deletes", "abcdef", []Operation{Delete(0, 1)}, []Operation{Delete(4, 2)}, "bcd"},
+		{"overlapping deletes", "abcdef", []Operation{Delete(1, 3)}, []Operation{Delete(2, 3)}, "af"},
+		{"same delete", "abcdef", []Operation{Delete(1, 2)}, []Operation{Delete(1, 2)}, "adef"},
+		{"nested deletes", "abcdef", []Operation{Delete(0, 6)}, []Operation{Delete(2, 1)}, ""},
+		{"sequences", "hello world", []Operation{Delete(0, 5), Insert(0, "goodbye")}, []Operation{Insert(11, "!"), Delete(5, 1)}, "goodbyeworld!"},
+		{"empty side", "abc", nil, []Operation{Insert(0, "x")}, "xabc"},
+		{"surrogate pairs", "😀😀", []Operation{Insert(2, "a")}, []Operation{Delete(0, 2)}, "a😀"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkConvergence(t, tt.doc, tt.a, tt.b); got != tt.want {
+				t.Errorf("converged on %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// randomOps returns a few random operations valid on doc, applied in order
+func randomOps(rng *rand.Rand, doc string) []Operation {
+	var ops []Operation
+	for range rng.Intn(3) + 1 {
+		n := TextLength(doc)
+		var op Operation
+		if n > 0 && rng.Intn(2) == 0 {
+			pos := rng.Intn(n)
+			op = Delete(pos, rng.Intn(n-pos)+1)
+		} else {
+			op = Insert(rng.Intn(n+1), string(rune('a'+rng.Intn(26))))
+		}
+		next, err := Apply(doc, []Operation{op})
+		if err != nil {
+			panic(err)
+		}
+		ops = append(ops, op)
+		doc = next
+	}
+	return ops
+}
+
+func TestTransformConvergesRandomly(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for range 5000 {
+		doc := "abcdefgh"[:rng.Intn(9)]
+		checkConvergence(t, doc, randomOps(rng, doc), randomOps(rng, doc))
+	}
+}
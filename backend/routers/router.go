@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/logoes0/peeriodic.git/auth"
+	"github.com/logoes0/peeriodic.git/config"
 	"github.com/logoes0/peeriodic.git/handlers"
 	"github.com/logoes0/peeriodic.git/middleware"
 	"github.com/logoes0/peeriodic.git/services"
@@ -14,34 +16,75 @@ import (
 type Router struct {
 	roomHandler     *handlers.RoomHandler
 	documentHandler *handlers.DocumentHandler
+	statsHandler    *handlers.StatsHandler
+	versionHandler  *handlers.VersionHandler
+	searchHandler   *handlers.SearchHandler
+	auditHandler    *handlers.AuditHandler
+	memberHandler   *handlers.MemberHandler
+	linkHandler     *handlers.LinkHandler
 	wsService       *services.WebSocketService
+	authenticate    func(http.HandlerFunc) http.HandlerFunc
+	cors            func(http.HandlerFunc) http.HandlerFunc
+	limitIP         func(http.HandlerFunc) http.HandlerFunc
+	limitUser       func(http.HandlerFunc) http.HandlerFunc
 }
 
-// NewRouter creates a new router instance
-func NewRouter(dbService *services.DatabaseService, wsService *services.WebSocketService) *Router {
+// NewRouter creates a new router instance. A nil verifier leaves the API
+// open to unauthenticated callers; the limiter holds the request counts
+// behind cfg.RateLimit.
+func NewRouter(cfg *config.Config, store services.Store, wsService *services.WebSocketService, verifier *auth.Verifier, limiter services.Limiter) *Router {
 	return &Router{
-		roomHandler:     handlers.NewRoomHandler(dbService),
-		documentHandler: handlers.NewDocumentHandler(dbService),
+		roomHandler:     handlers.NewRoomHandler(store, wsService),
+		documentHandler: handlers.NewDocumentHandler(store, wsService),
+		statsHandler:    handlers.NewStatsHandler(wsService),
+		versionHandler:  handlers.NewVersionHandler(store, wsService),
+		searchHandler:   handlers.NewSearchHandler(store),
+		auditHandler:    handlers.NewAuditHandler(store),
+		memberHandler:   handlers.NewMemberHandler(store, wsService),
+		linkHandler:     handlers.NewLinkHandler(store, wsService),
 		wsService:       wsService,
+		authenticate:    middleware.Auth(verifier),
+		cors:            middleware.CORS(cfg.CORS),
+		limitIP:         middleware.RateLimitIP(limiter, cfg.RateLimit),
+		limitUser:       middleware.RateLimitUser(limiter, cfg.RateLimit),
 	}
 }
 
+// guard authenticates a request between the per-IP rate limit, which holds
+// back callers whatever their token, and the per-user one
+func (r *Router) guard(next http.HandlerFunc) http.HandlerFunc {
+	return r.limitIP(r.authenticate(r.limitUser(next)))
+}
+
 // SetupRoutes configures all application routes with middleware
 func (r *Router) SetupRoutes() {
-	// WebSocket endpoint - NO middleware (WebSocket needs direct access to response writer)
-	http.HandleFunc("/ws", r.handleWebSocket)
+	// WebSocket endpoint - only authentication and rate limiting (WebSocket needs direct access
+	// to response writer); the handler checks the origin and connection caps itself
+	http.HandleFunc("/ws", r.guard(r.handleWebSocket))
+
+	// Yjs sync endpoint - binary WebSocket protocol, room ID in the path or query
+	http.HandleFunc("/yjs", r.guard(r.handleYjs))
+	http.HandleFunc("/yjs/", r.guard(r.handleYjs))
 
-	// HTTP API endpoints - apply CORS middleware
-	http.HandleFunc("/api/rooms", middleware.Logging(middleware.CORS(r.handleRooms)))
-	http.HandleFunc("/api/save", middleware.Logging(middleware.CORS(r.handleSave)))
+	// HTTP API endpoints - apply CORS middleware, which answers preflights
+	// before rate limits and authentication
+	http.HandleFunc("/api/rooms", middleware.Logging(r.cors(r.guard(r.handleRooms))))
+	http.HandleFunc("/api/save", middleware.Logging(r.cors(r.guard(r.handleSave))))
+	http.HandleFunc("/api/stats", middleware.Logging(r.cors(r.guard(r.handleStats))))
+	http.HandleFunc("/api/search", middleware.Logging(r.cors(r.guard(r.handleSearch))))
 
 	// Handle room-specific operations with path parameters
-	http.HandleFunc("/api/rooms/", middleware.Logging(middleware.CORS(r.handleRoomOperations)))
+	http.HandleFunc("/api/rooms/", middleware.Logging(r.cors(r.guard(r.handleRoomOperations))))
 }
 
 // handleWebSocket handles WebSocket connections
 func (r *Router) handleWebSocket(w http.ResponseWriter, req *http.Request) {
-	r.wsService.HandleConnection(w, req, r.roomHandler.DBService)
+	r.wsService.HandleConnection(w, req, r.roomHandler.Store)
+}
+
+// handleYjs handles Yjs sync WebSocket connections
+func (r *Router) handleYjs(w http.ResponseWriter, req *http.Request) {
+	r.wsService.HandleYjsConnection(w, req, r.roomHandler.Store)
 }
 
 // handleRooms handles room listing and creation
@@ -49,7 +92,8 @@ func (r *Router) handleRooms(w http.ResponseWriter, req *http.Request) {
 	r.roomHandler.HandleRooms(w, req)
 }
 
-// handleRoomOperations handles room-specific operations (GET, DELETE)
+// handleRoomOperations handles room-specific operations (GET, DELETE) and
+// routes version history, diff, audit, member, link and restore requests
 func (r *Router) handleRoomOperations(w http.ResponseWriter, req *http.Request) {
 	log.Printf("handleRoomOperations called with path: %s", req.URL.Path)
 
@@ -71,6 +115,31 @@ func (r *Router) handleRoomOperations(w http.ResponseWriter, req *http.Request)
 		return
 	}
 
+	if len(pathParts) > 4 && pathParts[4] == "versions" {
+		r.versionHandler.HandleVersions(w, req)
+		return
+	}
+	if len(pathParts) > 4 && pathParts[4] == "diff" {
+		r.versionHandler.HandleDiff(w, req)
+		return
+	}
+	if len(pathParts) > 4 && pathParts[4] == "audit" {
+		r.auditHandler.HandleAudit(w, req)
+		return
+	}
+	if len(pathParts) > 4 && pathParts[4] == "members" {
+		r.memberHandler.HandleMembers(w, req)
+		return
+	}
+	if len(pathParts) > 4 && pathParts[4] == "links" {
+		r.linkHandler.HandleLinks(w, req)
+		return
+	}
+	if len(pathParts) > 4 && pathParts[4] == "restore" {
+		r.roomHandler.HandleRestoreRoom(w, req)
+		return
+	}
+
 	// Create a new request with the room ID in the path for the handlers
 	req.URL.Path = "/api/rooms/" + roomID
 	log.Printf("Modified path: %s", req.URL.Path)
@@ -92,3 +161,13 @@ func (r *Router) handleRoomOperations(w http.ResponseWriter, req *http.Request)
 func (r *Router) handleSave(w http.ResponseWriter, req *http.Request) {
 	r.documentHandler.HandleSave(w, req)
 }
+
+// handleStats handles connection statistics requests
+func (r *Router) handleStats(w http.ResponseWriter, req *http.Request) {
+	r.statsHandler.HandleStats(w, req)
+}
+
+// handleSearch handles full-text search over a user's rooms
+func (r *Router) handleSearch(w http.ResponseWriter, req *http.Request) {
+	r.searchHandler.HandleSearch(w, req)
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Audit log actions
+const (
+	AuditCreate  = "create"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+	AuditSave    = "save"
+	AuditJoin    = "join"
+	AuditLeave   = "leave"
+	AuditShare   = "share"
+	AuditUnshare = "unshare"
+	// Share links
+	AuditLinkCreate = "link_create"
+	AuditLinkRevoke = "link_revoke"
+	AuditLinkUse    = "link_use"
+)
+
+// AuditEvent records something done to a room, by whom and from where. Events
+// are kept after their room is purged.
+type AuditEvent struct {
+	ID         int64     `json:"id"`
+	RoomID     string    `json:"room_id"`
+	Action     string    `json:"action"`
+	ActorUID   string    `json:"actor_uid,omitempty"`
+	RemoteAddr string    `json:"remote_addr,omitempty"`
+	Detail     string    `json:"detail,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// AuditQuery selects a room's audit events. Since is inclusive and Until
+// exclusive; zero times leave that end open.
+type AuditQuery struct {
+	Since time.Time
+	Until time.Time
+	Limit int
+}
+
+// RecordAudit adds an event to the audit log. The action has already
+// happened by then, so a failure is logged rather than returned.
+func RecordAudit(ctx context.Context, store Store, event *AuditEvent) {
+	if err := store.CreateAuditEvent(ctx, event); err != nil {
+		log.Printf("❌ Failed to record %s of room %s in the audit log: %v", event.Action, event.RoomID, err)
+	}
+}
+
+// RemoteHost returns the address a request came from, without its port
+func RemoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// auditQuery builds the SQL listing a room's audit events, with parameters
+// rendered by placeholder and times passed through toParam like roomListQuery
+func auditQuery(roomID string, query AuditQuery, placeholder func(n int) string, toParam func(time.Time) any) (string, []any) {
+	args := []any{roomID}
+	sqlQuery := `
+		SELECT id, room_id, action, actor_uid, remote_addr, detail, created_at
+		FROM audit_events
+		WHERE room_id = ` + placeholder(1)
+	if !query.Since.IsZero() {
+		args = append(args, toParam(query.Since))
+		sqlQuery += " AND created_at >= " + placeholder(len(args))
+	}
+	if !query.Until.IsZero() {
+		args = append(args, toParam(query.Until))
+		sqlQuery += " AND created_at < " + placeholder(len(args))
+	}
+	sqlQuery += " ORDER BY created_at DESC, id DESC"
+	if query.Limit > 0 {
+		args = append(args, query.Limit)
+		sqlQuery += " LIMIT " + placeholder(len(args))
+	}
+	return sqlQuery, args
+}
+
+// scanAuditEvents reads the rows of an audit query and closes them
+func scanAuditEvents(rows *sql.Rows) ([]*AuditEvent, error) {
+	defer rows.Close()
+
+	events := []*AuditEvent{}
+	for rows.Next() {
+		event := &AuditEvent{}
+		err := rows.Scan(
+			&event.ID, &event.RoomID, &event.Action, &event.ActorUID, &event.RemoteAddr, &event.Detail, &event.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan audit event: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating audit events: %w", err)
+	}
+
+	return events, nil
+}
+
+// auditConnection records a client joining or leaving a room over the given
+// protocol
+func (ws *WebSocketService) auditConnection(client *Client, roomManager *RoomManager, action, protocol string) {
+	RecordAudit(ws.ctx, roomManager.store, &AuditEvent{
+		RoomID:     roomManager.ID,
+		Action:     action,
+		ActorUID:   client.userID,
+		RemoteAddr: client.remoteAddr,
+		Detail:     protocol,
+	})
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/logoes0/peeriodic.git/config"
+	"github.com/logoes0/peeriodic.git/models"
+)
+
+// writeWait is how long a write to a client may take before it is dropped
+const writeWait = 10 * time.Second
+
+// outboundMessage is an encoded frame waiting in a client's send queue
+type outboundMessage struct {
+	messageType int
+	data        []byte
+}
+
+// Client is a WebSocket connection with a bounded send queue drained by its
+// own write pump. Sending never blocks, so a slow client cannot stall the
+// room or the sender's read loop. The write pump also pings the peer; a peer
+// that stops answering hits its read deadline, which ends the read loop and
+// evicts it like any other disconnect.
+type Client struct {
+	conn         *websocket.Conn
+	send         chan outboundMessage
+	done         chan struct{}
+	closeOnce    sync.Once
+	closeCode    int
+	closeReason  string
+	pingInterval time.Duration
+	pongTimeout  time.Duration
+	idleTimeout  time.Duration
+	idleTimer    *time.Timer
+	limiter      *tokenBucket
+	// strikes counts inbound messages dropped in a row by the rate limit
+	strikes int
+	// userID and remoteAddr identify the connection in the audit log
+	userID     string
+	remoteAddr string
+	// role is the user's role in the room, guarded by the room's mu once
+	// the client has joined. link is the share link it came from, if any.
+	role string
+	link *RoomLink
+}
+
+// newClient wraps a connection, arms its heartbeat and starts its write pump
+func newClient(conn *websocket.Conn, cfg config.WebSocketConfig) *Client {
+	client := &Client{
+		conn:         conn,
+		send:         make(chan outboundMessage, max(cfg.SendQueueSize, 1)),
+		done:         make(chan struct{}),
+		pingInterval: cfg.PingInterval,
+		pongTimeout:  cfg.PongTimeout,
+		idleTimeout:  cfg.IdleTimeout,
+		limiter:      newTokenBucket(float64(cfg.MessageRate), cfg.MessageBurst),
+	}
+
+	// Frames over the limit fail the read and get a 1009 close from the library
+	if cfg.MaxMessageSize > 0 {
+		conn.SetReadLimit(int64(cfg.MaxMessageSize))
+	}
+
+	client.extendReadDeadline()
+	conn.SetPongHandler(func(string) error {
+		client.extendReadDeadline()
+		return nil
+	})
+
+	if client.idleTimeout > 0 {
+		client.idleTimer = time.AfterFunc(client.idleTimeout, func() {
+			log.Printf("⏱️ Closing idle client %s", conn.RemoteAddr())
+			client.Close(websocket.CloseGoingAway, "idle timeout")
+		})
+	}
+
+	go client.writePump()
+	return client
+}
+
+// extendReadDeadline gives the peer another pong timeout to show it is alive
+func (c *Client) extendReadDeadline() {
+	if c.pongTimeout > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(c.pongTimeout))
+	}
+}
+
+// Touch records a message from the peer, which keeps it alive and resets its
+// idle timeout. Read loops call it after every message.
+func (c *Client) Touch() {
+	c.extendReadDeadline()
+	if c.idleTimer != nil {
+		c.idleTimer.Reset(c.idleTimeout)
+	}
+}
+
+// Send queues a frame without blocking. A client whose queue is full can no
+// longer keep up with the room and is disconnected.
+func (c *Client) Send(messageType int, data []byte) bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+
+	select {
+	case c.send <- outboundMessage{messageType: messageType, data: data}:
+		return true
+	default:
+		log.Printf("⚠️ Send queue full, disconnecting client %s", c.conn.RemoteAddr())
+		c.Close(websocket.CloseTryAgainLater, "send queue full")
+		return false
+	}
+}
+
+// SendJSON encodes and queues a JSON message
+func (c *Client) SendJSON(message models.Message) bool {
+	data, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("❌ Failed to encode message: %v", err)
+		return false
+	}
+	return c.Send(websocket.TextMessage, data)
+}
+
+// Close stops the client. The write pump flushes what is already queued,
+// sends a close frame with the given code (none when code is 0) and closes the
+// connection, which in turn ends the read loop.
+func (c *Client) Close(code int, reason string) {
+	c.closeOnce.Do(func() {
+		c.closeCode = code
+		c.closeReason = reason
+		close(c.done)
+	})
+}
+
+// writePump writes queued frames and pings to the connection until the
+// client is closed
+func (c *Client) writePump() {
+	defer c.conn.Close()
+	if c.idleTimer != nil {
+		defer c.idleTimer.Stop()
+	}
+
+	var ping <-chan time.Time
+	if c.pingInterval > 0 {
+		ticker := time.NewTicker(c.pingInterval)
+		defer ticker.Stop()
+		ping = ticker.C
+	}
+
+	for {
+		select {
+		case <-ping:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				log.Printf("❌ Failed to ping client %s: %v", c.conn.RemoteAddr(), err)
+				c.Close(0, "")
+				return
+			}
+		case msg := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(msg.messageType, msg.data); err != nil {
+				log.Printf("❌ Failed to write to client %s: %v", c.conn.RemoteAddr(), err)
+				c.Close(0, "")
+				return
+			}
+		case <-c.done:
+			c.flush()
+			return
+		}
+	}
+}
+
+// flush writes the frames still queued and the close frame, all within a
+// single write deadline. A client that overflowed its queue is too slow to
+// drain it, so it only gets the close frame.
+func (c *Client) flush() {
+	c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	if c.closeCode != websocket.CloseTryAgainLater && !c.drain() {
+		return
+	}
+	if c.closeCode != 0 {
+		c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(c.closeCode, c.closeReason))
+	}
+}
+
+// drain writes the frames still queued and reports whether all writes succeeded
+func (c *Client) drain() bool {
+	for {
+		select {
+		case msg := <-c.send:
+			if err := c.conn.WriteMessage(msg.messageType, msg.data); err != nil {
+				return false
+			}
+		default:
+			return true
+		}
+	}
+}
+
+// isTimeout reports whether a read failed because the peer went silent
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/logoes0/peeriodic.git/utils"
+)
+
+// Limiter keeps the state behind request rate limits and connection caps.
+// MemoryLimiter keeps it in process; an implementation backed by a shared
+// store lets several instances enforce the same limits.
+type Limiter interface {
+	// Allow counts a request against key, which may make limit requests per
+	// window. When it refuses, it returns how long until the next request
+	// would be allowed.
+	Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, time.Duration, error)
+	// Acquire takes one of the max concurrent slots under key
+	Acquire(ctx context.Context, key string, max int) (bool, error)
+	// Release gives back a slot taken by Acquire
+	Release(ctx context.Context, key string) error
+}
+
+// limiterSweepInterval is how often idle entries are dropped from a
+// MemoryLimiter
+const limiterSweepInterval = time.Minute
+
+// MemoryLimiter is a Limiter for a single instance. Each key gets a token
+// bucket holding limit tokens that refills over window.
+type MemoryLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	slots     map[string]int
+	lastSweep time.Time
+}
+
+// NewMemoryLimiter creates an empty in-memory limiter
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{
+		buckets:   make(map[string]*tokenBucket),
+		slots:     make(map[string]int),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow implements Limiter
+func (l *MemoryLimiter) Allow(_ context.Context, key string, limit int, window time.Duration) (bool, time.Duration, error) {
+	if limit <= 0 || window <= 0 {
+		return true, 0, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep()
+	bucket, exists := l.buckets[key]
+	if !exists {
+		bucket = newTokenBucket(float64(limit)/window.Seconds(), limit)
+		l.buckets[key] = bucket
+	}
+	if bucket.allow() {
+		return true, 0, nil
+	}
+	return false, bucket.retryAfter(), nil
+}
+
+// Acquire implements Limiter
+func (l *MemoryLimiter) Acquire(_ context.Context, key string, max int) (bool, error) {
+	if max <= 0 {
+		return true, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.slots[key] >= max {
+		return false, nil
+	}
+	l.slots[key]++
+	return true, nil
+}
+
+// Release implements Limiter
+func (l *MemoryLimiter) Release(_ context.Context, key string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.slots[key] <= 1 {
+		delete(l.slots, key)
+	} else {
+		l.slots[key]--
+	}
+	return nil
+}
+
+// sweep drops buckets that have refilled, so clients that went away do not
+// pile up. The caller must hold l.mu.
+func (l *MemoryLimiter) sweep() {
+	now := time.Now()
+	if now.Sub(l.lastSweep) < limiterSweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, bucket := range l.buckets {
+		if bucket.full(now) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// connRetryAfter is the Retry-After sent with handshakes refused for too many
+// connections. Nobody knows when a slot frees up, so it is only a hint.
+const connRetryAfter = 10 * time.Second
+
+// acquireConnection takes a WebSocket connection slot for the client's IP and
+// one for the room, refusing the handshake with 429 when either cap is
+// reached. It returns a func giving the slots back, and false once the
+// request has been answered.
+func (ws *WebSocketService) acquireConnection(w http.ResponseWriter, r *http.Request, roomID string) (func(), bool) {
+	ctx := context.WithoutCancel(r.Context())
+	cfg := ws.config.RateLimit
+
+	var held []string
+	release := func() {
+		for _, key := range held {
+			if err := ws.limiter.Release(ctx, key); err != nil {
+				log.Printf("⚠️ Failed to release connection slot %s: %v", key, err)
+			}
+		}
+	}
+
+	slots := []struct {
+		key string
+		max int
+	}{
+		{"conns:ip:" + RemoteHost(r), cfg.MaxConnsPerIP},
+		{"conns:room:" + roomID, cfg.MaxConnsPerRoom},
+	}
+	for _, slot := range slots {
+		if slot.max <= 0 {
+			continue
+		}
+		acquired, err := ws.limiter.Acquire(ctx, slot.key, slot.max)
+		if err != nil {
+			log.Printf("⚠️ Connection limiter failed for %s, allowing connection: %v", slot.key, err)
+			continue
+		}
+		if !acquired {
+			release()
+			log.Printf("🚫 Refused connection to room %s from %s, %s is full", roomID, RemoteHost(r), slot.key)
+			utils.SetRetryAfter(w, connRetryAfter)
+			http.Error(w, "Too many connections", http.StatusTooManyRequests)
+			return nil, false
+		}
+		held = append(held, slot.key)
+	}
+	return release, true
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// RoomLink is a share link granting a role in a room to whoever holds its
+// token. Token is only known when the link is created; afterwards the link
+// is found by TokenHash.
+type RoomLink struct {
+	ID        string     `json:"id"`
+	RoomID    string     `json:"room_id"`
+	Token     string     `json:"token,omitempty"`
+	TokenHash string     `json:"-"`
+	Role      string     `json:"role"`
+	CreatedBy string     `json:"created_by,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// MaxUses caps how often the link can be used; zero means no cap
+	MaxUses   int       `json:"max_uses,omitempty"`
+	Uses      int       `json:"uses"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// linkRoles maps what a share link may grant, "view" or "edit", to the role
+// its holders get. The role names themselves are accepted as aliases.
+var linkRoles = map[string]string{
+	"view":     RoleViewer,
+	"edit":     RoleEditor,
+	RoleViewer: RoleViewer,
+	RoleEditor: RoleEditor,
+}
+
+// LinkRole returns the role a share link granting permission gives its
+// holders, and false when links cannot grant it
+func LinkRole(permission string) (string, bool) {
+	role, ok := linkRoles[permission]
+	return role, ok
+}
+
+// linkTokenBytes is the amount of randomness in a link token
+const linkTokenBytes = 32
+
+// NewLinkToken generates a share link token and the hash it is stored under
+func NewLinkToken() (token, hash string, err error) {
+	raw := make([]byte, linkTokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", fmt.Errorf("failed to generate link token: %w", err)
+	}
+	token = base64.RawURLEncoding.EncodeToString(raw)
+	return token, HashLinkToken(token), nil
+}
+
+// HashLinkToken returns the hash a link token is stored under
+func HashLinkToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// UseShareLink checks a share link token for a room and counts the use. It
+// fails with ErrLinkInvalid for unknown, expired and used up links alike.
+func UseShareLink(ctx context.Context, store Store, roomID, token string) (*RoomLink, error) {
+	return store.UseRoomLink(ctx, roomID, HashLinkToken(token))
+}
+
+// HigherRole returns whichever of two roles grants more
+func HigherRole(a, b string) string {
+	if roleRanks[b] > roleRanks[a] {
+		return b
+	}
+	return a
+}
+
+// LinkUseDetail describes a use of a link in the audit log
+func LinkUseDetail(link *RoomLink, via string) string {
+	return fmt.Sprintf("link %s as %s via %s", link.ID, link.Role, via)
+}
+
+// scanRoomLinks reads the rows of a room link query and closes them
+func scanRoomLinks(rows *sql.Rows) ([]*RoomLink, error) {
+	defer rows.Close()
+
+	links := []*RoomLink{}
+	for rows.Next() {
+		link := &RoomLink{}
+		err := rows.Scan(
+			&link.ID, &link.RoomID, &link.Role, &link.CreatedBy, &link.ExpiresAt, &link.MaxUses, &link.Uses, &link.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan room link: %w", err)
+		}
+		links = append(links, link)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating room links: %w", err)
+	}
+
+	return links, nil
+}
+
+// RevokeLink disconnects the clients whose access to a room came from a
+// revoked share link
+func (ws *WebSocketService) RevokeLink(roomID, linkID string) {
+	ws.mu.RLock()
+	room, exists := ws.rooms[roomID]
+	ws.mu.RUnlock()
+
+	if !exists {
+		return
+	}
+
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	for _, clients := range []map[*Client]bool{room.Clients, room.CRDTClients} {
+		for client := range clients {
+			if client.link != nil && client.link.ID == linkID {
+				log.Printf("🔒 Link %s to room %s revoked, disconnecting %s", linkID, roomID, client.remoteAddr)
+				client.Close(websocket.ClosePolicyViolation, "link revoked")
+			}
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Room member roles. Owners manage the room and its members, editors change
+// its content and viewers only read it.
+const (
+	RoleOwner  = "owner"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+// roleRanks orders the roles; each may do everything the ones below it may
+var roleRanks = map[string]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleOwner:  3,
+}
+
+// ValidRole reports whether role is one of the member roles
+func ValidRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
+
+// RoleAllows reports whether role grants at least the access of need. The
+// empty role, held by users who are not members, grants nothing.
+func RoleAllows(role, need string) bool {
+	return ValidRole(role) && roleRanks[role] >= roleRanks[need]
+}
+
+// RoomMember is a user's role in a room
+type RoomMember struct {
+	RoomID    string    `json:"room_id"`
+	UserUID   string    `json:"user_uid"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// RoomRole returns a user's role in a room, or "" when they have no access.
+// Nobody has access to a room without members but through a share link.
+func RoomRole(ctx context.Context, store Store, roomID, userUID string) (string, error) {
+	members, err := store.GetRoomMembers(ctx, roomID)
+	if err != nil {
+		return "", err
+	}
+	for _, member := range members {
+		if userUID != "" && member.UserUID == userUID {
+			return member.Role, nil
+		}
+	}
+	return "", nil
+}
+
+// ClaimRoom makes a user the owner of a room without members, such as a room
+// created before rooms had owners, which nobody could open otherwise. It
+// fails with ErrRoomClaimed when the room already has members.
+func ClaimRoom(ctx context.Context, store Store, roomID, userUID string) error {
+	if _, err := store.GetRoom(ctx, roomID); err != nil {
+		return err
+	}
+	members, err := store.GetRoomMembers(ctx, roomID)
+	if err != nil {
+		return err
+	}
+	if len(members) > 0 {
+		return ErrRoomClaimed
+	}
+	_, err = store.SetRoomMember(ctx, roomID, userUID, RoleOwner)
+	return err
+}
+
+// scanRoomMembers reads the rows of a room member query and closes them
+func scanRoomMembers(rows *sql.Rows) ([]*RoomMember, error) {
+	defer rows.Close()
+
+	members := []*RoomMember{}
+	for rows.Next() {
+		member := &RoomMember{}
+		if err := rows.Scan(&member.RoomID, &member.UserUID, &member.Role, &member.CreatedAt, &member.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan room member: %w", err)
+		}
+		members = append(members, member)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating room members: %w", err)
+	}
+
+	return members, nil
+}
+
+// openRoom checks that a user may join a room and returns the room with their
+// role and the link it came from. A room that does not exist yet is created
+// with the user as its owner, but only for a user with a uid, so a refused
+// join never takes a room ID. It returns false once the request has been
+// answered.
+func (ws *WebSocketService) openRoom(w http.ResponseWriter, r *http.Request, store Store, roomID, userUID string) (*Room, string, *RoomLink, bool) {
+	_, err := store.GetRoom(r.Context(), roomID)
+	exists := err == nil
+	if err != nil && !errors.Is(err, ErrRoomNotFound) {
+		log.Printf("❌ Failed to look up room %s: %v", roomID, err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return nil, "", nil, false
+	}
+	if !exists && userUID == "" {
+		log.Printf("🔒 Refused to create room %s for a caller without a uid", roomID)
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return nil, "", nil, false
+	}
+
+	// Members of an existing room are checked before it is touched; the
+	// creator of a new one is its owner, unless someone else created it first
+	var role string
+	var link *RoomLink
+	if exists {
+		var ok bool
+		if role, link, ok = ws.joinRole(w, r, store, roomID, userUID); !ok {
+			return nil, "", nil, false
+		}
+	}
+
+	room, err := store.EnsureRoomExists(r.Context(), roomID, userUID)
+	if errors.Is(err, ErrRoomDeleted) {
+		log.Printf("🗑️ Connection attempt to deleted room %s", roomID)
+		http.Error(w, "Room deleted", http.StatusGone)
+		return nil, "", nil, false
+	}
+	if err != nil {
+		log.Printf("❌ Failed to ensure room exists: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return nil, "", nil, false
+	}
+
+	if !exists {
+		var ok bool
+		if role, link, ok = ws.joinRole(w, r, store, roomID, userUID); !ok {
+			return nil, "", nil, false
+		}
+	}
+	return room, role, link, true
+}
+
+// joinRole looks up the role of a user connecting to a room, raised by the
+// share link in the token query parameter, and refuses the handshake when
+// they have none. It returns the link when the role came from it, and false
+// once the request has been answered.
+func (ws *WebSocketService) joinRole(w http.ResponseWriter, r *http.Request, store Store, roomID, userUID string) (string, *RoomLink, bool) {
+	role, err := RoomRole(r.Context(), store, roomID, userUID)
+	if err != nil {
+		log.Printf("❌ Failed to look up role of %q in room %s: %v", userUID, roomID, err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return "", nil, false
+	}
+
+	var link *RoomLink
+	if token := r.URL.Query().Get("token"); token != "" {
+		link, err = UseShareLink(r.Context(), store, roomID, token)
+		if errors.Is(err, ErrLinkInvalid) {
+			log.Printf("🔒 Refused connection to room %s with an invalid link", roomID)
+			http.Error(w, "Invalid or expired link", http.StatusForbidden)
+			return "", nil, false
+		}
+		if err != nil {
+			log.Printf("❌ Failed to use link to room %s: %v", roomID, err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return "", nil, false
+		}
+		RecordAudit(context.WithoutCancel(r.Context()), store, &AuditEvent{
+			RoomID:     roomID,
+			Action:     AuditLinkUse,
+			ActorUID:   userUID,
+			RemoteAddr: RemoteHost(r),
+			Detail:     LinkUseDetail(link, "websocket"),
+		})
+		if HigherRole(role, link.Role) == role {
+			link = nil
+		} else {
+			role = link.Role
+		}
+	}
+
+	if role == "" {
+		log.Printf("🔒 Refused connection of %q to room %s, not a member", userUID, roomID)
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return "", nil, false
+	}
+	return role, link, true
+}
+
+// UpdateMemberRole applies a changed role to the user's live connections in a
+// room. An empty role means they were removed, and they are disconnected.
+func (ws *WebSocketService) UpdateMemberRole(roomID, userUID, role string) {
+	ws.mu.RLock()
+	room, exists := ws.rooms[roomID]
+	ws.mu.RUnlock()
+
+	if !exists {
+		return
+	}
+
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	for _, clients := range []map[*Client]bool{room.Clients, room.CRDTClients} {
+		for client := range clients {
+			if client.userID != userUID {
+				continue
+			}
+			// A share link still grants what it did
+			role := role
+			if client.link != nil {
+				role = HigherRole(role, client.link.Role)
+			}
+			if role == "" {
+				log.Printf("🔒 Access of %s to room %s revoked, disconnecting", userUID, roomID)
+				client.Close(websocket.ClosePolicyViolation, "access revoked")
+				continue
+			}
+			client.role = role
+		}
+	}
+}
+
+// canEdit reports whether a client may change the document, telling it
+// otherwise. The caller must hold roomManager.mu.
+func (ws *WebSocketService) canEdit(client *Client, roomManager *RoomManager) bool {
+	if RoleAllows(client.role, RoleEditor) {
+		return true
+	}
+	log.Printf("🔒 Rejected edit by %s (%s) in room %s", client.userID, client.role, roomManager.ID)
+	if roomManager.Clients[client] {
+		ws.sendError(client, roomManager, fmt.Errorf("%w: %s role cannot edit", ErrReadOnly, client.role))
+	}
+	return false
+}
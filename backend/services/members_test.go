@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/logoes0/peeriodic.git/config"
+)
+
+func TestRefusedJoinLeavesRoomAlone(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+	}{
+		{"new room without a uid", "?room=new"},
+		{"existing room as a stranger", "?room=owned&uid=bob"},
+		{"existing room without a uid", "?room=owned"},
+	}
+
+	ws := NewWebSocketService(&config.Config{}, nil)
+	handlers := map[string]func(http.ResponseWriter, *http.Request, Store){
+		"/ws":  ws.HandleConnection,
+		"/yjs": ws.HandleYjsConnection,
+	}
+	for path, handle := range handlers {
+		for _, tt := range tests {
+			t.Run(path+" "+tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				store := NewMemoryStore()
+				if _, err := store.EnsureRoomExists(ctx, "owned", "alice"); err != nil {
+					t.Fatalf("EnsureRoomExists() error = %v", err)
+				}
+
+				w := httptest.NewRecorder()
+				handle(w, httptest.NewRequest(http.MethodGet, path+tt.target, nil), store)
+				if w.Code != http.StatusForbidden {
+					t.Fatalf("status = %d, want %d", w.Code, http.StatusForbidden)
+				}
+
+				if _, err := store.GetRoom(ctx, "new"); !errors.Is(err, ErrRoomNotFound) {
+					t.Errorf("GetRoom() of the refused room error = %v, want ErrRoomNotFound", err)
+				}
+				members, err := store.GetRoomMembers(ctx, "owned")
+				if err != nil {
+					t.Fatalf("GetRoomMembers() error = %v", err)
+				}
+				if len(members) != 1 || members[0].UserUID != "alice" {
+					t.Errorf("members = %v, want only alice", members)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,603 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store that keeps everything in process memory. It is meant
+// for tests and throwaway instances; nothing survives a restart. It enforces
+// the same uniqueness and reference rules as the SQL stores. Operations never
+// block, so contexts are ignored.
+type MemoryStore struct {
+	mu       sync.RWMutex
+	nextID   int
+	users    map[string]*User
+	rooms    map[string]*Room
+	versions map[string][]*RoomVersion
+	members  map[string][]*RoomMember
+	links    map[string][]*RoomLink
+	audit    []*AuditEvent
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		users:    make(map[string]*User),
+		rooms:    make(map[string]*Room),
+		versions: make(map[string][]*RoomVersion),
+		members:  make(map[string][]*RoomMember),
+		links:    make(map[string][]*RoomLink),
+	}
+}
+
+// Close is a no-op; the data lives as long as the store
+func (ms *MemoryStore) Close() error {
+	return nil
+}
+
+// CreateUser creates a new user
+func (ms *MemoryStore) CreateUser(_ context.Context, uid, email, name string) (*User, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if _, exists := ms.users[uid]; exists {
+		return nil, fmt.Errorf("failed to create user: user already exists: %s", uid)
+	}
+	if ms.emailTaken(email, uid) {
+		return nil, fmt.Errorf("failed to create user: email already in use: %s", email)
+	}
+
+	now := time.Now().UTC()
+	ms.nextID++
+	user := &User{ID: ms.nextID, UID: uid, Email: email, Name: name, CreatedAt: now, UpdatedAt: now}
+	ms.users[uid] = user
+	return copyUser(user), nil
+}
+
+// GetUserByUID retrieves a user by UID
+func (ms *MemoryStore) GetUserByUID(_ context.Context, uid string) (*User, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	user, ok := ms.users[uid]
+	if !ok {
+		return nil, fmt.Errorf("user not found: %s", uid)
+	}
+	return copyUser(user), nil
+}
+
+// EnsureUserExists creates a user if it doesn't exist, otherwise updates and
+// returns the existing user
+func (ms *MemoryStore) EnsureUserExists(_ context.Context, uid, email, name string) (*User, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if ms.emailTaken(email, uid) {
+		return nil, fmt.Errorf("failed to ensure user exists: email already in use: %s", email)
+	}
+
+	now := time.Now().UTC()
+	user, ok := ms.users[uid]
+	if !ok {
+		ms.nextID++
+		user = &User{ID: ms.nextID, UID: uid, CreatedAt: now}
+		ms.users[uid] = user
+	}
+	user.Email = email
+	user.Name = name
+	user.UpdatedAt = now
+	return copyUser(user), nil
+}
+
+// emailTaken reports whether a user other than uid has email. The caller must
+// hold ms.mu.
+func (ms *MemoryStore) emailTaken(email, uid string) bool {
+	for _, user := range ms.users {
+		if user.Email == email && user.UID != uid {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateRoom creates a new room
+func (ms *MemoryStore) CreateRoom(_ context.Context, id, title string, userUID *string) (*Room, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if _, exists := ms.rooms[id]; exists {
+		return nil, fmt.Errorf("failed to create room: room already exists: %s", id)
+	}
+	if userUID != nil {
+		if _, ok := ms.users[*userUID]; !ok {
+			return nil, fmt.Errorf("failed to create room: user not found: %s", *userUID)
+		}
+	}
+
+	now := time.Now().UTC()
+	room := &Room{ID: id, Title: title, CreatedAt: now, UpdatedAt: now, Version: 1}
+	if userUID != nil {
+		owner := *userUID
+		room.UserUID = &owner
+		ms.members[id] = []*RoomMember{{RoomID: id, UserUID: owner, Role: RoleOwner, CreatedAt: now, UpdatedAt: now}}
+	}
+	ms.rooms[id] = room
+	return copyRoom(room), nil
+}
+
+// GetRoom retrieves a room by ID
+func (ms *MemoryStore) GetRoom(_ context.Context, id string) (*Room, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	room, ok := ms.rooms[id]
+	if !ok || room.DeletedAt != nil {
+		return nil, fmt.Errorf("%w: %s", ErrRoomNotFound, id)
+	}
+	return copyRoom(room), nil
+}
+
+// ListRooms lists a page of a user's rooms without their content
+func (ms *MemoryStore) ListRooms(_ context.Context, userUID string, opts RoomListOptions) ([]*Room, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	var rooms []*Room
+	for _, room := range ms.rooms {
+		if room.UserUID == nil || *room.UserUID != userUID || room.DeletedAt != nil || !opts.matches(room) {
+			continue
+		}
+		listed := copyRoom(room)
+		listed.Content = ""
+		rooms = append(rooms, listed)
+	}
+	sort.Slice(rooms, func(i, j int) bool {
+		return opts.less(opts.CursorFor(rooms[i]), opts.CursorFor(rooms[j]))
+	})
+	if opts.Limit > 0 && len(rooms) > opts.Limit {
+		rooms = rooms[:opts.Limit]
+	}
+	return rooms, nil
+}
+
+// UpdateRoomContent updates the content of a room
+func (ms *MemoryStore) UpdateRoomContent(_ context.Context, id, content string, crdtState []byte) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	room, ok := ms.rooms[id]
+	if !ok || room.DeletedAt != nil {
+		return fmt.Errorf("%w: %s", ErrRoomNotFound, id)
+	}
+	room.Content = content
+	if crdtState != nil {
+		room.CRDTState = crdtState
+	}
+	room.Version++
+	room.UpdatedAt = time.Now().UTC()
+	return nil
+}
+
+// SaveRoomContent writes content and returns the room's new version. With a
+// nonzero ifVersion the write only happens while the room is at that version.
+// The content it replaces is kept as a room version.
+func (ms *MemoryStore) SaveRoomContent(_ context.Context, id, content string, ifVersion int64) (int64, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	room, ok := ms.rooms[id]
+	if !ok || room.DeletedAt != nil {
+		return 0, fmt.Errorf("%w: %s", ErrRoomNotFound, id)
+	}
+	if ifVersion != 0 && room.Version != ifVersion {
+		return room.Version, fmt.Errorf("%w: room %s is at version %d", ErrVersionMismatch, id, room.Version)
+	}
+	ms.addVersion(id, room.Content)
+	room.Content = content
+	room.Version++
+	room.UpdatedAt = time.Now().UTC()
+	return room.Version, nil
+}
+
+// DeleteRoom moves a room to the trash
+func (ms *MemoryStore) DeleteRoom(_ context.Context, id string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	room, ok := ms.rooms[id]
+	if !ok || room.DeletedAt != nil {
+		return fmt.Errorf("%w: %s", ErrRoomNotFound, id)
+	}
+	now := time.Now().UTC()
+	room.DeletedAt = &now
+	return nil
+}
+
+// RestoreRoom takes a room back out of the trash
+func (ms *MemoryStore) RestoreRoom(_ context.Context, id string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	room, ok := ms.rooms[id]
+	if !ok || room.DeletedAt == nil {
+		return fmt.Errorf("%w in trash: %s", ErrRoomNotFound, id)
+	}
+	room.DeletedAt = nil
+	return nil
+}
+
+// GetTrashedRoomsByUser lists a user's rooms in the trash, most recently
+// deleted first
+func (ms *MemoryStore) GetTrashedRoomsByUser(_ context.Context, userUID string) ([]*Room, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	var rooms []*Room
+	for _, room := range ms.rooms {
+		if room.UserUID != nil && *room.UserUID == userUID && room.DeletedAt != nil {
+			rooms = append(rooms, copyRoom(room))
+		}
+	}
+	sort.Slice(rooms, func(i, j int) bool {
+		return rooms[i].DeletedAt.After(*rooms[j].DeletedAt)
+	})
+	return rooms, nil
+}
+
+// PurgeDeletedRooms permanently deletes rooms moved to the trash before the
+// cutoff, with their versions
+func (ms *MemoryStore) PurgeDeletedRooms(_ context.Context, before time.Time) (int64, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	var purged int64
+	for id, room := range ms.rooms {
+		if room.DeletedAt != nil && room.DeletedAt.Before(before) {
+			delete(ms.rooms, id)
+			delete(ms.versions, id)
+			delete(ms.members, id)
+			delete(ms.links, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// SearchRooms finds a user's rooms containing every word of the query
+func (ms *MemoryStore) SearchRooms(_ context.Context, userUID, query string, limit int) ([]*SearchResult, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	var results []*SearchResult
+	for _, room := range ms.rooms {
+		if room.UserUID == nil || *room.UserUID != userUID || room.DeletedAt != nil {
+			continue
+		}
+		if result, ok := matchRoom(room, terms); ok {
+			results = append(results, result)
+		}
+	}
+	return rankResults(results, limit), nil
+}
+
+// EnsureRoomExists creates a room owned by ownerUID if it doesn't exist,
+// otherwise returns the existing room
+func (ms *MemoryStore) EnsureRoomExists(_ context.Context, id, ownerUID string) (*Room, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	room, ok := ms.rooms[id]
+	if !ok {
+		now := time.Now().UTC()
+		room = &Room{ID: id, Title: "Untitled Room", CreatedAt: now, UpdatedAt: now, Version: 1}
+		if ownerUID != "" {
+			if _, signedUp := ms.users[ownerUID]; signedUp {
+				owner := ownerUID
+				room.UserUID = &owner
+			}
+			ms.members[id] = []*RoomMember{{RoomID: id, UserUID: ownerUID, Role: RoleOwner, CreatedAt: now, UpdatedAt: now}}
+		}
+		ms.rooms[id] = room
+	}
+	if room.DeletedAt != nil {
+		return nil, fmt.Errorf("%w: %s", ErrRoomDeleted, id)
+	}
+	return copyRoom(room), nil
+}
+
+// CreateRoomVersion stores content as the room's next version. It returns nil
+// without storing anything when content matches the latest version.
+func (ms *MemoryStore) CreateRoomVersion(_ context.Context, roomID, content string) (*RoomVersion, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if _, ok := ms.rooms[roomID]; !ok {
+		return nil, fmt.Errorf("failed to create room version: %w: %s", ErrRoomNotFound, roomID)
+	}
+
+	version := ms.addVersion(roomID, content)
+	if version == nil {
+		return nil, nil
+	}
+	v := *version
+	return &v, nil
+}
+
+// addVersion is CreateRoomVersion for callers holding ms.mu
+func (ms *MemoryStore) addVersion(roomID, content string) *RoomVersion {
+	versions := ms.versions[roomID]
+	next := 1
+	if len(versions) > 0 {
+		latest := versions[len(versions)-1]
+		if latest.Content == content {
+			return nil
+		}
+		next = latest.Version + 1
+	}
+
+	version := &RoomVersion{
+		RoomID:    roomID,
+		Version:   next,
+		Content:   content,
+		Size:      len(content),
+		CreatedAt: time.Now().UTC(),
+	}
+	ms.versions[roomID] = append(versions, version)
+	return version
+}
+
+// GetRoomVersions lists a room's versions, newest first, without their content
+func (ms *MemoryStore) GetRoomVersions(_ context.Context, roomID string) ([]*RoomVersion, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	stored := ms.versions[roomID]
+	versions := make([]*RoomVersion, 0, len(stored))
+	for i := len(stored) - 1; i >= 0; i-- {
+		v := *stored[i]
+		v.Content = ""
+		versions = append(versions, &v)
+	}
+	return versions, nil
+}
+
+// GetRoomVersion retrieves one version of a room
+func (ms *MemoryStore) GetRoomVersion(_ context.Context, roomID string, version int) (*RoomVersion, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	for _, stored := range ms.versions[roomID] {
+		if stored.Version == version {
+			v := *stored
+			return &v, nil
+		}
+	}
+	return nil, fmt.Errorf("room version not found: %s@%d", roomID, version)
+}
+
+// GetRoomMembers lists a room's members, oldest first
+func (ms *MemoryStore) GetRoomMembers(_ context.Context, roomID string) ([]*RoomMember, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	members := make([]*RoomMember, 0, len(ms.members[roomID]))
+	for _, member := range ms.members[roomID] {
+		copied := *member
+		members = append(members, &copied)
+	}
+	return members, nil
+}
+
+// SetRoomMember adds a member or changes their role unless that would leave
+// the room without an owner
+func (ms *MemoryStore) SetRoomMember(_ context.Context, roomID, userUID, role string) (*RoomMember, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if _, ok := ms.rooms[roomID]; !ok {
+		return nil, fmt.Errorf("failed to set room member: %w: %s", ErrRoomNotFound, roomID)
+	}
+	if role != RoleOwner && !ms.hasOtherOwner(roomID, userUID) {
+		return nil, ErrLastOwner
+	}
+
+	now := time.Now().UTC()
+	member := ms.findMember(roomID, userUID)
+	if member == nil {
+		member = &RoomMember{RoomID: roomID, UserUID: userUID, CreatedAt: now}
+		ms.members[roomID] = append(ms.members[roomID], member)
+	}
+	member.Role = role
+	member.UpdatedAt = now
+
+	copied := *member
+	return &copied, nil
+}
+
+// RemoveRoomMember removes a member from a room unless they are its last
+// owner
+func (ms *MemoryStore) RemoveRoomMember(_ context.Context, roomID, userUID string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	member := ms.findMember(roomID, userUID)
+	if member == nil {
+		return fmt.Errorf("room member not found: %s in %s", userUID, roomID)
+	}
+	if member.Role == RoleOwner && !ms.hasOtherOwner(roomID, userUID) {
+		return ErrLastOwner
+	}
+
+	members := ms.members[roomID]
+	for i, m := range members {
+		if m == member {
+			ms.members[roomID] = append(members[:i:i], members[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// CreateRoomLink stores a share link
+func (ms *MemoryStore) CreateRoomLink(_ context.Context, link *RoomLink) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if _, ok := ms.rooms[link.RoomID]; !ok {
+		return fmt.Errorf("failed to create room link: %w: %s", ErrRoomNotFound, link.RoomID)
+	}
+
+	link.Uses = 0
+	link.CreatedAt = time.Now().UTC()
+	stored := *link
+	stored.Token = ""
+	ms.links[link.RoomID] = append(ms.links[link.RoomID], &stored)
+	return nil
+}
+
+// GetRoomLinks lists a room's share links, newest first
+func (ms *MemoryStore) GetRoomLinks(_ context.Context, roomID string) ([]*RoomLink, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	stored := ms.links[roomID]
+	links := make([]*RoomLink, 0, len(stored))
+	for i := len(stored) - 1; i >= 0; i-- {
+		links = append(links, copyRoomLink(stored[i]))
+	}
+	return links, nil
+}
+
+// DeleteRoomLink revokes a share link
+func (ms *MemoryStore) DeleteRoomLink(_ context.Context, roomID, linkID string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	links := ms.links[roomID]
+	for i, link := range links {
+		if link.ID == linkID {
+			ms.links[roomID] = append(links[:i:i], links[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("room link not found: %s", linkID)
+}
+
+// UseRoomLink counts a use of a room's link while it is unexpired and has
+// uses left
+func (ms *MemoryStore) UseRoomLink(_ context.Context, roomID, tokenHash string) (*RoomLink, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	for _, link := range ms.links[roomID] {
+		if link.TokenHash != tokenHash {
+			continue
+		}
+		if link.ExpiresAt != nil && !link.ExpiresAt.After(time.Now()) {
+			break
+		}
+		if link.MaxUses > 0 && link.Uses >= link.MaxUses {
+			break
+		}
+		link.Uses++
+		return copyRoomLink(link), nil
+	}
+	return nil, ErrLinkInvalid
+}
+
+// findMember returns a room's member record for a user, or nil. The caller
+// must hold ms.mu.
+func (ms *MemoryStore) findMember(roomID, userUID string) *RoomMember {
+	for _, member := range ms.members[roomID] {
+		if member.UserUID == userUID {
+			return member
+		}
+	}
+	return nil
+}
+
+// hasOtherOwner reports whether someone besides userUID owns the room. The
+// caller must hold ms.mu.
+func (ms *MemoryStore) hasOtherOwner(roomID, userUID string) bool {
+	for _, member := range ms.members[roomID] {
+		if member.Role == RoleOwner && member.UserUID != userUID {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateAuditEvent adds an event to the audit log
+func (ms *MemoryStore) CreateAuditEvent(_ context.Context, event *AuditEvent) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	event.ID = int64(len(ms.audit) + 1)
+	event.CreatedAt = time.Now().UTC()
+	stored := *event
+	ms.audit = append(ms.audit, &stored)
+	return nil
+}
+
+// GetAuditEvents lists a room's audit events, newest first
+func (ms *MemoryStore) GetAuditEvents(_ context.Context, roomID string, query AuditQuery) ([]*AuditEvent, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	events := []*AuditEvent{}
+	for i := len(ms.audit) - 1; i >= 0; i-- {
+		stored := ms.audit[i]
+		if stored.RoomID != roomID ||
+			(!query.Since.IsZero() && stored.CreatedAt.Before(query.Since)) ||
+			(!query.Until.IsZero() && !stored.CreatedAt.Before(query.Until)) {
+			continue
+		}
+		event := *stored
+		events = append(events, &event)
+		if query.Limit > 0 && len(events) == query.Limit {
+			break
+		}
+	}
+	return events, nil
+}
+
+// copyUser returns a copy callers may modify without touching the store
+func copyUser(user *User) *User {
+	u := *user
+	return &u
+}
+
+// copyRoom returns a copy callers may modify without touching the store
+func copyRoom(room *Room) *Room {
+	r := *room
+	if room.UserUID != nil {
+		owner := *room.UserUID
+		r.UserUID = &owner
+	}
+	if room.DeletedAt != nil {
+		deletedAt := *room.DeletedAt
+		r.DeletedAt = &deletedAt
+	}
+	r.CRDTState = slices.Clone(room.CRDTState)
+	return &r
+}
+
+// copyRoomLink returns a copy callers may modify without touching the store
+func copyRoomLink(link *RoomLink) *RoomLink {
+	l := *link
+	if link.ExpiresAt != nil {
+		expiresAt := *link.ExpiresAt
+		l.ExpiresAt = &expiresAt
+	}
+	return &l
+}
@@ -0,0 +1,252 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/logoes0/peeriodic.git/config"
+)
+
+// roomPersister saves a room's document in the background. Edits only record
+// the latest content; a single worker per room writes it once the edits have
+// settled for the configured interval or enough of them have piled up, so
+// rapid typing turns into a handful of ordered writes instead of one racing
+// goroutine per keystroke. Every snapshot interval, and when the room closes,
+// the saved content is also kept as a new version.
+type roomPersister struct {
+	ctx              context.Context
+	roomID           string
+	store            Store
+	interval         time.Duration
+	maxPending       int
+	maxBackoff       time.Duration
+	snapshotInterval time.Duration
+	// encodeState returns the room's Yjs state to save with its content, or
+	// nil to keep the saved one
+	encodeState func() []byte
+
+	mu      sync.Mutex
+	content string
+	dirty   bool
+	pending int
+
+	// writeMu serialises writes so a newer document is never overwritten by
+	// an older one. It also guards the snapshot state below.
+	writeMu      sync.Mutex
+	saved        string
+	unversioned  bool
+	lastSnapshot time.Time
+
+	wake     chan struct{}
+	full     chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// newRoomPersister creates a persister for a room loaded with content and
+// starts its worker. Its writes are abandoned once ctx is cancelled.
+func newRoomPersister(ctx context.Context, roomID, content string, store Store, cfg config.PersistenceConfig, encodeState func() []byte) *roomPersister {
+	p := &roomPersister{
+		ctx:              ctx,
+		roomID:           roomID,
+		store:            store,
+		interval:         cfg.Interval,
+		maxPending:       cfg.MaxPending,
+		maxBackoff:       max(cfg.MaxBackoff, cfg.Interval, 100*time.Millisecond),
+		snapshotInterval: cfg.SnapshotInterval,
+		encodeState:      encodeState,
+		saved:            content,
+		unversioned:      true,
+		wake:             make(chan struct{}, 1),
+		full:             make(chan struct{}, 1),
+		stop:             make(chan struct{}),
+		done:             make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+// schedule records content as the latest state of the room. It never blocks
+// on the database.
+func (p *roomPersister) schedule(content string) {
+	p.mu.Lock()
+	p.content = content
+	p.dirty = true
+	p.pending++
+	full := p.maxPending > 0 && p.pending >= p.maxPending
+	p.mu.Unlock()
+
+	signal(p.wake)
+	if full {
+		signal(p.full)
+	}
+}
+
+// signal wakes a worker without blocking when it is already awake
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// flush writes the latest content if it has not been saved yet and reports
+// whether the room is now saved. On failure the content stays pending unless
+// a newer version has arrived in the meantime.
+func (p *roomPersister) flush() bool {
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+	return p.flushLocked()
+}
+
+// flushLocked is flush for callers already holding p.writeMu. It reads the
+// room's Yjs state, so the caller must not hold the room's lock.
+func (p *roomPersister) flushLocked() bool {
+	p.mu.Lock()
+	if !p.dirty {
+		p.mu.Unlock()
+		return true
+	}
+	content, pending := p.content, p.pending
+	p.dirty = false
+	p.pending = 0
+	p.mu.Unlock()
+
+	err := p.store.UpdateRoomContent(p.ctx, p.roomID, content, p.encodeState())
+	if err == nil {
+		log.Printf("💾 Persisted room %s (%d edits, %d bytes)", p.roomID, pending, len(content))
+		p.saved = content
+		p.unversioned = true
+		if time.Since(p.lastSnapshot) >= p.snapshotInterval {
+			p.snapshot()
+		}
+		return true
+	}
+
+	// A deleted room will never accept the write, so retrying is pointless
+	if errors.Is(err, ErrRoomNotFound) {
+		log.Printf("⚠️ Dropping changes for room %s: %v", p.roomID, err)
+		return true
+	}
+
+	log.Printf("❌ Failed to persist room %s: %v", p.roomID, err)
+	p.mu.Lock()
+	if !p.dirty {
+		p.content = content
+		p.dirty = true
+	}
+	p.pending += pending
+	p.mu.Unlock()
+	return false
+}
+
+// saveNow writes content straight away, after any edits still pending, and
+// returns the room's new version. With a nonzero ifVersion it only writes
+// while the saved room is at that version. Edits scheduled meanwhile are
+// written after it.
+func (p *roomPersister) saveNow(ctx context.Context, content string, ifVersion int64) (int64, error) {
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+
+	if !p.flushLocked() {
+		return 0, fmt.Errorf("failed to save pending edits of room %s", p.roomID)
+	}
+	version, err := p.store.SaveRoomContent(ctx, p.roomID, content, ifVersion)
+	if err != nil {
+		return version, err
+	}
+	log.Printf("💾 Saved room %s as version %d (%d bytes)", p.roomID, version, len(content))
+	p.saved = content
+	p.unversioned = true
+	return version, nil
+}
+
+// snapshot keeps the last saved content as a version. The caller must hold
+// p.writeMu.
+func (p *roomPersister) snapshot() {
+	if p.snapshotInterval <= 0 || !p.unversioned {
+		return
+	}
+
+	version, err := p.store.CreateRoomVersion(p.ctx, p.roomID, p.saved)
+	if err != nil {
+		log.Printf("❌ Failed to snapshot room %s: %v", p.roomID, err)
+		return
+	}
+	if version != nil {
+		log.Printf("🗂️ Saved version %d of room %s", version.Version, p.roomID)
+	}
+	p.unversioned = false
+	p.lastSnapshot = time.Now()
+}
+
+// run is the worker loop: wait for an edit, let further edits coalesce, then
+// write with exponential backoff until the write succeeds
+func (p *roomPersister) run() {
+	defer close(p.done)
+
+	// Keep the content the room was loaded with, so even the first edit of a
+	// session can be undone
+	p.writeMu.Lock()
+	p.snapshot()
+	p.writeMu.Unlock()
+
+	for {
+		select {
+		case <-p.wake:
+		case <-p.stop:
+			p.finalFlush()
+			return
+		}
+
+		timer := time.NewTimer(p.interval)
+		select {
+		case <-timer.C:
+		case <-p.full:
+			timer.Stop()
+		case <-p.stop:
+			timer.Stop()
+			p.finalFlush()
+			return
+		}
+
+		backoff := max(p.interval, 100*time.Millisecond)
+		for !p.flush() {
+			log.Printf("🔁 Retrying persistence for room %s in %s", p.roomID, backoff)
+			timer := time.NewTimer(backoff)
+			select {
+			case <-timer.C:
+			case <-p.stop:
+				timer.Stop()
+				p.finalFlush()
+				return
+			}
+			backoff = min(backoff*2, p.maxBackoff)
+		}
+	}
+}
+
+// finalFlush makes a last attempt to save the room and keep its final
+// content as a version before the worker exits
+func (p *roomPersister) finalFlush() {
+	if !p.flush() {
+		log.Printf("❌ Room %s closed with unsaved changes", p.roomID)
+	}
+
+	p.writeMu.Lock()
+	p.snapshot()
+	p.writeMu.Unlock()
+}
+
+// close stops the worker after a final write and waits for it to finish
+func (p *roomPersister) close() {
+	p.stopOnce.Do(func() {
+		close(p.stop)
+	})
+	<-p.done
+}
@@ -0,0 +1,793 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	_ "github.com/lib/pq"
+	"github.com/logoes0/peeriodic.git/config"
+	"github.com/logoes0/peeriodic.git/migrations"
+)
+
+// PostgresStore is the Store backed by PostgreSQL
+type PostgresStore struct {
+	db           *sql.DB
+	queryTimeout time.Duration
+}
+
+// NewPostgresStore connects to the PostgreSQL database described by cfg and
+// brings its schema up to date
+func NewPostgresStore(cfg *config.Config) (*PostgresStore, error) {
+	db, err := openPostgres(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := prepareSchema(db, migrations.DialectPostgres, cfg.Database.AutoMigrate); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &PostgresStore{db: db, queryTimeout: cfg.Database.QueryTimeout}, nil
+}
+
+// openPostgres opens and pings the PostgreSQL database described by cfg
+func openPostgres(cfg *config.Config) (*sql.DB, error) {
+	db, err := sql.Open("postgres", cfg.GetDatabaseConnectionString())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database connection: %w", err)
+	}
+
+	// Configure connection pool
+	db.SetMaxOpenConns(cfg.Database.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.Database.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.Database.ConnMaxLifetime)
+
+	// Test the connection
+	ctx, cancel := withQueryTimeout(context.Background(), cfg.Database.QueryTimeout)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	log.Println("✅ Database connection established successfully")
+	return db, nil
+}
+
+// Close closes the database connection
+func (ps *PostgresStore) Close() error {
+	return ps.db.Close()
+}
+
+// queryContext bounds a query by the default query timeout
+func (ps *PostgresStore) queryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withQueryTimeout(ctx, ps.queryTimeout)
+}
+
+// CreateUser creates a new user in the database
+func (ps *PostgresStore) CreateUser(ctx context.Context, uid, email, name string) (*User, error) {
+	ctx, cancel := ps.queryContext(ctx)
+	defer cancel()
+	query := `
+		INSERT INTO users (uid, email, name, created_at, updated_at) 
+		VALUES ($1, $2, $3, NOW(), NOW()) 
+		RETURNING id, uid, email, name, created_at, updated_at
+	`
+
+	user := &User{}
+	err := ps.db.QueryRowContext(ctx, query, uid, email, name).Scan(
+		&user.ID, &user.UID, &user.Email, &user.Name, &user.CreatedAt, &user.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	return user, nil
+}
+
+// GetUserByUID retrieves a user by UID
+func (ps *PostgresStore) GetUserByUID(ctx context.Context, uid string) (*User, error) {
+	ctx, cancel := ps.queryContext(ctx)
+	defer cancel()
+	query := `
+		SELECT id, uid, email, name, created_at, updated_at 
+		FROM users 
+		WHERE uid = $1
+	`
+
+	user := &User{}
+	err := ps.db.QueryRowContext(ctx, query, uid).Scan(
+		&user.ID, &user.UID, &user.Email, &user.Name, &user.CreatedAt, &user.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("user not found: %s", uid)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return user, nil
+}
+
+// EnsureUserExists creates a user if it doesn't exist, otherwise returns the existing user
+func (ps *PostgresStore) EnsureUserExists(ctx context.Context, uid, email, name string) (*User, error) {
+	ctx, cancel := ps.queryContext(ctx)
+	defer cancel()
+	query := `
+		INSERT INTO users (uid, email, name, created_at, updated_at) 
+		VALUES ($1, $2, $3, NOW(), NOW()) 
+		ON CONFLICT (uid) DO UPDATE SET 
+			email = EXCLUDED.email,
+			name = EXCLUDED.name,
+			updated_at = NOW()
+		RETURNING id, uid, email, name, created_at, updated_at
+	`
+
+	user := &User{}
+	err := ps.db.QueryRowContext(ctx, query, uid, email, name).Scan(
+		&user.ID, &user.UID, &user.Email, &user.Name, &user.CreatedAt, &user.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to ensure user exists: %w", err)
+	}
+
+	return user, nil
+}
+
+// CreateRoom creates a new room in the database
+func (ps *PostgresStore) CreateRoom(ctx context.Context, id, title string, userUID *string) (*Room, error) {
+	ctx, cancel := ps.queryContext(ctx)
+	defer cancel()
+	query := `
+		INSERT INTO rooms (id, title, user_uid, content, created_at, updated_at) 
+		VALUES ($1, $2, $3, '', NOW(), NOW()) 
+		RETURNING id, title, content, user_uid, created_at, updated_at, deleted_at, version
+	`
+
+	room := &Room{}
+	err := ps.db.QueryRowContext(ctx, query, id, title, userUID).Scan(
+		&room.ID, &room.Title, &room.Content, &room.UserUID, &room.CreatedAt, &room.UpdatedAt, &room.DeletedAt, &room.Version,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create room: %w", err)
+	}
+
+	return room, nil
+}
+
+// GetRoom retrieves a room by ID
+func (ps *PostgresStore) GetRoom(ctx context.Context, id string) (*Room, error) {
+	ctx, cancel := ps.queryContext(ctx)
+	defer cancel()
+	query := `
+		SELECT id, title, content, user_uid, created_at, updated_at, deleted_at, version
+		FROM rooms 
+		WHERE id = $1 AND deleted_at IS NULL
+	`
+
+	room := &Room{}
+	err := ps.db.QueryRowContext(ctx, query, id).Scan(
+		&room.ID, &room.Title, &room.Content, &room.UserUID, &room.CreatedAt, &room.UpdatedAt, &room.DeletedAt, &room.Version,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s", ErrRoomNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get room: %w", err)
+	}
+
+	return room, nil
+}
+
+// ListRooms lists a page of a user's rooms without their content
+func (ps *PostgresStore) ListRooms(ctx context.Context, userUID string, opts RoomListOptions) ([]*Room, error) {
+	ctx, cancel := ps.queryContext(ctx)
+	defer cancel()
+	query, args := roomListQuery(userUID, opts,
+		func(n int) string { return fmt.Sprintf("$%d", n) },
+		func(t time.Time) any { return t },
+	)
+
+	rows, err := ps.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rooms: %w", err)
+	}
+	defer rows.Close()
+
+	var rooms []*Room
+	for rows.Next() {
+		room := &Room{}
+		err := rows.Scan(
+			&room.ID, &room.Title, &room.UserUID, &room.CreatedAt, &room.UpdatedAt, &room.DeletedAt, &room.Version,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan room: %w", err)
+		}
+		rooms = append(rooms, room)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rooms: %w", err)
+	}
+
+	return rooms, nil
+}
+
+// UpdateRoomContent updates the content of a room
+func (ps *PostgresStore) UpdateRoomContent(ctx context.Context, id, content string, crdtState []byte) error {
+	ctx, cancel := ps.queryContext(ctx)
+	defer cancel()
+	query := `
+		UPDATE rooms 
+		SET content = $1, crdt_state = COALESCE($3::BYTEA, crdt_state), version = version + 1, updated_at = NOW() 
+		WHERE id = $2 AND deleted_at IS NULL
+	`
+
+	log.Printf("Executing update query for room %s with content length %d", id, len(content))
+	result, err := ps.db.ExecContext(ctx, query, content, id, nullBytes(crdtState))
+	if err != nil {
+		log.Printf("Database error updating room %s: %v", id, err)
+		return fmt.Errorf("failed to update room content: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("Error getting rows affected for room %s: %v", id, err)
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	log.Printf("Updated %d rows for room %s", rowsAffected, id)
+	if rowsAffected == 0 {
+		log.Printf("No rows affected for room %s - room may not exist", id)
+		return fmt.Errorf("%w: %s", ErrRoomNotFound, id)
+	}
+
+	return nil
+}
+
+// SaveRoomContent writes content and returns the room's new version. With a
+// nonzero ifVersion the write only happens while the room is at that version.
+// The content it replaces is kept as a room version in the same transaction.
+func (ps *PostgresStore) SaveRoomContent(ctx context.Context, id, content string, ifVersion int64) (int64, error) {
+	ctx, cancel := ps.queryContext(ctx)
+	defer cancel()
+
+	tx, err := ps.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to save room content: %w", err)
+	}
+	defer tx.Rollback()
+
+	var current string
+	var version int64
+	err = tx.QueryRowContext(ctx,
+		`SELECT content, version FROM rooms WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, id,
+	).Scan(&current, &version)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("%w: %s", ErrRoomNotFound, id)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to save room content: %w", err)
+	}
+	if ifVersion != 0 && version != ifVersion {
+		return version, fmt.Errorf("%w: room %s is at version %d", ErrVersionMismatch, id, version)
+	}
+
+	if _, err := tx.ExecContext(ctx, postgresInsertRoomVersion, id, current); err != nil {
+		return 0, fmt.Errorf("failed to keep replaced content: %w", err)
+	}
+
+	query := `
+		UPDATE rooms
+		SET content = $1, version = version + 1, updated_at = NOW()
+		WHERE id = $2
+		RETURNING version
+	`
+	if err := tx.QueryRowContext(ctx, query, content, id).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to save room content: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to save room content: %w", err)
+	}
+
+	return version, nil
+}
+
+// DeleteRoom moves a room to the trash
+func (ps *PostgresStore) DeleteRoom(ctx context.Context, id string) error {
+	ctx, cancel := ps.queryContext(ctx)
+	defer cancel()
+	query := `UPDATE rooms SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL`
+
+	result, err := ps.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete room: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%w: %s", ErrRoomNotFound, id)
+	}
+
+	return nil
+}
+
+// EnsureRoomExists creates a room owned by ownerUID if it doesn't exist,
+// otherwise returns the existing room
+func (ps *PostgresStore) EnsureRoomExists(ctx context.Context, id, ownerUID string) (*Room, error) {
+	ctx, cancel := ps.queryContext(ctx)
+	defer cancel()
+	// xmax is 0 only on a row this statement inserted
+	query := `
+		WITH room AS (
+			INSERT INTO rooms (id, title, content, user_uid, created_at, updated_at) 
+			VALUES ($1, 'Untitled Room', '', (SELECT uid FROM users WHERE uid = $2::VARCHAR), NOW(), NOW()) 
+			ON CONFLICT (id) DO UPDATE SET id = EXCLUDED.id 
+			RETURNING id, title, content, user_uid, created_at, updated_at, deleted_at, version, crdt_state, xmax = 0 AS created
+		), owner AS (
+			INSERT INTO room_members (room_id, user_uid, role, created_at, updated_at)
+			SELECT id, $2::VARCHAR, 'owner', created_at, created_at FROM room
+			WHERE created AND $2::VARCHAR <> ''
+			ON CONFLICT DO NOTHING
+		)
+		SELECT id, title, content, user_uid, created_at, updated_at, deleted_at, version, crdt_state FROM room
+	`
+
+	log.Printf("Ensuring room exists: %s", id)
+	room := &Room{}
+	err := ps.db.QueryRowContext(ctx, query, id, ownerUID).Scan(
+		&room.ID, &room.Title, &room.Content, &room.UserUID, &room.CreatedAt, &room.UpdatedAt, &room.DeletedAt, &room.Version, &room.CRDTState,
+	)
+	if err != nil {
+		log.Printf("Failed to ensure room exists for %s: %v", id, err)
+		return nil, fmt.Errorf("failed to ensure room exists: %w", err)
+	}
+	if room.DeletedAt != nil {
+		return nil, fmt.Errorf("%w: %s", ErrRoomDeleted, id)
+	}
+
+	log.Printf("Room %s exists with content length: %d", id, len(room.Content))
+	return room, nil
+}
+
+// RestoreRoom takes a room back out of the trash
+func (ps *PostgresStore) RestoreRoom(ctx context.Context, id string) error {
+	ctx, cancel := ps.queryContext(ctx)
+	defer cancel()
+	query := `UPDATE rooms SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL`
+
+	result, err := ps.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to restore room: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%w in trash: %s", ErrRoomNotFound, id)
+	}
+
+	return nil
+}
+
+// GetTrashedRoomsByUser lists a user's rooms in the trash, most recently
+// deleted first
+func (ps *PostgresStore) GetTrashedRoomsByUser(ctx context.Context, userUID string) ([]*Room, error) {
+	ctx, cancel := ps.queryContext(ctx)
+	defer cancel()
+	query := `
+		SELECT id, title, content, user_uid, created_at, updated_at, deleted_at, version
+		FROM rooms
+		WHERE user_uid = $1 AND deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC
+	`
+
+	rows, err := ps.db.QueryContext(ctx, query, userUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trashed rooms: %w", err)
+	}
+	defer rows.Close()
+
+	var rooms []*Room
+	for rows.Next() {
+		room := &Room{}
+		err := rows.Scan(
+			&room.ID, &room.Title, &room.Content, &room.UserUID, &room.CreatedAt, &room.UpdatedAt, &room.DeletedAt, &room.Version,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan room: %w", err)
+		}
+		rooms = append(rooms, room)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rooms: %w", err)
+	}
+
+	return rooms, nil
+}
+
+// PurgeDeletedRooms permanently deletes rooms moved to the trash before the
+// cutoff, with their versions
+func (ps *PostgresStore) PurgeDeletedRooms(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := ps.queryContext(ctx)
+	defer cancel()
+	query := `DELETE FROM rooms WHERE deleted_at IS NOT NULL AND deleted_at < $1`
+
+	result, err := ps.db.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge deleted rooms: %w", err)
+	}
+
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return purged, nil
+}
+
+// CreateAuditEvent adds an event to the audit log
+func (ps *PostgresStore) CreateAuditEvent(ctx context.Context, event *AuditEvent) error {
+	ctx, cancel := ps.queryContext(ctx)
+	defer cancel()
+	query := `
+		INSERT INTO audit_events (room_id, action, actor_uid, remote_addr, detail, created_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		RETURNING id, created_at
+	`
+
+	err := ps.db.QueryRowContext(ctx, query,
+		event.RoomID, event.Action, event.ActorUID, event.RemoteAddr, event.Detail,
+	).Scan(&event.ID, &event.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create audit event: %w", err)
+	}
+
+	return nil
+}
+
+// GetAuditEvents lists a room's audit events, newest first
+func (ps *PostgresStore) GetAuditEvents(ctx context.Context, roomID string, query AuditQuery) ([]*AuditEvent, error) {
+	ctx, cancel := ps.queryContext(ctx)
+	defer cancel()
+	sqlQuery, args := auditQuery(roomID, query, func(n int) string { return fmt.Sprintf("$%d", n) },
+		func(t time.Time) any { return t },
+	)
+
+	rows, err := ps.db.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get audit events: %w", err)
+	}
+	return scanAuditEvents(rows)
+}
+
+// GetRoomMembers lists a room's members, oldest first
+func (ps *PostgresStore) GetRoomMembers(ctx context.Context, roomID string) ([]*RoomMember, error) {
+	ctx, cancel := ps.queryContext(ctx)
+	defer cancel()
+	query := `
+		SELECT room_id, user_uid, role, created_at, updated_at
+		FROM room_members
+		WHERE room_id = $1
+		ORDER BY created_at, user_uid
+	`
+
+	rows, err := ps.db.QueryContext(ctx, query, roomID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get room members: %w", err)
+	}
+	return scanRoomMembers(rows)
+}
+
+// SetRoomMember adds a member or changes their role. The change only goes
+// through when the member is made an owner or another owner remains.
+func (ps *PostgresStore) SetRoomMember(ctx context.Context, roomID, userUID, role string) (*RoomMember, error) {
+	ctx, cancel := ps.queryContext(ctx)
+	defer cancel()
+	query := `
+		WITH change (room_id, user_uid, role) AS (
+			VALUES ($1::varchar, $2::varchar, $3::varchar)
+		)
+		INSERT INTO room_members (room_id, user_uid, role, created_at, updated_at)
+		SELECT room_id, user_uid, role, NOW(), NOW() FROM change
+		WHERE role = 'owner' OR EXISTS (
+			SELECT 1 FROM room_members other
+			WHERE other.room_id = change.room_id AND other.role = 'owner' AND other.user_uid <> change.user_uid
+		)
+		ON CONFLICT (room_id, user_uid) DO UPDATE SET role = EXCLUDED.role, updated_at = EXCLUDED.updated_at
+		RETURNING room_id, user_uid, role, created_at, updated_at
+	`
+
+	member := &RoomMember{}
+	err := ps.db.QueryRowContext(ctx, query, roomID, userUID, role).Scan(
+		&member.RoomID, &member.UserUID, &member.Role, &member.CreatedAt, &member.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrLastOwner
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to set room member: %w", err)
+	}
+
+	return member, nil
+}
+
+// RemoveRoomMember removes a member from a room unless they are its last
+// owner
+func (ps *PostgresStore) RemoveRoomMember(ctx context.Context, roomID, userUID string) error {
+	ctx, cancel := ps.queryContext(ctx)
+	defer cancel()
+	query := `
+		DELETE FROM room_members
+		WHERE room_id = $1 AND user_uid = $2 AND (role <> 'owner' OR EXISTS (
+			SELECT 1 FROM room_members other
+			WHERE other.room_id = $1 AND other.role = 'owner' AND other.user_uid <> $2
+		))
+	`
+
+	result, err := ps.db.ExecContext(ctx, query, roomID, userUID)
+	if err != nil {
+		return fmt.Errorf("failed to remove room member: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected > 0 {
+		return nil
+	}
+
+	var exists bool
+	err = ps.db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM room_members WHERE room_id = $1 AND user_uid = $2)`, roomID, userUID,
+	).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check room member: %w", err)
+	}
+	if exists {
+		return ErrLastOwner
+	}
+	return fmt.Errorf("room member not found: %s in %s", userUID, roomID)
+}
+
+// CreateRoomLink stores a share link
+func (ps *PostgresStore) CreateRoomLink(ctx context.Context, link *RoomLink) error {
+	ctx, cancel := ps.queryContext(ctx)
+	defer cancel()
+	query := `
+		INSERT INTO room_links (id, room_id, token_hash, role, created_by, expires_at, max_uses, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+		RETURNING uses, created_at
+	`
+
+	err := ps.db.QueryRowContext(ctx, query,
+		link.ID, link.RoomID, link.TokenHash, link.Role, link.CreatedBy, link.ExpiresAt, link.MaxUses,
+	).Scan(&link.Uses, &link.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create room link: %w", err)
+	}
+
+	return nil
+}
+
+// GetRoomLinks lists a room's share links, newest first
+func (ps *PostgresStore) GetRoomLinks(ctx context.Context, roomID string) ([]*RoomLink, error) {
+	ctx, cancel := ps.queryContext(ctx)
+	defer cancel()
+	query := `
+		SELECT id, room_id, role, created_by, expires_at, max_uses, uses, created_at
+		FROM room_links
+		WHERE room_id = $1
+		ORDER BY created_at DESC, id
+	`
+
+	rows, err := ps.db.QueryContext(ctx, query, roomID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get room links: %w", err)
+	}
+	return scanRoomLinks(rows)
+}
+
+// DeleteRoomLink revokes a share link
+func (ps *PostgresStore) DeleteRoomLink(ctx context.Context, roomID, linkID string) error {
+	ctx, cancel := ps.queryContext(ctx)
+	defer cancel()
+	query := `DELETE FROM room_links WHERE room_id = $1 AND id = $2`
+
+	result, err := ps.db.ExecContext(ctx, query, roomID, linkID)
+	if err != nil {
+		return fmt.Errorf("failed to delete room link: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("room link not found: %s", linkID)
+	}
+
+	return nil
+}
+
+// UseRoomLink counts a use of a room's link while it is unexpired and has
+// uses left
+func (ps *PostgresStore) UseRoomLink(ctx context.Context, roomID, tokenHash string) (*RoomLink, error) {
+	ctx, cancel := ps.queryContext(ctx)
+	defer cancel()
+	query := `
+		UPDATE room_links
+		SET uses = uses + 1
+		WHERE room_id = $1 AND token_hash = $2
+			AND (expires_at IS NULL OR expires_at > NOW())
+			AND (max_uses = 0 OR uses < max_uses)
+		RETURNING id, room_id, role, created_by, expires_at, max_uses, uses, created_at
+	`
+
+	link := &RoomLink{}
+	err := ps.db.QueryRowContext(ctx, query, roomID, tokenHash).Scan(
+		&link.ID, &link.RoomID, &link.Role, &link.CreatedBy, &link.ExpiresAt, &link.MaxUses, &link.Uses, &link.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrLinkInvalid
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to use room link: %w", err)
+	}
+
+	return link, nil
+}
+
+// headlineOptions configures the snippets built by ts_headline. Matches are
+// delimited so markSnippet can escape the text around them.
+var headlineOptions = fmt.Sprintf(
+	`StartSel="%s", StopSel="%s", MaxWords=%d, MinWords=%d, MaxFragments=2, FragmentDelimiter=" … "`,
+	snippetStart, snippetStop, snippetWords, snippetWords/3,
+)
+
+// SearchRooms finds a user's rooms matching a web search style query, ranked
+// with the search_vector index. Titles weigh more than content.
+func (ps *PostgresStore) SearchRooms(ctx context.Context, userUID, query string, limit int) ([]*SearchResult, error) {
+	ctx, cancel := ps.queryContext(ctx)
+	defer cancel()
+	// Snippets are only built for the rows that make the cut
+	sqlQuery := `
+		SELECT id, title, ts_headline('english', content, query, $4), rank, updated_at
+		FROM (
+			SELECT id, title, content, updated_at, query,
+				ts_rank(search_vector, query, 1) AS rank
+			FROM rooms, websearch_to_tsquery('english', $2) AS query
+			WHERE user_uid = $1 AND deleted_at IS NULL AND search_vector @@ query
+			ORDER BY rank DESC, updated_at DESC
+			LIMIT $3
+		) AS matches
+		ORDER BY rank DESC, updated_at DESC
+	`
+
+	rows, err := ps.db.QueryContext(ctx, sqlQuery, userUID, query, limit, headlineOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search rooms: %w", err)
+	}
+	defer rows.Close()
+
+	var results []*SearchResult
+	for rows.Next() {
+		result := &SearchResult{}
+		if err := rows.Scan(&result.ID, &result.Title, &result.Snippet, &result.Rank, &result.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan search result: %w", err)
+		}
+		result.Snippet = markSnippet(result.Snippet)
+		results = append(results, result)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating search results: %w", err)
+	}
+
+	return results, nil
+}
+
+// postgresInsertRoomVersion stores $2 as the next version of room $1 unless
+// it matches the latest one
+const postgresInsertRoomVersion = `
+	WITH latest AS (
+		SELECT version, content FROM room_versions
+		WHERE room_id = $1
+		ORDER BY version DESC
+		LIMIT 1
+	)
+	INSERT INTO room_versions (room_id, version, content, created_at)
+	SELECT $1, COALESCE((SELECT version FROM latest), 0) + 1, $2, NOW()
+	WHERE NOT EXISTS (SELECT 1 FROM latest WHERE content = $2)
+	RETURNING room_id, version, content, created_at
+`
+
+// CreateRoomVersion stores content as the room's next version. It returns nil
+// without storing anything when content matches the latest version.
+func (ps *PostgresStore) CreateRoomVersion(ctx context.Context, roomID, content string) (*RoomVersion, error) {
+	ctx, cancel := ps.queryContext(ctx)
+	defer cancel()
+
+	version := &RoomVersion{}
+	err := ps.db.QueryRowContext(ctx, postgresInsertRoomVersion, roomID, content).Scan(
+		&version.RoomID, &version.Version, &version.Content, &version.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create room version: %w", err)
+	}
+
+	version.Size = len(version.Content)
+	return version, nil
+}
+
+// GetRoomVersions lists a room's versions, newest first, without their content
+func (ps *PostgresStore) GetRoomVersions(ctx context.Context, roomID string) ([]*RoomVersion, error) {
+	ctx, cancel := ps.queryContext(ctx)
+	defer cancel()
+	query := `
+		SELECT room_id, version, OCTET_LENGTH(content), created_at
+		FROM room_versions
+		WHERE room_id = $1
+		ORDER BY version DESC
+	`
+
+	rows, err := ps.db.QueryContext(ctx, query, roomID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get room versions: %w", err)
+	}
+	defer rows.Close()
+
+	versions := []*RoomVersion{}
+	for rows.Next() {
+		version := &RoomVersion{}
+		if err := rows.Scan(&version.RoomID, &version.Version, &version.Size, &version.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan room version: %w", err)
+		}
+		versions = append(versions, version)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating room versions: %w", err)
+	}
+
+	return versions, nil
+}
+
+// GetRoomVersion retrieves one version of a room
+func (ps *PostgresStore) GetRoomVersion(ctx context.Context, roomID string, version int) (*RoomVersion, error) {
+	ctx, cancel := ps.queryContext(ctx)
+	defer cancel()
+	query := `
+		SELECT room_id, version, content, created_at
+		FROM room_versions
+		WHERE room_id = $1 AND version = $2
+	`
+
+	v := &RoomVersion{}
+	err := ps.db.QueryRowContext(ctx, query, roomID, version).Scan(&v.RoomID, &v.Version, &v.Content, &v.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("room version not found: %s@%d", roomID, version)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get room version: %w", err)
+	}
+
+	v.Size = len(v.Content)
+	return v, nil
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"hash/fnv"
+	"log"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/logoes0/peeriodic.git/models"
+)
+
+// presenceColors is the palette used for users that don't pick a colour
+var presenceColors = []string{
+	"#e6194b", "#3cb44b", "#4363d8", "#f58231",
+	"#911eb4", "#42d4f4", "#f032e6", "#9a6324",
+}
+
+// colorPattern matches the #rrggbb colours accepted from clients
+var colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// maxDisplayNameLength caps the display name a client can register
+const maxDisplayNameLength = 64
+
+// peer holds the presence of a connection and its cursor throttling state
+type peer struct {
+	presence       models.Presence
+	lastCursorSent time.Time
+	cursorTimer    *time.Timer
+}
+
+// newPresence builds a connection's presence for the user from the name and
+// color query parameters
+func newPresence(userID string, query url.Values) models.Presence {
+	presence := models.Presence{
+		SessionID: uuid.New().String(),
+		UserID:    userID,
+		Name:      query.Get("name"),
+		Color:     query.Get("color"),
+	}
+
+	if runes := []rune(presence.Name); len(runes) > maxDisplayNameLength {
+		presence.Name = string(runes[:maxDisplayNameLength])
+	}
+	if presence.Name == "" {
+		presence.Name = "Anonymous"
+	}
+	if !colorPattern.MatchString(presence.Color) {
+		key := presence.UserID
+		if key == "" {
+			key = presence.SessionID
+		}
+		h := fnv.New32a()
+		h.Write([]byte(key))
+		presence.Color = presenceColors[h.Sum32()%uint32(len(presenceColors))]
+	}
+	return presence
+}
+
+// peerList returns the presence of everyone in the room except client.
+// The caller must hold rm.mu.
+func (rm *RoomManager) peerList(client *Client) []models.Presence {
+	peers := make([]models.Presence, 0, len(rm.peers))
+	for c, p := range rm.peers {
+		if c != client {
+			peers = append(peers, p.presence)
+		}
+	}
+	return peers
+}
+
+// joinPresence registers a connection's presence, announces it to the room
+// and sends the newcomer a snapshot of who is present. The caller must hold
+// roomManager.mu.
+func (ws *WebSocketService) joinPresence(client *Client, roomManager *RoomManager, presence models.Presence) {
+	roomManager.peers[client] = &peer{presence: presence}
+
+	ws.broadcastLocked(roomManager, models.Message{Type: "join", Presence: &presence, Revision: roomManager.Revision}, client)
+
+	client.SendJSON(models.Message{
+		Type:     "presence",
+		Presence: &presence,
+		Peers:    roomManager.peerList(client),
+		Revision: roomManager.Revision,
+	})
+}
+
+// leavePresence removes a connection's presence and announces that it left.
+// The caller must hold roomManager.mu.
+func (ws *WebSocketService) leavePresence(client *Client, roomManager *RoomManager) {
+	p, exists := roomManager.peers[client]
+	if !exists {
+		return
+	}
+	if p.cursorTimer != nil {
+		p.cursorTimer.Stop()
+	}
+	delete(roomManager.peers, client)
+
+	ws.broadcastLocked(roomManager, models.Message{Type: "leave", Presence: &p.presence, Revision: roomManager.Revision}, client)
+}
+
+// handleCursor records a cursor or selection change and relays it to the
+// room, at most once per throttle interval. Changes arriving faster are
+// coalesced and the latest one is sent when the interval ends.
+func (ws *WebSocketService) handleCursor(client *Client, roomManager *RoomManager, cursor *models.Cursor) {
+	if cursor == nil || cursor.Anchor < 0 || cursor.Head < 0 {
+		log.Printf("⚠️ Ignoring invalid cursor in room %s", roomManager.ID)
+		return
+	}
+
+	roomManager.mu.Lock()
+	defer roomManager.mu.Unlock()
+
+	p, exists := roomManager.peers[client]
+	if !exists {
+		return
+	}
+	p.presence.Cursor = cursor
+
+	throttle := time.Duration(ws.config.WebSocket.CursorThrottleMs) * time.Millisecond
+	if elapsed := time.Since(p.lastCursorSent); elapsed >= throttle {
+		ws.sendCursor(client, roomManager, p)
+	} else if p.cursorTimer == nil {
+		p.cursorTimer = time.AfterFunc(throttle-elapsed, func() {
+			roomManager.mu.Lock()
+			defer roomManager.mu.Unlock()
+
+			p.cursorTimer = nil
+			if roomManager.peers[client] == p {
+				ws.sendCursor(client, roomManager, p)
+			}
+		})
+	}
+}
+
+// sendCursor broadcasts a peer's latest cursor. The caller must hold roomManager.mu.
+func (ws *WebSocketService) sendCursor(client *Client, roomManager *RoomManager, p *peer) {
+	p.lastCursorSent = time.Now()
+	presence := p.presence
+	ws.broadcastLocked(roomManager, models.Message{
+		Type:     "cursor",
+		Presence: &presence,
+		Cursor:   presence.Cursor,
+		Revision: roomManager.Revision,
+	}, client)
+}
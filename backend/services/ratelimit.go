@@ -0,0 +1,80 @@
+package services
+
+import (
+	"log"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// tokenBucket is a token-bucket rate limiter. It holds up to burst tokens and
+// refills at rate tokens per second; every allowed event spends one token.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket creates a full bucket. A rate of zero or less disables it.
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(max(burst, 1)),
+		tokens: float64(max(burst, 1)),
+		last:   time.Now(),
+	}
+}
+
+// allow spends a token and reports whether one was available
+func (b *tokenBucket) allow() bool {
+	if b.rate <= 0 {
+		return true
+	}
+
+	now := time.Now()
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// retryAfter returns how long until the bucket next holds a token
+func (b *tokenBucket) retryAfter() time.Duration {
+	if b.rate <= 0 || b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// full reports whether the bucket has refilled completely by now, making it
+// no different from a new one
+func (b *tokenBucket) full(now time.Time) bool {
+	return b.tokens+now.Sub(b.last).Seconds()*b.rate >= b.burst
+}
+
+// admit applies a client's inbound rate limit to the message just read and
+// reports whether it should be handled. A client that keeps sending through a
+// whole burst's worth of drops is disconnected for a policy violation.
+func (ws *WebSocketService) admit(client *Client, roomManager *RoomManager) bool {
+	if client.limiter.allow() {
+		client.strikes = 0
+		return true
+	}
+
+	client.strikes++
+	ws.violations.rateLimitedMessages.Add(1)
+
+	if client.strikes == int(client.limiter.burst) {
+		ws.violations.policyCloses.Add(1)
+		log.Printf("🚫 Client %s kept flooding room %s, disconnecting", client.conn.RemoteAddr(), roomManager.ID)
+		client.Close(websocket.ClosePolicyViolation, "rate limit exceeded")
+	} else if client.strikes == 1 {
+		log.Printf("⚠️ Rate limiting client %s in room %s", client.conn.RemoteAddr(), roomManager.ID)
+	}
+	return false
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Room list sort keys
+const (
+	RoomSortUpdated = "updated"
+	RoomSortCreated = "created"
+	RoomSortTitle   = "title"
+)
+
+// RoomListOptions selects and orders a page of a user's rooms. Zero values
+// mean no filter.
+type RoomListOptions struct {
+	// Sort is one of the RoomSort keys; rooms with the same key are ordered
+	// by ID so every room has a fixed place in the list
+	Sort       string
+	Descending bool
+	// TitlePrefix matches the start of titles, ignoring case
+	TitlePrefix string
+	// The After bounds are inclusive and the Before bounds exclusive
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	UpdatedAfter  time.Time
+	UpdatedBefore time.Time
+	// After continues the list behind this room, which must have been listed
+	// with the same sort
+	After *RoomCursor
+	Limit int
+}
+
+// RoomCursor is the position of a room in a sorted list
+type RoomCursor struct {
+	ID    string    `json:"id"`
+	Title string    `json:"title,omitempty"`
+	Time  time.Time `json:"time,omitzero"`
+}
+
+// CursorFor returns the position of room in lists sorted by opts.Sort
+func (opts RoomListOptions) CursorFor(room *Room) *RoomCursor {
+	switch opts.Sort {
+	case RoomSortTitle:
+		return &RoomCursor{ID: room.ID, Title: room.Title}
+	case RoomSortCreated:
+		return &RoomCursor{ID: room.ID, Time: room.CreatedAt}
+	default:
+		return &RoomCursor{ID: room.ID, Time: room.UpdatedAt}
+	}
+}
+
+// sortColumn returns the rooms column the list is sorted by
+func (opts RoomListOptions) sortColumn() string {
+	switch opts.Sort {
+	case RoomSortTitle:
+		return "title"
+	case RoomSortCreated:
+		return "created_at"
+	default:
+		return "updated_at"
+	}
+}
+
+// roomListQuery builds the SQL listing a page of a user's rooms, leaving out
+// content and rooms in the trash. placeholder renders the nth parameter in
+// the database's syntax, and times are passed through toParam so each store
+// can store them its own way.
+func roomListQuery(userUID string, opts RoomListOptions, placeholder func(n int) string, toParam func(time.Time) any) (string, []any) {
+	var conditions []string
+	var args []any
+	add := func(condition string, values ...any) {
+		for _, value := range values {
+			args = append(args, value)
+			condition = strings.Replace(condition, "?", placeholder(len(args)), 1)
+		}
+		conditions = append(conditions, condition)
+	}
+
+	add("user_uid = ?", userUID)
+	conditions = append(conditions, "deleted_at IS NULL")
+	if opts.TitlePrefix != "" {
+		add(`LOWER(title) LIKE ? ESCAPE '\'`, likePrefix(strings.ToLower(opts.TitlePrefix)))
+	}
+	for _, bound := range []struct {
+		condition string
+		value     time.Time
+	}{
+		{"created_at >= ?", opts.CreatedAfter},
+		{"created_at < ?", opts.CreatedBefore},
+		{"updated_at >= ?", opts.UpdatedAfter},
+		{"updated_at < ?", opts.UpdatedBefore},
+	} {
+		if !bound.value.IsZero() {
+			add(bound.condition, toParam(bound.value))
+		}
+	}
+
+	column := opts.sortColumn()
+	direction, comparison := "ASC", ">"
+	if opts.Descending {
+		direction, comparison = "DESC", "<"
+	}
+	if opts.After != nil {
+		var key any = opts.After.Title
+		if opts.Sort != RoomSortTitle {
+			key = toParam(opts.After.Time)
+		}
+		add(fmt.Sprintf("(%s, id) %s (?, ?)", column, comparison), key, opts.After.ID)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, title, user_uid, created_at, updated_at, deleted_at, version
+		FROM rooms
+		WHERE %s
+		ORDER BY %s %s, id %s
+	`, strings.Join(conditions, " AND "), column, direction, direction)
+	if opts.Limit > 0 {
+		query += " LIMIT " + placeholder(len(args)+1)
+		args = append(args, opts.Limit)
+	}
+	return query, args
+}
+
+// likePrefix turns a prefix into a LIKE pattern, escaping its wildcards
+func likePrefix(prefix string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(prefix) + "%"
+}
+
+// matches reports whether a room passes the filters, for stores that filter
+// in Go
+func (opts RoomListOptions) matches(room *Room) bool {
+	if opts.TitlePrefix != "" && !strings.HasPrefix(strings.ToLower(room.Title), strings.ToLower(opts.TitlePrefix)) {
+		return false
+	}
+	if !opts.CreatedAfter.IsZero() && room.CreatedAt.Before(opts.CreatedAfter) {
+		return false
+	}
+	if !opts.CreatedBefore.IsZero() && !room.CreatedAt.Before(opts.CreatedBefore) {
+		return false
+	}
+	if !opts.UpdatedAfter.IsZero() && room.UpdatedAt.Before(opts.UpdatedAfter) {
+		return false
+	}
+	if !opts.UpdatedBefore.IsZero() && !room.UpdatedAt.Before(opts.UpdatedBefore) {
+		return false
+	}
+	if opts.After != nil && !opts.less(opts.After, opts.CursorFor(room)) {
+		return false
+	}
+	return true
+}
+
+// less reports whether the room at a comes before the room at b in the list
+func (opts RoomListOptions) less(a, b *RoomCursor) bool {
+	var cmp int
+	if opts.Sort == RoomSortTitle {
+		cmp = strings.Compare(a.Title, b.Title)
+	} else {
+		cmp = a.Time.Compare(b.Time)
+	}
+	if cmp == 0 {
+		cmp = strings.Compare(a.ID, b.ID)
+	}
+	if opts.Descending {
+		return cmp > 0
+	}
+	return cmp < 0
+}
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// SearchResult is a room matching a search
+type SearchResult struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	// Snippet is HTML-escaped text from the room with the matches wrapped in
+	// <mark> tags
+	Snippet string `json:"snippet"`
+	// Rank orders results best first; it only compares results of one search
+	Rank      float64   `json:"rank"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// snippetStart and snippetStop delimit matches in the snippets built by the
+// databases, so the text around them can be escaped before they become tags.
+// They are private use characters that never appear in normal text.
+const (
+	snippetStart = "\ue000"
+	snippetStop  = "\ue001"
+)
+
+// Snippet sizes for the stores that build snippets themselves
+const (
+	snippetWords       = 24
+	snippetWordsBefore = 6
+)
+
+// searchTerms splits a query into lower case words, ignoring punctuation and
+// operators
+func searchTerms(query string) []string {
+	return strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// markSnippet HTML-escapes a snippet and turns its match delimiters into
+// <mark> tags
+func markSnippet(snippet string) string {
+	return strings.NewReplacer(snippetStart, "<mark>", snippetStop, "</mark>").Replace(html.EscapeString(snippet))
+}
+
+// word is a word of a document and where it sits in the text
+type word struct {
+	text       string
+	start, end int
+}
+
+// splitWords splits text into lower case words with their byte offsets
+func splitWords(text string) []word {
+	var words []word
+	start := -1
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsNumber(r)
+		if isWord && start < 0 {
+			start = i
+		} else if !isWord && start >= 0 {
+			words = append(words, word{strings.ToLower(text[start:i]), start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		words = append(words, word{strings.ToLower(text[start:]), start, len(text)})
+	}
+	return words
+}
+
+// matchesTerm reports whether a word matches one of the terms. Terms match
+// the start of a word, so "edit" also finds "editing".
+func matchesTerm(w string, terms []string) bool {
+	for _, term := range terms {
+		if strings.HasPrefix(w, term) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchRoom scores a room against the terms for stores without a search
+// index. Every term must start a word of the title or content; title matches
+// count ten times as much as content matches, and long documents need more
+// matches to rank as high. It returns false when the room does not match.
+func matchRoom(room *Room, terms []string) (*SearchResult, bool) {
+	titleWords := splitWords(room.Title)
+	contentWords := splitWords(room.Content)
+
+	hits := 0.0
+	for _, term := range terms {
+		found := 0.0
+		for _, w := range titleWords {
+			if strings.HasPrefix(w.text, term) {
+				found += 10
+			}
+		}
+		for _, w := range contentWords {
+			if strings.HasPrefix(w.text, term) {
+				found++
+			}
+		}
+		if found == 0 {
+			return nil, false
+		}
+		hits += found
+	}
+
+	return &SearchResult{
+		ID:        room.ID,
+		Title:     room.Title,
+		Snippet:   markSnippet(buildSnippet(room.Content, contentWords, terms)),
+		Rank:      hits / math.Log2(float64(len(contentWords))+2),
+		UpdatedAt: room.UpdatedAt,
+	}, true
+}
+
+// buildSnippet cuts the words around the first match out of the content, with
+// the matches delimited by snippetStart and snippetStop
+func buildSnippet(content string, words []word, terms []string) string {
+	if len(words) == 0 {
+		return ""
+	}
+
+	first := 0
+	for i, w := range words {
+		if matchesTerm(w.text, terms) {
+			first = i
+			break
+		}
+	}
+	from := max(0, first-snippetWordsBefore)
+	to := min(len(words), from+snippetWords)
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("…")
+	}
+	pos := words[from].start
+	for _, w := range words[from:to] {
+		if !matchesTerm(w.text, terms) {
+			continue
+		}
+		b.WriteString(content[pos:w.start])
+		b.WriteString(snippetStart)
+		b.WriteString(content[w.start:w.end])
+		b.WriteString(snippetStop)
+		pos = w.end
+	}
+	b.WriteString(content[pos:words[to-1].end])
+	if to < len(words) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+// rankResults sorts results best first, most recently updated first on ties,
+// and keeps at most limit of them
+func rankResults(results []*SearchResult, limit int) []*SearchResult {
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		return results[i].UpdatedAt.After(results[j].UpdatedAt)
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// trackConnection registers a new connection handler unless the service is
+// shutting down. Callers that get true must call ws.connections.Done.
+func (ws *WebSocketService) trackConnection() bool {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if ws.shuttingDown.Load() {
+		return false
+	}
+	ws.connections.Add(1)
+	return true
+}
+
+// closeIfShuttingDown closes a client that finished joining a room after
+// Shutdown swept it. The caller must hold the room's lock.
+func (ws *WebSocketService) closeIfShuttingDown(client *Client) {
+	if ws.shuttingDown.Load() {
+		client.Close(websocket.CloseGoingAway, "server shutting down")
+	}
+}
+
+// Shutdown stops accepting connections, tells every connected client the
+// server is going away and writes each room's latest document to the database.
+// It returns once all connection handlers have finished and every room is
+// saved, or with an error when ctx expires first.
+func (ws *WebSocketService) Shutdown(ctx context.Context) error {
+	ws.mu.Lock()
+	ws.shuttingDown.Store(true)
+	rooms := make([]*RoomManager, 0, len(ws.rooms))
+	for _, room := range ws.rooms {
+		rooms = append(rooms, room)
+	}
+	ws.mu.Unlock()
+
+	log.Printf("🛑 Closing WebSocket connections in %d rooms", len(rooms))
+	for _, room := range rooms {
+		room.mu.RLock()
+		for client := range room.Clients {
+			client.Close(websocket.CloseGoingAway, "server shutting down")
+		}
+		for client := range room.CRDTClients {
+			client.Close(websocket.CloseGoingAway, "server shutting down")
+		}
+		room.mu.RUnlock()
+	}
+
+	// Wait for the handlers to exit so no edit can arrive after the final write
+	handlersDone := make(chan struct{})
+	go func() {
+		ws.connections.Wait()
+		close(handlersDone)
+	}()
+	select {
+	case <-handlersDone:
+	case <-ctx.Done():
+		log.Printf("⚠️ WebSocket handlers still running at shutdown deadline")
+	}
+
+	var wg sync.WaitGroup
+	for _, room := range rooms {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			room.persister.close()
+		}()
+	}
+	flushed := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(flushed)
+	}()
+
+	select {
+	case <-flushed:
+		log.Printf("✅ All rooms saved")
+		ws.cancel()
+		return nil
+	case <-ctx.Done():
+		// Abort the writes still in flight so they do not outlive the database
+		ws.cancel()
+		return fmt.Errorf("rooms not saved before shutdown deadline: %w", ctx.Err())
+	}
+}
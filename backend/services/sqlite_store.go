@@ -0,0 +1,823 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/logoes0/peeriodic.git/config"
+	"github.com/logoes0/peeriodic.git/migrations"
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteStore is the Store backed by an embedded SQLite database file, for
+// deployments that ship as a single binary
+type SQLiteStore struct {
+	db           *sql.DB
+	queryTimeout time.Duration
+}
+
+// NewSQLiteStore opens (creating if needed) the SQLite database at
+// cfg.Database.Path and brings its schema up to date. Use ":memory:" for a
+// throwaway database.
+func NewSQLiteStore(cfg *config.Config) (*SQLiteStore, error) {
+	db, err := openSQLite(cfg.Database.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := prepareSchema(db, migrations.DialectSQLite, cfg.Database.AutoMigrate); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLiteStore{db: db, queryTimeout: cfg.Database.QueryTimeout}, nil
+}
+
+// openSQLite opens the SQLite database at path
+func openSQLite(path string) (*sql.DB, error) {
+	dsn := "file:" + path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+	if path != ":memory:" {
+		dsn += "&_pragma=journal_mode(WAL)"
+	}
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	// SQLite allows one writer at a time, and every connection to ":memory:"
+	// would get its own empty database, so the pool settings do not apply
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	log.Printf("✅ SQLite database opened at %s", path)
+	return db, nil
+}
+
+// Close closes the database
+func (ss *SQLiteStore) Close() error {
+	return ss.db.Close()
+}
+
+// queryContext bounds a query by the default query timeout
+func (ss *SQLiteStore) queryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withQueryTimeout(ctx, ss.queryTimeout)
+}
+
+// CreateUser creates a new user in the database
+func (ss *SQLiteStore) CreateUser(ctx context.Context, uid, email, name string) (*User, error) {
+	ctx, cancel := ss.queryContext(ctx)
+	defer cancel()
+	query := `
+		INSERT INTO users (uid, email, name, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING id, uid, email, name, created_at, updated_at
+	`
+
+	now := time.Now().UTC()
+	user := &User{}
+	err := ss.db.QueryRowContext(ctx, query, uid, email, name, now, now).Scan(
+		&user.ID, &user.UID, &user.Email, &user.Name, &user.CreatedAt, &user.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	return user, nil
+}
+
+// GetUserByUID retrieves a user by UID
+func (ss *SQLiteStore) GetUserByUID(ctx context.Context, uid string) (*User, error) {
+	ctx, cancel := ss.queryContext(ctx)
+	defer cancel()
+	query := `
+		SELECT id, uid, email, name, created_at, updated_at
+		FROM users
+		WHERE uid = ?
+	`
+
+	user := &User{}
+	err := ss.db.QueryRowContext(ctx, query, uid).Scan(
+		&user.ID, &user.UID, &user.Email, &user.Name, &user.CreatedAt, &user.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("user not found: %s", uid)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return user, nil
+}
+
+// EnsureUserExists creates a user if it doesn't exist, otherwise updates and
+// returns the existing user
+func (ss *SQLiteStore) EnsureUserExists(ctx context.Context, uid, email, name string) (*User, error) {
+	ctx, cancel := ss.queryContext(ctx)
+	defer cancel()
+	query := `
+		INSERT INTO users (uid, email, name, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (uid) DO UPDATE SET
+			email = excluded.email,
+			name = excluded.name,
+			updated_at = excluded.updated_at
+		RETURNING id, uid, email, name, created_at, updated_at
+	`
+
+	now := time.Now().UTC()
+	user := &User{}
+	err := ss.db.QueryRowContext(ctx, query, uid, email, name, now, now).Scan(
+		&user.ID, &user.UID, &user.Email, &user.Name, &user.CreatedAt, &user.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to ensure user exists: %w", err)
+	}
+
+	return user, nil
+}
+
+// CreateRoom creates a new room in the database
+func (ss *SQLiteStore) CreateRoom(ctx context.Context, id, title string, userUID *string) (*Room, error) {
+	ctx, cancel := ss.queryContext(ctx)
+	defer cancel()
+	query := `
+		INSERT INTO rooms (id, title, user_uid, content, created_at, updated_at)
+		VALUES (?, ?, ?, '', ?, ?)
+		RETURNING id, title, content, user_uid, created_at, updated_at, deleted_at, version
+	`
+
+	now := time.Now().UTC()
+	room := &Room{}
+	err := ss.db.QueryRowContext(ctx, query, id, title, userUID, now, now).Scan(
+		&room.ID, &room.Title, &room.Content, &room.UserUID, &room.CreatedAt, &room.UpdatedAt, &room.DeletedAt, &room.Version,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create room: %w", err)
+	}
+
+	return room, nil
+}
+
+// GetRoom retrieves a room by ID
+func (ss *SQLiteStore) GetRoom(ctx context.Context, id string) (*Room, error) {
+	ctx, cancel := ss.queryContext(ctx)
+	defer cancel()
+	query := `
+		SELECT id, title, content, user_uid, created_at, updated_at, deleted_at, version
+		FROM rooms
+		WHERE id = ? AND deleted_at IS NULL
+	`
+
+	room := &Room{}
+	err := ss.db.QueryRowContext(ctx, query, id).Scan(
+		&room.ID, &room.Title, &room.Content, &room.UserUID, &room.CreatedAt, &room.UpdatedAt, &room.DeletedAt, &room.Version,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s", ErrRoomNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get room: %w", err)
+	}
+
+	return room, nil
+}
+
+// ListRooms lists a page of a user's rooms without their content
+func (ss *SQLiteStore) ListRooms(ctx context.Context, userUID string, opts RoomListOptions) ([]*Room, error) {
+	ctx, cancel := ss.queryContext(ctx)
+	defer cancel()
+	// Times are stored as UTC text, which only sorts right against UTC
+	query, args := roomListQuery(userUID, opts,
+		func(int) string { return "?" },
+		func(t time.Time) any { return t.UTC() },
+	)
+
+	rows, err := ss.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rooms: %w", err)
+	}
+	defer rows.Close()
+
+	var rooms []*Room
+	for rows.Next() {
+		room := &Room{}
+		err := rows.Scan(
+			&room.ID, &room.Title, &room.UserUID, &room.CreatedAt, &room.UpdatedAt, &room.DeletedAt, &room.Version,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan room: %w", err)
+		}
+		rooms = append(rooms, room)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rooms: %w", err)
+	}
+
+	return rooms, nil
+}
+
+// UpdateRoomContent updates the content of a room
+func (ss *SQLiteStore) UpdateRoomContent(ctx context.Context, id, content string, crdtState []byte) error {
+	ctx, cancel := ss.queryContext(ctx)
+	defer cancel()
+	query := `UPDATE rooms SET content = ?, crdt_state = COALESCE(?, crdt_state), version = version + 1, updated_at = ? WHERE id = ? AND deleted_at IS NULL`
+
+	result, err := ss.db.ExecContext(ctx, query, content, nullBytes(crdtState), time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update room content: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%w: %s", ErrRoomNotFound, id)
+	}
+
+	return nil
+}
+
+// SaveRoomContent writes content and returns the room's new version. With a
+// nonzero ifVersion the write only happens while the room is at that version.
+// The content it replaces is kept as a room version in the same transaction.
+func (ss *SQLiteStore) SaveRoomContent(ctx context.Context, id, content string, ifVersion int64) (int64, error) {
+	ctx, cancel := ss.queryContext(ctx)
+	defer cancel()
+
+	tx, err := ss.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to save room content: %w", err)
+	}
+	defer tx.Rollback()
+
+	var current string
+	var version int64
+	err = tx.QueryRowContext(ctx,
+		`SELECT content, version FROM rooms WHERE id = ? AND deleted_at IS NULL`, id,
+	).Scan(&current, &version)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("%w: %s", ErrRoomNotFound, id)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to save room content: %w", err)
+	}
+	if ifVersion != 0 && version != ifVersion {
+		return version, fmt.Errorf("%w: room %s is at version %d", ErrVersionMismatch, id, version)
+	}
+
+	now := time.Now().UTC()
+	if _, err := tx.ExecContext(ctx, sqliteInsertRoomVersion, id, current, now); err != nil {
+		return 0, fmt.Errorf("failed to keep replaced content: %w", err)
+	}
+
+	query := `
+		UPDATE rooms
+		SET content = ?, version = version + 1, updated_at = ?
+		WHERE id = ?
+		RETURNING version
+	`
+	if err := tx.QueryRowContext(ctx, query, content, now, id).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to save room content: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to save room content: %w", err)
+	}
+
+	return version, nil
+}
+
+// DeleteRoom moves a room to the trash
+func (ss *SQLiteStore) DeleteRoom(ctx context.Context, id string) error {
+	ctx, cancel := ss.queryContext(ctx)
+	defer cancel()
+	query := `UPDATE rooms SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL`
+
+	result, err := ss.db.ExecContext(ctx, query, time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("failed to delete room: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%w: %s", ErrRoomNotFound, id)
+	}
+
+	return nil
+}
+
+// EnsureRoomExists creates a room owned by ownerUID if it doesn't exist,
+// otherwise returns the existing room
+func (ss *SQLiteStore) EnsureRoomExists(ctx context.Context, id, ownerUID string) (*Room, error) {
+	ctx, cancel := ss.queryContext(ctx)
+	defer cancel()
+
+	// SQLite has no writable CTEs, so the room and its owner are added in
+	// one transaction instead
+	tx, err := ss.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to ensure room exists: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	insert := `
+		INSERT INTO rooms (id, title, content, user_uid, created_at, updated_at)
+		VALUES (?1, 'Untitled Room', '', (SELECT uid FROM users WHERE uid = ?2), ?3, ?3)
+		ON CONFLICT (id) DO NOTHING
+	`
+	result, err := tx.ExecContext(ctx, insert, id, ownerUID, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to ensure room exists: %w", err)
+	}
+	created, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if created > 0 && ownerUID != "" {
+		owner := `
+			INSERT OR IGNORE INTO room_members (room_id, user_uid, role, created_at, updated_at)
+			VALUES (?, ?, 'owner', ?, ?)
+		`
+		if _, err := tx.ExecContext(ctx, owner, id, ownerUID, now, now); err != nil {
+			return nil, fmt.Errorf("failed to add room owner: %w", err)
+		}
+	}
+
+	query := `
+		SELECT id, title, content, user_uid, created_at, updated_at, deleted_at, version, crdt_state
+		FROM rooms
+		WHERE id = ?
+	`
+	room := &Room{}
+	err = tx.QueryRowContext(ctx, query, id).Scan(
+		&room.ID, &room.Title, &room.Content, &room.UserUID, &room.CreatedAt, &room.UpdatedAt, &room.DeletedAt, &room.Version, &room.CRDTState,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to ensure room exists: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to ensure room exists: %w", err)
+	}
+	if room.DeletedAt != nil {
+		return nil, fmt.Errorf("%w: %s", ErrRoomDeleted, id)
+	}
+
+	return room, nil
+}
+
+// RestoreRoom takes a room back out of the trash
+func (ss *SQLiteStore) RestoreRoom(ctx context.Context, id string) error {
+	ctx, cancel := ss.queryContext(ctx)
+	defer cancel()
+	query := `UPDATE rooms SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL`
+
+	result, err := ss.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to restore room: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%w in trash: %s", ErrRoomNotFound, id)
+	}
+
+	return nil
+}
+
+// GetTrashedRoomsByUser lists a user's rooms in the trash, most recently
+// deleted first
+func (ss *SQLiteStore) GetTrashedRoomsByUser(ctx context.Context, userUID string) ([]*Room, error) {
+	ctx, cancel := ss.queryContext(ctx)
+	defer cancel()
+	query := `
+		SELECT id, title, content, user_uid, created_at, updated_at, deleted_at, version
+		FROM rooms
+		WHERE user_uid = ? AND deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC
+	`
+
+	rows, err := ss.db.QueryContext(ctx, query, userUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trashed rooms: %w", err)
+	}
+	defer rows.Close()
+
+	var rooms []*Room
+	for rows.Next() {
+		room := &Room{}
+		err := rows.Scan(
+			&room.ID, &room.Title, &room.Content, &room.UserUID, &room.CreatedAt, &room.UpdatedAt, &room.DeletedAt, &room.Version,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan room: %w", err)
+		}
+		rooms = append(rooms, room)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rooms: %w", err)
+	}
+
+	return rooms, nil
+}
+
+// PurgeDeletedRooms permanently deletes rooms moved to the trash before the
+// cutoff, with their versions
+func (ss *SQLiteStore) PurgeDeletedRooms(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := ss.queryContext(ctx)
+	defer cancel()
+	query := `DELETE FROM rooms WHERE deleted_at IS NOT NULL AND deleted_at < ?`
+
+	result, err := ss.db.ExecContext(ctx, query, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge deleted rooms: %w", err)
+	}
+
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return purged, nil
+}
+
+// CreateAuditEvent adds an event to the audit log
+func (ss *SQLiteStore) CreateAuditEvent(ctx context.Context, event *AuditEvent) error {
+	ctx, cancel := ss.queryContext(ctx)
+	defer cancel()
+	query := `
+		INSERT INTO audit_events (room_id, action, actor_uid, remote_addr, detail, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING id, created_at
+	`
+
+	err := ss.db.QueryRowContext(ctx, query,
+		event.RoomID, event.Action, event.ActorUID, event.RemoteAddr, event.Detail, time.Now().UTC(),
+	).Scan(&event.ID, &event.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create audit event: %w", err)
+	}
+
+	return nil
+}
+
+// GetAuditEvents lists a room's audit events, newest first
+func (ss *SQLiteStore) GetAuditEvents(ctx context.Context, roomID string, query AuditQuery) ([]*AuditEvent, error) {
+	ctx, cancel := ss.queryContext(ctx)
+	defer cancel()
+	sqlQuery, args := auditQuery(roomID, query, func(int) string { return "?" },
+		func(t time.Time) any { return t.UTC() },
+	)
+
+	rows, err := ss.db.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get audit events: %w", err)
+	}
+	return scanAuditEvents(rows)
+}
+
+// GetRoomMembers lists a room's members, oldest first
+func (ss *SQLiteStore) GetRoomMembers(ctx context.Context, roomID string) ([]*RoomMember, error) {
+	ctx, cancel := ss.queryContext(ctx)
+	defer cancel()
+	query := `
+		SELECT room_id, user_uid, role, created_at, updated_at
+		FROM room_members
+		WHERE room_id = ?1
+		ORDER BY created_at, user_uid
+	`
+
+	rows, err := ss.db.QueryContext(ctx, query, roomID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get room members: %w", err)
+	}
+	return scanRoomMembers(rows)
+}
+
+// SetRoomMember adds a member or changes their role. The change only goes
+// through when the member is made an owner or another owner remains.
+func (ss *SQLiteStore) SetRoomMember(ctx context.Context, roomID, userUID, role string) (*RoomMember, error) {
+	ctx, cancel := ss.queryContext(ctx)
+	defer cancel()
+	query := `
+		WITH change (room_id, user_uid, role) AS (
+			VALUES (?1, ?2, ?3)
+		)
+		INSERT INTO room_members (room_id, user_uid, role, created_at, updated_at)
+		SELECT room_id, user_uid, role, ?4, ?4 FROM change
+		WHERE role = 'owner' OR EXISTS (
+			SELECT 1 FROM room_members other
+			WHERE other.room_id = change.room_id AND other.role = 'owner' AND other.user_uid <> change.user_uid
+		)
+		ON CONFLICT (room_id, user_uid) DO UPDATE SET role = excluded.role, updated_at = excluded.updated_at
+		RETURNING room_id, user_uid, role, created_at, updated_at
+	`
+
+	member := &RoomMember{}
+	err := ss.db.QueryRowContext(ctx, query, roomID, userUID, role, time.Now().UTC()).Scan(
+		&member.RoomID, &member.UserUID, &member.Role, &member.CreatedAt, &member.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrLastOwner
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to set room member: %w", err)
+	}
+
+	return member, nil
+}
+
+// RemoveRoomMember removes a member from a room unless they are its last
+// owner
+func (ss *SQLiteStore) RemoveRoomMember(ctx context.Context, roomID, userUID string) error {
+	ctx, cancel := ss.queryContext(ctx)
+	defer cancel()
+	query := `
+		DELETE FROM room_members
+		WHERE room_id = ?1 AND user_uid = ?2 AND (role <> 'owner' OR EXISTS (
+			SELECT 1 FROM room_members other
+			WHERE other.room_id = ?1 AND other.role = 'owner' AND other.user_uid <> ?2
+		))
+	`
+
+	result, err := ss.db.ExecContext(ctx, query, roomID, userUID)
+	if err != nil {
+		return fmt.Errorf("failed to remove room member: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected > 0 {
+		return nil
+	}
+
+	var exists bool
+	err = ss.db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM room_members WHERE room_id = ?1 AND user_uid = ?2)`, roomID, userUID,
+	).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check room member: %w", err)
+	}
+	if exists {
+		return ErrLastOwner
+	}
+	return fmt.Errorf("room member not found: %s in %s", userUID, roomID)
+}
+
+// CreateRoomLink stores a share link
+func (ss *SQLiteStore) CreateRoomLink(ctx context.Context, link *RoomLink) error {
+	ctx, cancel := ss.queryContext(ctx)
+	defer cancel()
+	query := `
+		INSERT INTO room_links (id, room_id, token_hash, role, created_by, expires_at, max_uses, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING uses, created_at
+	`
+
+	// Times are compared as text, so they must all be in UTC
+	var expiresAt any
+	if link.ExpiresAt != nil {
+		expiresAt = link.ExpiresAt.UTC()
+	}
+
+	err := ss.db.QueryRowContext(ctx, query,
+		link.ID, link.RoomID, link.TokenHash, link.Role, link.CreatedBy, expiresAt, link.MaxUses, time.Now().UTC(),
+	).Scan(&link.Uses, &link.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create room link: %w", err)
+	}
+
+	return nil
+}
+
+// GetRoomLinks lists a room's share links, newest first
+func (ss *SQLiteStore) GetRoomLinks(ctx context.Context, roomID string) ([]*RoomLink, error) {
+	ctx, cancel := ss.queryContext(ctx)
+	defer cancel()
+	query := `
+		SELECT id, room_id, role, created_by, expires_at, max_uses, uses, created_at
+		FROM room_links
+		WHERE room_id = ?
+		ORDER BY created_at DESC, id
+	`
+
+	rows, err := ss.db.QueryContext(ctx, query, roomID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get room links: %w", err)
+	}
+	return scanRoomLinks(rows)
+}
+
+// DeleteRoomLink revokes a share link
+func (ss *SQLiteStore) DeleteRoomLink(ctx context.Context, roomID, linkID string) error {
+	ctx, cancel := ss.queryContext(ctx)
+	defer cancel()
+	query := `DELETE FROM room_links WHERE room_id = ? AND id = ?`
+
+	result, err := ss.db.ExecContext(ctx, query, roomID, linkID)
+	if err != nil {
+		return fmt.Errorf("failed to delete room link: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("room link not found: %s", linkID)
+	}
+
+	return nil
+}
+
+// UseRoomLink counts a use of a room's link while it is unexpired and has
+// uses left
+func (ss *SQLiteStore) UseRoomLink(ctx context.Context, roomID, tokenHash string) (*RoomLink, error) {
+	ctx, cancel := ss.queryContext(ctx)
+	defer cancel()
+	query := `
+		UPDATE room_links
+		SET uses = uses + 1
+		WHERE room_id = ? AND token_hash = ?
+			AND (expires_at IS NULL OR expires_at > ?)
+			AND (max_uses = 0 OR uses < max_uses)
+		RETURNING id, room_id, role, created_by, expires_at, max_uses, uses, created_at
+	`
+
+	link := &RoomLink{}
+	err := ss.db.QueryRowContext(ctx, query, roomID, tokenHash, time.Now().UTC()).Scan(
+		&link.ID, &link.RoomID, &link.Role, &link.CreatedBy, &link.ExpiresAt, &link.MaxUses, &link.Uses, &link.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrLinkInvalid
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to use room link: %w", err)
+	}
+
+	return link, nil
+}
+
+// SearchRooms finds a user's rooms containing every word of the query, ranked
+// by the rooms_fts full-text index. Titles weigh more than content.
+func (ss *SQLiteStore) SearchRooms(ctx context.Context, userUID, query string, limit int) ([]*SearchResult, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	// Quoting every term keeps FTS5 from reading the query as its own syntax
+	for i, term := range terms {
+		terms[i] = `"` + term + `"`
+	}
+
+	ctx, cancel := ss.queryContext(ctx)
+	defer cancel()
+	// bm25 scores better matches lower, so it is negated to rank best first
+	sqlQuery := `
+		SELECT r.id, r.title, snippet(rooms_fts, 2, ?, ?, '…', ?), -bm25(rooms_fts, 0, 10, 1) AS rank, r.updated_at
+		FROM rooms_fts
+		JOIN rooms r ON r.id = rooms_fts.room_id
+		WHERE rooms_fts MATCH ? AND r.user_uid = ? AND r.deleted_at IS NULL
+		ORDER BY rank DESC, r.updated_at DESC
+		LIMIT ?
+	`
+
+	rows, err := ss.db.QueryContext(ctx, sqlQuery,
+		snippetStart, snippetStop, snippetWords, strings.Join(terms, " "), userUID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search rooms: %w", err)
+	}
+	defer rows.Close()
+
+	var results []*SearchResult
+	for rows.Next() {
+		result := &SearchResult{}
+		if err := rows.Scan(&result.ID, &result.Title, &result.Snippet, &result.Rank, &result.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan search result: %w", err)
+		}
+		result.Snippet = markSnippet(result.Snippet)
+		results = append(results, result)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating search results: %w", err)
+	}
+
+	return results, nil
+}
+
+// sqliteInsertRoomVersion stores ?2 as the next version of room ?1, created
+// at ?3, unless it matches the latest one
+const sqliteInsertRoomVersion = `
+	WITH latest AS (
+		SELECT version, content FROM room_versions
+		WHERE room_id = ?1
+		ORDER BY version DESC
+		LIMIT 1
+	)
+	INSERT INTO room_versions (room_id, version, content, created_at)
+	SELECT ?1, COALESCE((SELECT version FROM latest), 0) + 1, ?2, ?3
+	WHERE NOT EXISTS (SELECT 1 FROM latest WHERE content = ?2)
+	RETURNING room_id, version, content, created_at
+`
+
+// CreateRoomVersion stores content as the room's next version. It returns nil
+// without storing anything when content matches the latest version.
+func (ss *SQLiteStore) CreateRoomVersion(ctx context.Context, roomID, content string) (*RoomVersion, error) {
+	ctx, cancel := ss.queryContext(ctx)
+	defer cancel()
+
+	version := &RoomVersion{}
+	err := ss.db.QueryRowContext(ctx, sqliteInsertRoomVersion, roomID, content, time.Now().UTC()).Scan(
+		&version.RoomID, &version.Version, &version.Content, &version.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create room version: %w", err)
+	}
+
+	version.Size = len(version.Content)
+	return version, nil
+}
+
+// GetRoomVersions lists a room's versions, newest first, without their content
+func (ss *SQLiteStore) GetRoomVersions(ctx context.Context, roomID string) ([]*RoomVersion, error) {
+	ctx, cancel := ss.queryContext(ctx)
+	defer cancel()
+	// LENGTH counts characters for TEXT, so cast to measure the bytes
+	query := `
+		SELECT room_id, version, LENGTH(CAST(content AS BLOB)), created_at
+		FROM room_versions
+		WHERE room_id = ?
+		ORDER BY version DESC
+	`
+
+	rows, err := ss.db.QueryContext(ctx, query, roomID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get room versions: %w", err)
+	}
+	defer rows.Close()
+
+	versions := []*RoomVersion{}
+	for rows.Next() {
+		version := &RoomVersion{}
+		if err := rows.Scan(&version.RoomID, &version.Version, &version.Size, &version.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan room version: %w", err)
+		}
+		versions = append(versions, version)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating room versions: %w", err)
+	}
+
+	return versions, nil
+}
+
+// GetRoomVersion retrieves one version of a room
+func (ss *SQLiteStore) GetRoomVersion(ctx context.Context, roomID string, version int) (*RoomVersion, error) {
+	ctx, cancel := ss.queryContext(ctx)
+	defer cancel()
+	query := `
+		SELECT room_id, version, content, created_at
+		FROM room_versions
+		WHERE room_id = ? AND version = ?
+	`
+
+	v := &RoomVersion{}
+	err := ss.db.QueryRowContext(ctx, query, roomID, version).Scan(&v.RoomID, &v.Version, &v.Content, &v.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("room version not found: %s@%d", roomID, version)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get room version: %w", err)
+	}
+
+	v.Size = len(v.Content)
+	return v, nil
+}
@@ -0,0 +1,222 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/logoes0/peeriodic.git/config"
+	"github.com/logoes0/peeriodic.git/migrations"
+)
+
+// Store persists users, rooms, room members and share links, room versions
+// and the audit log. Lookups of missing records return an error containing
+// "not found", which wraps ErrRoomNotFound when the room itself is missing.
+// Every call gives up when its context is cancelled, and the SQL stores also
+// bound each query by DatabaseConfig.QueryTimeout.
+type Store interface {
+	CreateUser(ctx context.Context, uid, email, name string) (*User, error)
+	GetUserByUID(ctx context.Context, uid string) (*User, error)
+	EnsureUserExists(ctx context.Context, uid, email, name string) (*User, error)
+
+	CreateRoom(ctx context.Context, id, title string, userUID *string) (*Room, error)
+	// GetRoom, ListRooms and UpdateRoomContent only see rooms that are not in
+	// the trash
+	GetRoom(ctx context.Context, id string) (*Room, error)
+	// ListRooms lists a page of a user's rooms, leaving Content empty
+	ListRooms(ctx context.Context, userUID string, opts RoomListOptions) ([]*Room, error)
+	// UpdateRoomContent also stores crdtState, the room's encoded Yjs state,
+	// unless it is nil
+	UpdateRoomContent(ctx context.Context, id, content string, crdtState []byte) error
+	// SaveRoomContent writes content and returns the room's new version. With
+	// a nonzero ifVersion it only writes while the room is at that version
+	// and otherwise fails with ErrVersionMismatch, returning the current one.
+	// The content it replaces is kept as a room version, as CreateRoomVersion
+	// would, in the same transaction as the write.
+	SaveRoomContent(ctx context.Context, id, content string, ifVersion int64) (int64, error)
+	// EnsureRoomExists returns a room, first creating it when it does not
+	// exist. Unless ownerUID is empty, the room it creates is owned by that
+	// user, who becomes its user_uid too if they have signed up. It fails
+	// with ErrRoomDeleted for a room in the trash.
+	EnsureRoomExists(ctx context.Context, id, ownerUID string) (*Room, error)
+
+	// DeleteRoom moves a room to the trash
+	DeleteRoom(ctx context.Context, id string) error
+	// RestoreRoom takes a room back out of the trash
+	RestoreRoom(ctx context.Context, id string) error
+	// GetTrashedRoomsByUser lists a user's rooms in the trash, most recently
+	// deleted first
+	GetTrashedRoomsByUser(ctx context.Context, userUID string) ([]*Room, error)
+	// PurgeDeletedRooms permanently deletes rooms moved to the trash before
+	// the cutoff, with their versions, and returns how many were removed
+	PurgeDeletedRooms(ctx context.Context, before time.Time) (int64, error)
+
+	// SearchRooms finds a user's rooms whose title or content match the
+	// query, best match first, leaving out rooms in the trash
+	SearchRooms(ctx context.Context, userUID, query string, limit int) ([]*SearchResult, error)
+
+	// CreateRoomVersion stores content as the room's next version. It returns
+	// nil without storing anything when content matches the latest version.
+	CreateRoomVersion(ctx context.Context, roomID, content string) (*RoomVersion, error)
+	// GetRoomVersions lists a room's versions, newest first, without content
+	GetRoomVersions(ctx context.Context, roomID string) ([]*RoomVersion, error)
+	GetRoomVersion(ctx context.Context, roomID string, version int) (*RoomVersion, error)
+
+	// GetRoomMembers lists a room's members, oldest first. Creating a room
+	// with a user makes them its owner.
+	GetRoomMembers(ctx context.Context, roomID string) ([]*RoomMember, error)
+	// SetRoomMember adds a member or changes their role. Both it and
+	// RemoveRoomMember fail with ErrLastOwner rather than leave a room
+	// without an owner.
+	SetRoomMember(ctx context.Context, roomID, userUID, role string) (*RoomMember, error)
+	RemoveRoomMember(ctx context.Context, roomID, userUID string) error
+
+	// CreateRoomLink stores a share link, filling in its creation time
+	CreateRoomLink(ctx context.Context, link *RoomLink) error
+	// GetRoomLinks lists a room's share links, newest first
+	GetRoomLinks(ctx context.Context, roomID string) ([]*RoomLink, error)
+	DeleteRoomLink(ctx context.Context, roomID, linkID string) error
+	// UseRoomLink finds a room's link by token hash and counts a use. It
+	// fails with ErrLinkInvalid when the link is unknown, expired or used up.
+	UseRoomLink(ctx context.Context, roomID, tokenHash string) (*RoomLink, error)
+
+	// CreateAuditEvent adds an event to the audit log, filling in its ID and
+	// time
+	CreateAuditEvent(ctx context.Context, event *AuditEvent) error
+	// GetAuditEvents lists a room's audit events, newest first
+	GetAuditEvents(ctx context.Context, roomID string, query AuditQuery) ([]*AuditEvent, error)
+
+	Close() error
+}
+
+// Errors returned by the stores for rooms in a state that refuses the call
+var (
+	// ErrRoomNotFound is returned when a room does not exist, or for most
+	// calls when it is in the trash
+	ErrRoomNotFound = errors.New("room not found")
+	// ErrRoomDeleted is returned when a room in the trash is opened
+	ErrRoomDeleted = errors.New("room is deleted")
+	// ErrVersionMismatch is returned when a conditional save finds the room
+	// changed since the version it was based on
+	ErrVersionMismatch = errors.New("room version mismatch")
+	// ErrLastOwner is returned when a membership change would leave a room
+	// without an owner
+	ErrLastOwner = errors.New("room must keep an owner")
+	// ErrLinkInvalid is returned when a share link cannot be used
+	ErrLinkInvalid = errors.New("share link is invalid or expired")
+	// ErrRoomClaimed is returned when claiming a room that already has members
+	ErrRoomClaimed = errors.New("room already has members")
+)
+
+// Storage drivers selectable with DB_DRIVER
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+	DriverMemory   = "memory"
+)
+
+// NewStore opens the store selected by cfg.Database.Driver
+func NewStore(cfg *config.Config) (Store, error) {
+	switch cfg.Database.Driver {
+	case DriverPostgres:
+		return NewPostgresStore(cfg)
+	case DriverSQLite:
+		return NewSQLiteStore(cfg)
+	case DriverMemory:
+		return NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown database driver: %s", cfg.Database.Driver)
+	}
+}
+
+// OpenDatabase opens the SQL database configured in cfg without touching its
+// schema, for running migrations by hand
+func OpenDatabase(cfg *config.Config) (*sql.DB, error) {
+	switch cfg.Database.Driver {
+	case DriverPostgres:
+		return openPostgres(cfg)
+	case DriverSQLite:
+		return openSQLite(cfg.Database.Path)
+	default:
+		return nil, fmt.Errorf("the %s driver has no schema to migrate", cfg.Database.Driver)
+	}
+}
+
+// withQueryTimeout derives a context that expires after timeout, or never
+// when timeout is zero
+func withQueryTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// nullBytes passes a nil slice to the database as NULL
+func nullBytes(b []byte) any {
+	if b == nil {
+		return nil
+	}
+	return b
+}
+
+// prepareSchema applies pending migrations, or with autoMigrate off only
+// checks that none are pending. Either way it refuses a database whose schema
+// is newer than this binary.
+func prepareSchema(db *sql.DB, dialect string, autoMigrate bool) error {
+	migrator, err := migrations.New(db, dialect)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if !autoMigrate {
+		return migrator.Check(ctx)
+	}
+
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		return err
+	}
+	if len(applied) > 0 {
+		log.Printf("✅ Database schema migrated to version %d", migrator.Latest())
+	}
+	return nil
+}
+
+// User represents a user in the database
+type User struct {
+	ID        int       `json:"id"`
+	UID       string    `json:"uid"`
+	Email     string    `json:"email"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Room represents a room in the database
+type Room struct {
+	ID        string     `json:"id"`
+	Title     string     `json:"title"`
+	Content   string     `json:"content"`
+	UserUID   *string    `json:"user_uid"` // Changed to pointer to handle NULL values
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// Version counts writes to Content; it backs the room's ETag
+	Version int64 `json:"version"`
+	// CRDTState is the encoded Yjs state saved with Content, if any. Only
+	// EnsureRoomExists is sure to load it.
+	CRDTState []byte `json:"-"`
+}
+
+// RoomVersion represents a saved snapshot of a room's content
+type RoomVersion struct {
+	RoomID    string    `json:"room_id"`
+	Version   int       `json:"version"`
+	Content   string    `json:"content,omitempty"`
+	Size      int       `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
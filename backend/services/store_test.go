@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/logoes0/peeriodic.git/config"
+)
+
+// newTestSQLiteStore opens a fresh SQLite database for one test
+func newTestSQLiteStore(t *testing.T) *SQLiteStore {
+	t.Helper()
+	store, err := NewSQLiteStore(&config.Config{
+		Database: config.DatabaseConfig{Path: ":memory:", AutoMigrate: true},
+	})
+	if err != nil {
+		t.Fatalf("NewSQLiteStore() error = %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// testStores returns a fresh store of every kind that runs without a server
+func testStores(t *testing.T) map[string]Store {
+	return map[string]Store{
+		"memory": NewMemoryStore(),
+		"sqlite": newTestSQLiteStore(t),
+	}
+}
+
+func TestSaveRoomContentKeepsReplacedContent(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if _, err := store.EnsureRoomExists(ctx, "room", "alice"); err != nil {
+				t.Fatalf("EnsureRoomExists() error = %v", err)
+			}
+			if err := store.UpdateRoomContent(ctx, "room", "first", nil); err != nil {
+				t.Fatalf("UpdateRoomContent() error = %v", err)
+			}
+
+			version, err := store.SaveRoomContent(ctx, "room", "second", 0)
+			if err != nil {
+				t.Fatalf("SaveRoomContent() error = %v", err)
+			}
+			if _, err := store.SaveRoomContent(ctx, "room", "third", version); err != nil {
+				t.Fatalf("SaveRoomContent() error = %v", err)
+			}
+			if _, err := store.SaveRoomContent(ctx, "room", "stale", version); !errors.Is(err, ErrVersionMismatch) {
+				t.Fatalf("SaveRoomContent() of a stale version error = %v, want ErrVersionMismatch", err)
+			}
+
+			versions, err := store.GetRoomVersions(ctx, "room")
+			if err != nil {
+				t.Fatalf("GetRoomVersions() error = %v", err)
+			}
+			var got []string
+			for i := len(versions) - 1; i >= 0; i-- {
+				v, err := store.GetRoomVersion(ctx, "room", versions[i].Version)
+				if err != nil {
+					t.Fatalf("GetRoomVersion() error = %v", err)
+				}
+				got = append(got, v.Content)
+			}
+			want := []string{"first", "second"}
+			if !slices.Equal(got, want) {
+				t.Errorf("versions = %q, want %q", got, want)
+			}
+
+			room, err := store.GetRoom(ctx, "room")
+			if err != nil {
+				t.Fatalf("GetRoom() error = %v", err)
+			}
+			if room.Content != "third" {
+				t.Errorf("Content = %q, want %q", room.Content, "third")
+			}
+		})
+	}
+}
+
+func TestMissingRoomIsNotFound(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if _, err := store.EnsureRoomExists(ctx, "trashed", "alice"); err != nil {
+				t.Fatalf("EnsureRoomExists() error = %v", err)
+			}
+			if err := store.DeleteRoom(ctx, "trashed"); err != nil {
+				t.Fatalf("DeleteRoom() error = %v", err)
+			}
+
+			for _, id := range []string{"missing", "trashed"} {
+				if _, err := store.GetRoom(ctx, id); !errors.Is(err, ErrRoomNotFound) {
+					t.Errorf("GetRoom(%q) error = %v, want ErrRoomNotFound", id, err)
+				}
+				if err := store.UpdateRoomContent(ctx, id, "content", nil); !errors.Is(err, ErrRoomNotFound) {
+					t.Errorf("UpdateRoomContent(%q) error = %v, want ErrRoomNotFound", id, err)
+				}
+				if _, err := store.SaveRoomContent(ctx, id, "content", 0); !errors.Is(err, ErrRoomNotFound) {
+					t.Errorf("SaveRoomContent(%q) error = %v, want ErrRoomNotFound", id, err)
+				}
+			}
+			if err := store.RestoreRoom(ctx, "missing"); !errors.Is(err, ErrRoomNotFound) {
+				t.Errorf("RestoreRoom() error = %v, want ErrRoomNotFound", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/logoes0/peeriodic.git/config"
+	"github.com/logoes0/peeriodic.git/models"
+)
+
+// CloseRoom disconnects everyone editing a room that was moved to the trash.
+// JSON clients receive a room_deleted frame first. Unsaved edits are dropped,
+// since the store no longer accepts writes to the room. It returns the number
+// of clients disconnected.
+func (ws *WebSocketService) CloseRoom(roomID string) int {
+	ws.mu.RLock()
+	room, exists := ws.rooms[roomID]
+	ws.mu.RUnlock()
+
+	if !exists {
+		return 0
+	}
+
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	room.deleted = true
+	closed := 0
+	for client := range room.Clients {
+		ws.closeIfDeleted(client, room)
+		closed++
+	}
+	for client := range room.CRDTClients {
+		ws.closeIfDeleted(client, room)
+		closed++
+	}
+
+	log.Printf("🗑️ Room %s deleted, disconnected %d clients", roomID, closed)
+	return closed
+}
+
+// closeIfDeleted tells a client its room is gone and closes the connection.
+// The caller must hold roomManager.mu.
+func (ws *WebSocketService) closeIfDeleted(client *Client, roomManager *RoomManager) {
+	if !roomManager.deleted {
+		return
+	}
+	if roomManager.Clients[client] {
+		client.SendJSON(models.Message{
+			Type:     "room_deleted",
+			Data:     "This room has been deleted",
+			Revision: roomManager.Revision,
+		})
+	}
+	client.Close(websocket.CloseNormalClosure, "room deleted")
+}
+
+// RunTrashPurge permanently deletes rooms that have been in the trash longer
+// than the retention period, once at start and then every purge interval,
+// until ctx is cancelled. It returns straight away when retention is zero.
+func RunTrashPurge(ctx context.Context, store Store, cfg config.TrashConfig) {
+	if cfg.Retention <= 0 || cfg.PurgeInterval <= 0 {
+		log.Printf("🗑️ Trash purge disabled, deleted rooms are kept forever")
+		return
+	}
+
+	ticker := time.NewTicker(cfg.PurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := store.PurgeDeletedRooms(ctx, time.Now().Add(-cfg.Retention))
+		if err != nil {
+			log.Printf("❌ Failed to purge trash: %v", err)
+		} else if purged > 0 {
+			log.Printf("🗑️ Purged %d rooms deleted more than %s ago", purged, cfg.Retention)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -1,35 +1,203 @@
 package services
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"sync"
+	"sync/atomic"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
+	"github.com/logoes0/peeriodic.git/auth"
 	"github.com/logoes0/peeriodic.git/config"
+	"github.com/logoes0/peeriodic.git/crdt"
 	"github.com/logoes0/peeriodic.git/models"
+	"github.com/logoes0/peeriodic.git/ot"
+)
+
+// Errors returned when an edit cannot be applied to a room
+var (
+	ErrStaleRevision    = errors.New("stale revision")
+	ErrInvalidRevision  = errors.New("invalid revision")
+	ErrInvalidOperation = errors.New("invalid operation")
+	ErrDocumentTooLarge = errors.New("document too large")
+	ErrRateLimited      = errors.New("rate limit exceeded, message dropped")
+	ErrReadOnly         = errors.New("read only")
 )
 
 // WebSocketService handles WebSocket connections and real-time communication
 type WebSocketService struct {
-	config *config.Config
-	rooms  map[string]*RoomManager
-	mu     sync.RWMutex
+	config     *config.Config
+	rooms      map[string]*RoomManager
+	limiter    Limiter
+	mu         sync.RWMutex
+	violations violationCounters
+	// connections counts running connection handlers, so shutdown can wait
+	// for them; shuttingDown is only set while holding mu
+	connections  sync.WaitGroup
+	shuttingDown atomic.Bool
+	// ctx scopes the background database writes of live rooms. It is
+	// cancelled when shutdown gives up waiting for them.
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// violationCounters counts clients breaking the connection limits
+type violationCounters struct {
+	oversizedMessages   atomic.Int64
+	oversizedDocuments  atomic.Int64
+	rateLimitedMessages atomic.Int64
+	policyCloses        atomic.Int64
+}
+
+// ViolationStats is a snapshot of the limit violations seen since startup
+type ViolationStats struct {
+	OversizedMessages   int64 `json:"oversizedMessages"`
+	OversizedDocuments  int64 `json:"oversizedDocuments"`
+	RateLimitedMessages int64 `json:"rateLimitedMessages"`
+	PolicyCloses        int64 `json:"policyCloses"`
 }
 
-// RoomManager manages clients and document state for a specific room
+// RoomManager manages clients and document state for a specific room. Epoch
+// identifies this instance of the room: revisions restart from zero whenever
+// the room is loaded again, so a revision is only meaningful with its epoch.
 type RoomManager struct {
-	ID       string
-	Clients  map[*websocket.Conn]bool
-	Document string
-	mu       sync.RWMutex
+	ID           string
+	Epoch        string
+	Clients      map[*Client]bool
+	CRDTClients  map[*Client]bool
+	Document     string
+	Revision     int64
+	history      [][]ot.Operation
+	historyLimit int
+	crdt         *crdt.Doc
+	crdtText     string
+	// crdtState is the saved Yjs state the CRDT document is loaded from
+	crdtState  []byte
+	peers      map[*Client]*peer
+	maxDocSize int
+	persister  *roomPersister
+	store      Store
+	// deleted is set once the room is moved to the trash, so clients still
+	// joining are turned away
+	deleted bool
+	mu      sync.RWMutex
+	// saveMu keeps saves of the room from overlapping while edits go on
+	saveMu sync.Mutex
+}
+
+// applyOperations transforms ops built on baseRev against every revision
+// applied since, applies them to the document and returns the transformed ops.
+// The caller must hold rm.mu.
+func (rm *RoomManager) applyOperations(baseRev int64, ops []ot.Operation) ([]ot.Operation, error) {
+	if len(ops) == 0 {
+		return nil, fmt.Errorf("%w: no operations", ErrInvalidOperation)
+	}
+	if baseRev < 0 || baseRev > rm.Revision {
+		return nil, fmt.Errorf("%w: unknown revision %d (current %d)", ErrInvalidRevision, baseRev, rm.Revision)
+	}
+	missed := rm.Revision - baseRev
+	if missed > int64(len(rm.history)) {
+		return nil, fmt.Errorf("%w: revision %d is too old (current %d)", ErrStaleRevision, baseRev, rm.Revision)
+	}
+
+	for _, applied := range rm.history[int64(len(rm.history))-missed:] {
+		ops, _ = ot.Transform(ops, applied)
+	}
+
+	document, err := ot.Apply(rm.Document, ops)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidOperation, err)
+	}
+	if err := rm.checkDocumentSize(document); err != nil {
+		return nil, err
+	}
+
+	rm.Document = document
+	rm.recordRevision(ops)
+	return ops, nil
+}
+
+// checkDocumentSize rejects documents over the room's size limit
+func (rm *RoomManager) checkDocumentSize(document string) error {
+	if rm.maxDocSize > 0 && len(document) > rm.maxDocSize {
+		return fmt.Errorf("%w: %d bytes (limit %d)", ErrDocumentTooLarge, len(document), rm.maxDocSize)
+	}
+	return nil
 }
 
-// NewWebSocketService creates a new WebSocket service instance
-func NewWebSocketService(cfg *config.Config) *WebSocketService {
+// replaceDocument replaces the whole document and records the change as
+// operations so concurrent operations can still be transformed against it.
+// The caller must hold rm.mu.
+func (rm *RoomManager) replaceDocument(content string) []ot.Operation {
+	ops := ot.Diff(rm.Document, content)
+	rm.Document = content
+	rm.recordRevision(ops)
+	return ops
+}
+
+// rebaseSave returns content with the edits made since baseRev, which were
+// applied to base while content was being saved, carried over onto it. It
+// returns content alone when the history no longer reaches back to baseRev.
+// The caller must hold rm.mu.
+func (rm *RoomManager) rebaseSave(base string, baseRev int64, content string) string {
+	missed, ok := rm.operationsSince(baseRev)
+	if !ok {
+		log.Printf("⚠️ Edits to room %s during a save are too old to keep", rm.ID)
+		return content
+	}
+
+	document := content
+	replacement := ot.Diff(base, content)
+	for _, applied := range missed {
+		var carried []ot.Operation
+		replacement, carried = ot.Transform(replacement, applied)
+		next, err := ot.Apply(document, carried)
+		if err != nil {
+			log.Printf("⚠️ Failed to keep edits to room %s made during a save: %v", rm.ID, err)
+			return content
+		}
+		document = next
+	}
+	return document
+}
+
+// recordRevision advances the revision counter and remembers the applied ops.
+// The caller must hold rm.mu.
+func (rm *RoomManager) recordRevision(ops []ot.Operation) {
+	rm.Revision++
+	rm.history = append(rm.history, ops)
+	if len(rm.history) > rm.historyLimit {
+		rm.history = rm.history[len(rm.history)-rm.historyLimit:]
+	}
+}
+
+// operationsSince returns the operations of every revision after rev, oldest
+// first. It returns false when the history no longer reaches back to rev.
+// The caller must hold rm.mu.
+func (rm *RoomManager) operationsSince(rev int64) ([][]ot.Operation, bool) {
+	missed := rm.Revision - rev
+	if rev < 0 || missed < 0 || missed > int64(len(rm.history)) {
+		return nil, false
+	}
+	return rm.history[int64(len(rm.history))-missed:], true
+}
+
+// NewWebSocketService creates a new WebSocket service instance. The limiter
+// holds the per-IP and per-room connection counts.
+func NewWebSocketService(cfg *config.Config, limiter Limiter) *WebSocketService {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &WebSocketService{
-		config: cfg,
-		rooms:  make(map[string]*RoomManager),
+		config:  cfg,
+		rooms:   make(map[string]*RoomManager),
+		limiter: limiter,
+		ctx:     ctx,
+		cancel:  cancel,
 	}
 }
 
@@ -41,21 +209,25 @@ func (ws *WebSocketService) GetUpgrader() websocket.Upgrader {
 	return websocket.Upgrader{
 		ReadBufferSize:  ws.config.WebSocket.ReadBufferSize,
 		WriteBufferSize: ws.config.WebSocket.WriteBufferSize,
-		CheckOrigin: func(r *http.Request) bool {
-			// For development, allow all origins
-			// In production, you might want to restrict this
-			origin := r.Header.Get("Origin")
-			log.Printf("🔍 Checking origin: %s", origin)
-			log.Printf("🔍 Allowing origin: %s", origin)
-			return true
-		},
+		CheckOrigin:     ws.checkOrigin,
 		// Enable compression for better performance
 		EnableCompression: true,
 	}
 }
 
+// checkOrigin refuses handshakes from browser pages on origins outside the
+// CORS allowlist, unless origin checks are turned off
+func (ws *WebSocketService) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if !ws.config.WebSocket.CheckOrigin || origin == "" || ws.config.CORS.AllowsOrigin(origin) {
+		return true
+	}
+	log.Printf("🚫 Rejected WebSocket %s from origin %q (%s)", r.URL.Path, origin, r.RemoteAddr)
+	return false
+}
+
 // HandleConnection handles a new WebSocket connection
-func (ws *WebSocketService) HandleConnection(w http.ResponseWriter, r *http.Request, dbService *DatabaseService) {
+func (ws *WebSocketService) HandleConnection(w http.ResponseWriter, r *http.Request, store Store) {
 	// Basic request logging (replacing middleware.Logging)
 	log.Printf("🌐 WebSocket request: %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
 
@@ -67,18 +239,6 @@ func (ws *WebSocketService) HandleConnection(w http.ResponseWriter, r *http.Requ
 		}
 	}()
 
-	// Set CORS headers for WebSocket connections
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-	w.Header().Set("Access-Control-Max-Age", "86400")
-
-	// Handle preflight OPTIONS request
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
 	// Extract room ID from query parameters
 	roomID := r.URL.Query().Get("room")
 	if roomID == "" {
@@ -89,11 +249,29 @@ func (ws *WebSocketService) HandleConnection(w http.ResponseWriter, r *http.Requ
 
 	log.Printf("🔌 WebSocket connection attempt for room: %s", roomID)
 
-	// Ensure room exists in database
-	room, err := dbService.EnsureRoomExists(roomID)
-	if err != nil {
-		log.Printf("❌ Failed to ensure room exists: %v", err)
-		http.Error(w, "Database error", http.StatusInternalServerError)
+	// Refuse foreign origins before the room is touched or a link is used
+	if !ws.checkOrigin(r) {
+		http.Error(w, "Origin not allowed", http.StatusForbidden)
+		return
+	}
+
+	release, ok := ws.acquireConnection(w, r, roomID)
+	if !ok {
+		return
+	}
+	defer release()
+
+	if !ws.trackConnection() {
+		http.Error(w, "Server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	defer ws.connections.Done()
+
+	// Check access before the room is touched, creating it only for a caller
+	// who then owns it
+	userID := auth.CallerUID(r, r.URL.Query().Get("uid"))
+	room, role, link, ok := ws.openRoom(w, r, store, roomID, userID)
+	if !ok {
 		return
 	}
 
@@ -103,11 +281,6 @@ func (ws *WebSocketService) HandleConnection(w http.ResponseWriter, r *http.Requ
 	upgrader := ws.GetUpgrader()
 	log.Printf("🔧 Upgrader created, attempting upgrade for room: %s", roomID)
 
-	// Log request headers for debugging
-	log.Printf("🔍 Request headers: %v", r.Header)
-	log.Printf("🔍 Request method: %s", r.Method)
-	log.Printf("🔍 Request URL: %s", r.URL.String())
-
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("❌ WebSocket upgrade failed for room %s: %v", roomID, err)
@@ -118,54 +291,113 @@ func (ws *WebSocketService) HandleConnection(w http.ResponseWriter, r *http.Requ
 	}
 
 	log.Printf("✅ WebSocket upgrade successful for room: %s", roomID)
-	defer ws.closeConnection(conn, roomID)
+	client := newClient(conn, ws.config.WebSocket)
+	client.userID, client.remoteAddr = userID, RemoteHost(r)
+	client.role, client.link = role, link
+	defer ws.closeConnection(client, roomID)
 
 	// Get or create room manager
-	roomManager := ws.getOrCreateRoom(roomID, room.Content)
+	roomManager := ws.getOrCreateRoom(room, store)
 
-	// Add client to room
+	// Add client to room and send its initial state while holding the lock, so
+	// no broadcast can slip in between
 	roomManager.mu.Lock()
-	roomManager.Clients[conn] = true
+	roomManager.Clients[client] = true
 	clientCount := len(roomManager.Clients)
+	ws.sendInitialState(client, roomManager, r.URL.Query().Get("epoch"), r.URL.Query().Get("rev"))
+	ws.joinPresence(client, roomManager, newPresence(client.userID, r.URL.Query()))
+	ws.closeIfShuttingDown(client)
+	ws.closeIfDeleted(client, roomManager)
 	roomManager.mu.Unlock()
 
 	log.Printf("✅ Client connected to room %s (total clients: %d)", roomID, clientCount)
+	ws.auditConnection(client, roomManager, AuditJoin, "websocket")
 
-	// Send initial document state to new client
-	if err := conn.WriteJSON(models.Message{
-		Type: "init",
-		Data: roomManager.Document,
-	}); err != nil {
-		log.Printf("❌ Failed to send initial document: %v", err)
-		return
+	// Handle incoming messages
+	ws.handleMessages(client, roomManager)
+}
+
+// sendInitialState brings a new connection up to date. A reconnecting client
+// that passes the epoch and last revision it saw receives a resume frame
+// followed by the missed operations; everyone else receives the full document.
+// The caller must hold roomManager.mu.
+func (ws *WebSocketService) sendInitialState(client *Client, roomManager *RoomManager, epoch, rev string) {
+	if epoch != "" && epoch == roomManager.Epoch {
+		if lastSeen, err := strconv.ParseInt(rev, 10, 64); err == nil {
+			// A backlog that would not fit in the send queue is better replaced
+			// by the full document
+			if missed, ok := roomManager.operationsSince(lastSeen); ok && len(missed) < cap(client.send)/2 {
+				log.Printf("🔁 Resuming client in room %s from revision %d (%d missed)", roomManager.ID, lastSeen, len(missed))
+				ws.sendResume(client, roomManager, lastSeen, missed)
+				return
+			}
+			log.Printf("⚠️ Revision %d no longer in history for room %s, sending full document", lastSeen, roomManager.ID)
+		}
 	}
 
-	log.Printf("✅ Initial document sent to client in room: %s", roomID)
+	client.SendJSON(models.Message{
+		Type:     "init",
+		Data:     roomManager.Document,
+		Revision: roomManager.Revision,
+		Epoch:    roomManager.Epoch,
+	})
+}
 
-	// Handle incoming messages
-	ws.handleMessages(conn, roomManager, dbService)
+// sendResume sends a resume frame and the operations applied after lastSeen
+func (ws *WebSocketService) sendResume(client *Client, roomManager *RoomManager, lastSeen int64, missed [][]ot.Operation) {
+	client.SendJSON(models.Message{
+		Type:     "resume",
+		Revision: roomManager.Revision,
+		Epoch:    roomManager.Epoch,
+	})
+
+	for i, ops := range missed {
+		client.SendJSON(models.Message{
+			Type:     "op",
+			Revision: lastSeen + int64(i) + 1,
+			Ops:      ops,
+		})
+	}
 }
 
 // handleMessages processes incoming WebSocket messages
-func (ws *WebSocketService) handleMessages(conn *websocket.Conn, roomManager *RoomManager, dbService *DatabaseService) {
+func (ws *WebSocketService) handleMessages(client *Client, roomManager *RoomManager) {
 	log.Printf("🔄 Starting message handling for room: %s", roomManager.ID)
 
 	for {
 		var msg models.Message
-		if err := conn.ReadJSON(&msg); err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+		if err := client.conn.ReadJSON(&msg); err != nil {
+			if errors.Is(err, websocket.ErrReadLimit) {
+				ws.violations.oversizedMessages.Add(1)
+				log.Printf("🚫 Client %s sent an oversized message in room %s, disconnecting", client.conn.RemoteAddr(), roomManager.ID)
+			} else if isTimeout(err) {
+				log.Printf("💀 Client in room %s stopped answering pings, evicting", roomManager.ID)
+			} else if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("❌ Client disconnected unexpectedly from room %s: %v", roomManager.ID, err)
 			} else {
 				log.Printf("📖 Client disconnected normally from room %s: %v", roomManager.ID, err)
 			}
 			break
 		}
+		client.Touch()
+		if !ws.admit(client, roomManager) {
+			if client.strikes == 1 {
+				roomManager.mu.RLock()
+				ws.sendError(client, roomManager, ErrRateLimited)
+				roomManager.mu.RUnlock()
+			}
+			continue
+		}
 
 		log.Printf("📨 Received message in room %s: type=%s, data_length=%d", roomManager.ID, msg.Type, len(msg.Data))
 
 		switch msg.Type {
 		case "update":
-			ws.handleDocumentUpdate(conn, roomManager, msg.Data, dbService)
+			ws.handleDocumentUpdate(client, roomManager, msg)
+		case "op":
+			ws.handleOperation(client, roomManager, msg)
+		case "cursor":
+			ws.handleCursor(client, roomManager, msg.Cursor)
 		default:
 			log.Printf("⚠️ Unknown message type '%s' in room %s", msg.Type, roomManager.ID)
 		}
@@ -175,69 +407,176 @@ func (ws *WebSocketService) handleMessages(conn *websocket.Conn, roomManager *Ro
 }
 
 // handleDocumentUpdate processes document update messages
-func (ws *WebSocketService) handleDocumentUpdate(conn *websocket.Conn, roomManager *RoomManager, content string, dbService *DatabaseService) {
+func (ws *WebSocketService) handleDocumentUpdate(client *Client, roomManager *RoomManager, msg models.Message) {
 	roomManager.mu.Lock()
 	defer roomManager.mu.Unlock()
 
+	if !ws.canEdit(client, roomManager) {
+		return
+	}
+
+	content := msg.Data
 	log.Printf("📝 Processing document update for room %s, content length: %d", roomManager.ID, len(content))
 
+	// Full-document updates cannot be transformed, so reject any built on an
+	// older revision. Only the first edit of a room may leave it out, since
+	// there is nothing yet it could overwrite.
+	if msg.BaseRevision == nil && roomManager.Revision > 0 {
+		err := fmt.Errorf("%w: missing base revision (current %d)", ErrInvalidRevision, roomManager.Revision)
+		log.Printf("❌ Rejected update in room %s: %v", roomManager.ID, err)
+		ws.sendError(client, roomManager, err)
+		return
+	}
+	if msg.BaseRevision != nil && *msg.BaseRevision != roomManager.Revision {
+		err := fmt.Errorf("%w: update built on revision %d (current %d)", ErrStaleRevision, *msg.BaseRevision, roomManager.Revision)
+		log.Printf("❌ Rejected update in room %s: %v", roomManager.ID, err)
+		ws.sendError(client, roomManager, err)
+		return
+	}
+	if err := roomManager.checkDocumentSize(content); err != nil {
+		ws.violations.oversizedDocuments.Add(1)
+		log.Printf("🚫 Rejected update in room %s: %v", roomManager.ID, err)
+		ws.sendError(client, roomManager, err)
+		return
+	}
+
 	// Update local document state
-	roomManager.Document = content
+	ops := roomManager.replaceDocument(content)
+	ws.broadcastCRDTUpdate(roomManager, roomManager.mirrorToCRDT(ops), nil)
+
+	// Acknowledge the sender with the revision its update became
+	client.SendJSON(models.Message{Type: "ack", Revision: roomManager.Revision})
 
 	// Broadcast to other clients in the room
-	clientCount := 0
-	for client := range roomManager.Clients {
-		if client != conn {
-			clientCount++
-			log.Printf("📤 Broadcasting to client in room %s", roomManager.ID)
-			message := models.Message{
-				Type: "update",
-				Data: content,
-			}
-			if err := client.WriteJSON(message); err != nil {
-				log.Printf("❌ Failed to broadcast to client: %v", err)
-				client.Close()
-				delete(roomManager.Clients, client)
-			} else {
-				log.Printf("✅ Successfully broadcasted to client in room %s", roomManager.ID)
-			}
+	ws.broadcastLocked(roomManager, models.Message{
+		Type:     "update",
+		Data:     content,
+		Revision: roomManager.Revision,
+	}, client)
+
+	log.Printf("📊 Broadcasted update to %d clients in room %s", len(roomManager.Clients)-1, roomManager.ID)
+
+	// Queue the new content for the room's persistence worker
+	roomManager.persister.schedule(content)
+}
+
+// handleOperation processes operational-transform edit messages
+func (ws *WebSocketService) handleOperation(client *Client, roomManager *RoomManager, msg models.Message) {
+	roomManager.mu.Lock()
+	defer roomManager.mu.Unlock()
+
+	if !ws.canEdit(client, roomManager) {
+		return
+	}
+
+	if msg.BaseRevision == nil {
+		ws.sendError(client, roomManager, fmt.Errorf("%w: missing base revision", ErrInvalidRevision))
+		return
+	}
+
+	log.Printf("📝 Processing %d operations for room %s at revision %d", len(msg.Ops), roomManager.ID, *msg.BaseRevision)
+
+	ops, err := roomManager.applyOperations(*msg.BaseRevision, msg.Ops)
+	if err != nil {
+		if errors.Is(err, ErrDocumentTooLarge) {
+			ws.violations.oversizedDocuments.Add(1)
 		}
+		log.Printf("❌ Rejected operations in room %s: %v", roomManager.ID, err)
+		ws.sendError(client, roomManager, err)
+		return
+	}
+
+	// Acknowledge the sender with the revision its operations became
+	client.SendJSON(models.Message{Type: "ack", Revision: roomManager.Revision})
+
+	// Send the transformed operations to the other clients in the room
+	ws.broadcastOperations(roomManager, ops, client)
+	ws.broadcastCRDTUpdate(roomManager, roomManager.mirrorToCRDT(ops), nil)
+
+	// Queue the new content for the room's persistence worker
+	roomManager.persister.schedule(roomManager.Document)
+}
+
+// sendError sends a typed error frame carrying the room's current revision.
+// The caller must hold roomManager.mu.
+func (ws *WebSocketService) sendError(client *Client, roomManager *RoomManager, err error) {
+	code := models.ErrorInvalidOperation
+	switch {
+	case errors.Is(err, ErrStaleRevision):
+		code = models.ErrorStaleRevision
+	case errors.Is(err, ErrInvalidRevision):
+		code = models.ErrorInvalidRevision
+	case errors.Is(err, ErrDocumentTooLarge):
+		code = models.ErrorDocumentTooLarge
+	case errors.Is(err, ErrRateLimited):
+		code = models.ErrorRateLimited
+	case errors.Is(err, ErrReadOnly):
+		code = models.ErrorReadOnly
 	}
 
-	log.Printf("📊 Broadcasted update to %d clients in room %s", clientCount, roomManager.ID)
+	client.SendJSON(models.Message{
+		Type:     "error",
+		Code:     code,
+		Data:     err.Error(),
+		Revision: roomManager.Revision,
+	})
+}
+
+// broadcastOperations sends applied operations to every JSON client except the
+// sender. The caller must hold roomManager.mu.
+func (ws *WebSocketService) broadcastOperations(roomManager *RoomManager, ops []ot.Operation, sender *Client) {
+	ws.broadcastLocked(roomManager, models.Message{
+		Type:     "op",
+		Revision: roomManager.Revision,
+		Ops:      ops,
+	}, sender)
+}
 
-	// Persist to database asynchronously
-	go func() {
-		log.Printf("💾 Persisting document update for room %s, content length: %d", roomManager.ID, len(content))
-		if err := dbService.UpdateRoomContent(roomManager.ID, content); err != nil {
-			log.Printf("❌ Failed to persist document update: %v", err)
-		} else {
-			log.Printf("✅ Successfully persisted document update for room %s", roomManager.ID)
+// broadcastLocked queues a message for every JSON client except the sender.
+// The caller must hold roomManager.mu; nothing is written to sockets here.
+func (ws *WebSocketService) broadcastLocked(roomManager *RoomManager, message models.Message, sender *Client) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("❌ Failed to encode broadcast: %v", err)
+		return
+	}
+	for client := range roomManager.Clients {
+		if client != sender {
+			client.Send(websocket.TextMessage, data)
 		}
-	}()
+	}
 }
 
-// getOrCreateRoom returns an existing room manager or creates a new one
-func (ws *WebSocketService) getOrCreateRoom(roomID, initialContent string) *RoomManager {
+// getOrCreateRoom returns an existing room manager or creates a new one for
+// the loaded room whose edits are saved through store
+func (ws *WebSocketService) getOrCreateRoom(loaded *Room, store Store) *RoomManager {
 	ws.mu.Lock()
 	defer ws.mu.Unlock()
 
-	if room, exists := ws.rooms[roomID]; exists {
+	if room, exists := ws.rooms[loaded.ID]; exists {
 		return room
 	}
 
 	room := &RoomManager{
-		ID:       roomID,
-		Clients:  make(map[*websocket.Conn]bool),
-		Document: initialContent,
+		ID:           loaded.ID,
+		Epoch:        uuid.New().String(),
+		Clients:      make(map[*Client]bool),
+		CRDTClients:  make(map[*Client]bool),
+		Document:     loaded.Content,
+		historyLimit: max(ws.config.WebSocket.HistorySize, 0),
+		crdtState:    loaded.CRDTState,
+		peers:        make(map[*Client]*peer),
+		maxDocSize:   ws.config.WebSocket.MaxDocumentSize,
+		store:        store,
 	}
-	ws.rooms[roomID] = room
+	room.persister = newRoomPersister(ws.ctx, loaded.ID, loaded.Content, store, ws.config.Persistence, room.encodeCRDT)
+	ws.rooms[loaded.ID] = room
 	return room
 }
 
 // closeConnection removes a client from the room and cleans up if necessary
-func (ws *WebSocketService) closeConnection(conn *websocket.Conn, roomID string) {
-	conn.Close()
+func (ws *WebSocketService) closeConnection(client *Client, roomID string) {
+	client.Close(0, "")
 
 	ws.mu.RLock()
 	room, exists := ws.rooms[roomID]
@@ -248,18 +587,50 @@ func (ws *WebSocketService) closeConnection(conn *websocket.Conn, roomID string)
 	}
 
 	room.mu.Lock()
-	delete(room.Clients, conn)
-	remainingClients := len(room.Clients)
+	protocol := ""
+	if room.Clients[client] {
+		protocol = "websocket"
+	} else if room.CRDTClients[client] {
+		protocol = "yjs"
+	}
+	delete(room.Clients, client)
+	delete(room.CRDTClients, client)
+	ws.leavePresence(client, room)
+	remainingClients := len(room.Clients) + len(room.CRDTClients)
 	room.mu.Unlock()
 
 	log.Printf("Client disconnected from room %s (%d clients remaining)", roomID, remainingClients)
+	if protocol != "" {
+		ws.auditConnection(client, room, AuditLeave, protocol)
+	}
 
 	// Clean up empty rooms
 	if remainingClients == 0 {
-		ws.mu.Lock()
-		delete(ws.rooms, roomID)
-		ws.mu.Unlock()
-		log.Printf("Room %s cleaned up (no clients remaining)", roomID)
+		ws.releaseRoom(room)
+	}
+}
+
+// releaseRoom saves an empty room and forgets it. The pending content is
+// written before the room leaves the map, so a client that reconnects loads
+// the latest document from the database; a client that joined during the
+// write keeps the room alive.
+func (ws *WebSocketService) releaseRoom(room *RoomManager) {
+	room.persister.flush()
+
+	ws.mu.Lock()
+	room.mu.RLock()
+	empty := len(room.Clients)+len(room.CRDTClients) == 0
+	room.mu.RUnlock()
+	if empty && ws.rooms[room.ID] == room {
+		delete(ws.rooms, room.ID)
+	} else {
+		empty = false
+	}
+	ws.mu.Unlock()
+
+	if empty {
+		room.persister.close()
+		log.Printf("Room %s cleaned up (no clients remaining)", room.ID)
 	}
 }
 
@@ -276,13 +647,102 @@ func (ws *WebSocketService) BroadcastToRoom(roomID string, message models.Messag
 	room.mu.RLock()
 	defer room.mu.RUnlock()
 
-	for client := range room.Clients {
-		if err := client.WriteJSON(message); err != nil {
-			log.Printf("Failed to broadcast to client: %v", err)
-			client.Close()
-			delete(room.Clients, client)
-		}
+	message.Revision = room.Revision
+	ws.broadcastLocked(room, message, nil)
+}
+
+// GetDocument returns the current document of a live room, which may be newer
+// than the saved one. It returns false when the room has no connected clients.
+func (ws *WebSocketService) GetDocument(roomID string) (string, bool) {
+	ws.mu.RLock()
+	room, exists := ws.rooms[roomID]
+	ws.mu.RUnlock()
+
+	if !exists {
+		return "", false
 	}
+
+	room.mu.RLock()
+	defer room.mu.RUnlock()
+	return room.Document, true
+}
+
+// ReplaceDocument replaces the document of a live room as if a client had sent
+// a full update, pushes it to every connected client and saves it before
+// returning. It returns false when the room has no connected clients.
+func (ws *WebSocketService) ReplaceDocument(roomID, content string) (bool, error) {
+	ws.mu.RLock()
+	room, exists := ws.rooms[roomID]
+	ws.mu.RUnlock()
+
+	if !exists {
+		return false, nil
+	}
+
+	room.mu.Lock()
+	ops := room.replaceDocument(content)
+	ws.broadcastCRDTUpdate(room, room.mirrorToCRDT(ops), nil)
+	ws.broadcastLocked(room, models.Message{
+		Type:     "update",
+		Data:     content,
+		Revision: room.Revision,
+	}, nil)
+	room.persister.schedule(content)
+	room.mu.Unlock()
+
+	if !room.persister.flush() {
+		return true, fmt.Errorf("failed to save document of room %s", roomID)
+	}
+	return true, nil
+}
+
+// SaveDocument saves content as a room's document and returns its new
+// version. With a nonzero ifVersion the save only happens while the room is
+// at that version. A live room first saves the edits its clients have made,
+// so they count as changes, and then pushes the saved document to them. Its
+// clients keep editing during the write; their edits are carried over onto
+// the saved document. It reports whether the room was live.
+func (ws *WebSocketService) SaveDocument(ctx context.Context, store Store, roomID, content string, ifVersion int64) (int64, bool, error) {
+	ws.mu.RLock()
+	room, exists := ws.rooms[roomID]
+	ws.mu.RUnlock()
+
+	if !exists {
+		version, err := store.SaveRoomContent(ctx, roomID, content, ifVersion)
+		return version, false, err
+	}
+
+	room.saveMu.Lock()
+	defer room.saveMu.Unlock()
+
+	room.mu.RLock()
+	base, baseRev := room.Document, room.Revision
+	room.mu.RUnlock()
+
+	// The database is written without holding the room, so readers, editors
+	// and broadcasts never wait on it
+	version, err := room.persister.saveNow(ctx, content, ifVersion)
+	if err != nil {
+		return version, true, err
+	}
+
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	document := content
+	if room.Revision != baseRev {
+		document = room.rebaseSave(base, baseRev, content)
+		// The persister holds the edits without the save; replace them
+		room.persister.schedule(document)
+	}
+	ops := room.replaceDocument(document)
+	ws.broadcastCRDTUpdate(room, room.mirrorToCRDT(ops), nil)
+	ws.broadcastLocked(room, models.Message{
+		Type:     "update",
+		Data:     document,
+		Revision: room.Revision,
+	}, nil)
+	return version, true, nil
 }
 
 // GetRoomStats returns statistics about active rooms
@@ -293,8 +753,18 @@ func (ws *WebSocketService) GetRoomStats() map[string]int {
 	stats := make(map[string]int)
 	for roomID, room := range ws.rooms {
 		room.mu.RLock()
-		stats[roomID] = len(room.Clients)
+		stats[roomID] = len(room.Clients) + len(room.CRDTClients)
 		room.mu.RUnlock()
 	}
 	return stats
 }
+
+// GetViolationStats returns how often clients broke the connection limits
+func (ws *WebSocketService) GetViolationStats() ViolationStats {
+	return ViolationStats{
+		OversizedMessages:   ws.violations.oversizedMessages.Load(),
+		OversizedDocuments:  ws.violations.oversizedDocuments.Load(),
+		RateLimitedMessages: ws.violations.rateLimitedMessages.Load(),
+		PolicyCloses:        ws.violations.policyCloses.Load(),
+	}
+}
@@ -0,0 +1,287 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/websocket"
+	"github.com/logoes0/peeriodic.git/auth"
+	"github.com/logoes0/peeriodic.git/crdt"
+	"github.com/logoes0/peeriodic.git/ot"
+)
+
+// HandleYjsConnection handles a binary WebSocket connection speaking the Yjs
+// sync protocol. The room ID is taken from the path (/yjs/{roomId}, as used by
+// y-websocket providers) or from the room query parameter.
+func (ws *WebSocketService) HandleYjsConnection(w http.ResponseWriter, r *http.Request, store Store) {
+	log.Printf("🌐 Yjs WebSocket request: %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+
+	roomID := strings.Trim(strings.TrimPrefix(r.URL.Path, "/yjs"), "/")
+	if roomID == "" {
+		roomID = r.URL.Query().Get("room")
+	}
+	if roomID == "" {
+		log.Printf("❌ Yjs connection attempt without room ID")
+		http.Error(w, "Missing room ID", http.StatusBadRequest)
+		return
+	}
+
+	// Refuse foreign origins before the room is touched or a link is used
+	if !ws.checkOrigin(r) {
+		http.Error(w, "Origin not allowed", http.StatusForbidden)
+		return
+	}
+
+	release, ok := ws.acquireConnection(w, r, roomID)
+	if !ok {
+		return
+	}
+	defer release()
+
+	if !ws.trackConnection() {
+		http.Error(w, "Server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	defer ws.connections.Done()
+
+	userID := auth.CallerUID(r, r.URL.Query().Get("uid"))
+	room, role, link, ok := ws.openRoom(w, r, store, roomID, userID)
+	if !ok {
+		return
+	}
+
+	upgrader := ws.GetUpgrader()
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("❌ Yjs WebSocket upgrade failed for room %s: %v", roomID, err)
+		return
+	}
+	client := newClient(conn, ws.config.WebSocket)
+	client.userID, client.remoteAddr = userID, RemoteHost(r)
+	client.role, client.link = role, link
+	defer ws.closeConnection(client, roomID)
+
+	roomManager := ws.getOrCreateRoom(room, store)
+
+	roomManager.mu.Lock()
+	roomManager.ensureCRDT(ws.config.WebSocket.YjsTextName)
+	roomManager.CRDTClients[client] = true
+	clientCount := len(roomManager.CRDTClients)
+	// Start the handshake by asking the client for everything the server lacks
+	client.Send(websocket.BinaryMessage, crdt.EncodeSyncMessage(crdt.SyncStep1, roomManager.crdt.EncodeStateVector()))
+	ws.closeIfShuttingDown(client)
+	ws.closeIfDeleted(client, roomManager)
+	roomManager.mu.Unlock()
+
+	log.Printf("✅ Yjs client connected to room %s (total Yjs clients: %d)", roomID, clientCount)
+	ws.auditConnection(client, roomManager, AuditJoin, "yjs")
+
+	ws.handleYjsMessages(client, roomManager)
+}
+
+// handleYjsMessages processes incoming Yjs protocol messages
+func (ws *WebSocketService) handleYjsMessages(client *Client, roomManager *RoomManager) {
+	for {
+		messageType, data, err := client.conn.ReadMessage()
+		if err != nil {
+			if errors.Is(err, websocket.ErrReadLimit) {
+				ws.violations.oversizedMessages.Add(1)
+				log.Printf("🚫 Yjs client %s sent an oversized message in room %s, disconnecting", client.conn.RemoteAddr(), roomManager.ID)
+			} else if isTimeout(err) {
+				log.Printf("💀 Yjs client in room %s stopped answering pings, evicting", roomManager.ID)
+			} else if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				log.Printf("❌ Yjs client disconnected unexpectedly from room %s: %v", roomManager.ID, err)
+			} else {
+				log.Printf("📖 Yjs client disconnected normally from room %s: %v", roomManager.ID, err)
+			}
+			return
+		}
+		client.Touch()
+		if !ws.admit(client, roomManager) {
+			continue
+		}
+		if messageType != websocket.BinaryMessage {
+			log.Printf("⚠️ Ignoring non-binary message on Yjs connection in room %s", roomManager.ID)
+			continue
+		}
+
+		msg, err := crdt.DecodeMessage(data)
+		if err != nil {
+			log.Printf("⚠️ Invalid Yjs message in room %s: %v", roomManager.ID, err)
+			continue
+		}
+
+		switch msg.Type {
+		case crdt.MessageSync:
+			ws.handleYjsSync(client, roomManager, msg)
+		case crdt.MessageAwareness:
+			// Awareness is ephemeral, relay it as is
+			roomManager.mu.Lock()
+			ws.broadcastCRDTMessage(roomManager, data, client)
+			roomManager.mu.Unlock()
+		default:
+			log.Printf("⚠️ Unsupported Yjs message type %d in room %s", msg.Type, roomManager.ID)
+		}
+	}
+}
+
+// handleYjsSync processes sync protocol messages
+func (ws *WebSocketService) handleYjsSync(client *Client, roomManager *RoomManager, msg *crdt.Message) {
+	roomManager.mu.Lock()
+	defer roomManager.mu.Unlock()
+
+	switch msg.SyncType {
+	case crdt.SyncStep1:
+		update, err := roomManager.crdt.EncodeStateAsUpdate(msg.Payload)
+		if err != nil {
+			log.Printf("⚠️ Invalid state vector in room %s: %v", roomManager.ID, err)
+			return
+		}
+		client.Send(websocket.BinaryMessage, crdt.EncodeSyncMessage(crdt.SyncStep2, update))
+
+	case crdt.SyncStep2, crdt.SyncUpdate:
+		// Yjs has no way to refuse an update, so a viewer's edits are dropped
+		if !ws.canEdit(client, roomManager) {
+			return
+		}
+		ops, err := roomManager.applyCRDTUpdate(msg.Payload)
+		if errors.Is(err, ErrDocumentTooLarge) {
+			// The client already applied its update and there is no way to
+			// tell it otherwise, so drop it and make it resync
+			ws.violations.oversizedDocuments.Add(1)
+			log.Printf("🚫 Rejected Yjs update in room %s, disconnecting: %v", roomManager.ID, err)
+			client.Close(websocket.CloseMessageTooBig, "document too large")
+			return
+		}
+		if err != nil {
+			log.Printf("⚠️ Rejected Yjs update in room %s: %v", roomManager.ID, err)
+			return
+		}
+		ws.broadcastCRDTUpdate(roomManager, msg.Payload, client)
+
+		if len(ops) == 0 {
+			return
+		}
+		ws.broadcastOperations(roomManager, ops, nil)
+
+		roomManager.persister.schedule(roomManager.Document)
+	}
+}
+
+// broadcastCRDTUpdate queues a Yjs update to every Yjs client except the
+// sender. The caller must hold roomManager.mu.
+func (ws *WebSocketService) broadcastCRDTUpdate(roomManager *RoomManager, update []byte, sender *Client) {
+	if len(update) == 0 {
+		return
+	}
+	ws.broadcastCRDTMessage(roomManager, crdt.EncodeSyncMessage(crdt.SyncUpdate, update), sender)
+}
+
+// broadcastCRDTMessage queues a binary message for every Yjs client except
+// the sender. The caller must hold roomManager.mu.
+func (ws *WebSocketService) broadcastCRDTMessage(roomManager *RoomManager, data []byte, sender *Client) {
+	for client := range roomManager.CRDTClients {
+		if client != sender {
+			client.Send(websocket.BinaryMessage, data)
+		}
+	}
+}
+
+// ensureCRDT creates the room's CRDT document on first use. It is loaded from
+// the saved Yjs state, so clients that reconnect with their own copy of it do
+// not see the text twice, and then brought up to date with the current text.
+// A room without a saved state is seeded with the text. The caller must hold
+// rm.mu.
+func (rm *RoomManager) ensureCRDT(textName string) {
+	if rm.crdt != nil {
+		return
+	}
+	rm.crdtText = textName
+	if rm.crdtState != nil {
+		doc, err := crdt.LoadDoc(rm.crdtState)
+		if err != nil {
+			log.Printf("⚠️ Discarding saved Yjs state of room %s: %v", rm.ID, err)
+		} else {
+			rm.crdt = doc
+		}
+		rm.crdtState = nil
+	}
+	if rm.crdt == nil {
+		rm.crdt = crdt.NewDoc()
+	}
+	rm.mirrorToCRDT(ot.Diff(rm.crdt.Text(textName), rm.Document))
+}
+
+// encodeCRDT returns the room's Yjs state for saving, or nil when no Yjs
+// client has joined the room
+func (rm *RoomManager) encodeCRDT() []byte {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+
+	if rm.crdt == nil {
+		return nil
+	}
+	state, err := rm.crdt.EncodeStateAsUpdate(nil)
+	if err != nil {
+		log.Printf("⚠️ Failed to encode Yjs state of room %s: %v", rm.ID, err)
+		return nil
+	}
+	return state
+}
+
+// applyCRDTUpdate integrates a Yjs update and records the resulting text
+// change as a new revision. An update that takes the document over the size
+// limit is rolled back. The caller must hold rm.mu.
+func (rm *RoomManager) applyCRDTUpdate(update []byte) ([]ot.Operation, error) {
+	// Yjs updates cannot be undone, so keep the state to restore instead
+	var snapshot []byte
+	if rm.maxDocSize > 0 {
+		var err error
+		if snapshot, err = rm.crdt.EncodeStateAsUpdate(nil); err != nil {
+			return nil, err
+		}
+	}
+	if err := rm.crdt.ApplyUpdate(update); err != nil {
+		return nil, err
+	}
+
+	document := rm.crdt.Text(rm.crdtText)
+	if err := rm.checkDocumentSize(document); err != nil {
+		doc, loadErr := crdt.LoadDoc(snapshot)
+		if loadErr != nil {
+			return nil, fmt.Errorf("restoring Yjs state: %w", loadErr)
+		}
+		rm.crdt = doc
+		return nil, err
+	}
+
+	ops := ot.Diff(rm.Document, document)
+	if len(ops) == 0 {
+		return nil, nil
+	}
+	rm.Document = document
+	rm.recordRevision(ops)
+	return ops, nil
+}
+
+// mirrorToCRDT applies operations from JSON clients to the CRDT document and
+// returns the Yjs update for them. It returns nil when no Yjs client has
+// joined the room. The caller must hold rm.mu.
+func (rm *RoomManager) mirrorToCRDT(ops []ot.Operation) []byte {
+	if rm.crdt == nil {
+		return nil
+	}
+	return rm.crdt.Transact(func() {
+		for _, op := range ops {
+			switch op.Type {
+			case ot.OpInsert:
+				rm.crdt.InsertText(rm.crdtText, uint64(op.Pos), op.Text)
+			case ot.OpDelete:
+				rm.crdt.DeleteText(rm.crdtText, uint64(op.Pos), uint64(op.Len))
+			}
+		}
+	})
+}
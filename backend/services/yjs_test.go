@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/logoes0/peeriodic.git/crdt"
+)
+
+// loadRoomManager builds a room manager the way a join does, from the room as
+// EnsureRoomExists returns it
+func loadRoomManager(t *testing.T, store Store, roomID string) *RoomManager {
+	t.Helper()
+	room, err := store.EnsureRoomExists(context.Background(), roomID, "alice")
+	if err != nil {
+		t.Fatalf("EnsureRoomExists() error = %v", err)
+	}
+	rm := &RoomManager{ID: room.ID, Document: room.Content, crdtState: room.CRDTState}
+	rm.ensureCRDT("content")
+	return rm
+}
+
+func TestYjsStateSurvivesReload(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			client := crdt.NewDoc()
+			edit := client.Transact(func() { client.InsertText("content", 0, "hello") })
+
+			rm := loadRoomManager(t, store, "room")
+			if _, err := rm.applyCRDTUpdate(edit); err != nil {
+				t.Fatalf("applyCRDTUpdate() error = %v", err)
+			}
+			if err := store.UpdateRoomContent(ctx, rm.ID, rm.Document, rm.encodeCRDT()); err != nil {
+				t.Fatalf("UpdateRoomContent() error = %v", err)
+			}
+
+			// The client reconnects to a reloaded room and sends everything it has
+			reloaded := loadRoomManager(t, store, "room")
+			state, err := client.EncodeStateAsUpdate(nil)
+			if err != nil {
+				t.Fatalf("EncodeStateAsUpdate() error = %v", err)
+			}
+			if _, err := reloaded.applyCRDTUpdate(state); err != nil {
+				t.Fatalf("applyCRDTUpdate() error = %v", err)
+			}
+			if reloaded.Document != "hello" {
+				t.Errorf("Document = %q, want %q", reloaded.Document, "hello")
+			}
+
+			// Saving without a Yjs document keeps the stored state
+			if err := store.UpdateRoomContent(ctx, rm.ID, "hello world", nil); err != nil {
+				t.Fatalf("UpdateRoomContent() error = %v", err)
+			}
+			updated := loadRoomManager(t, store, "room")
+			if got := updated.crdt.Text("content"); got != "hello world" {
+				t.Errorf("Text() = %q, want %q", got, "hello world")
+			}
+			if err := updated.crdt.ApplyUpdate(state); err != nil {
+				t.Fatalf("ApplyUpdate() error = %v", err)
+			}
+			if got := updated.crdt.Text("content"); got != "hello world" {
+				t.Errorf("Text() after the client's state = %q, want %q", got, "hello world")
+			}
+		})
+	}
+}
+
+func TestYjsUpdateOverSizeLimitIsRolledBack(t *testing.T) {
+	rm := loadRoomManager(t, NewMemoryStore(), "room")
+	rm.maxDocSize = 8
+	client := crdt.NewDoc()
+
+	edit := client.Transact(func() { client.InsertText("content", 0, "hello") })
+	if _, err := rm.applyCRDTUpdate(edit); err != nil {
+		t.Fatalf("applyCRDTUpdate() error = %v", err)
+	}
+	tooLarge := client.Transact(func() { client.InsertText("content", 5, " world") })
+	if _, err := rm.applyCRDTUpdate(tooLarge); !errors.Is(err, ErrDocumentTooLarge) {
+		t.Fatalf("applyCRDTUpdate() over the limit error = %v, want ErrDocumentTooLarge", err)
+	}
+	if rm.Document != "hello" || rm.Revision != 1 {
+		t.Errorf("Document = %q at revision %d, want %q at revision 1", rm.Document, rm.Revision, "hello")
+	}
+	if got := rm.crdt.Text("content"); got != "hello" {
+		t.Errorf("Text() = %q, want %q", got, "hello")
+	}
+
+	// The restored document still takes updates from other clients
+	other := crdt.NewDoc()
+	state, err := rm.crdt.EncodeStateAsUpdate(nil)
+	if err != nil {
+		t.Fatalf("EncodeStateAsUpdate() error = %v", err)
+	}
+	if err := other.ApplyUpdate(state); err != nil {
+		t.Fatalf("ApplyUpdate() error = %v", err)
+	}
+	edit = other.Transact(func() { other.InsertText("content", 5, "!") })
+	if _, err := rm.applyCRDTUpdate(edit); err != nil {
+		t.Fatalf("applyCRDTUpdate() error = %v", err)
+	}
+	if rm.Document != "hello!" {
+		t.Errorf("Document = %q, want %q", rm.Document, "hello!")
+	}
+}
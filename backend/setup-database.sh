@@ -48,11 +48,6 @@ EOF
 
 echo "✅ Database and user created successfully!"
 
-echo "📝 Running setup script..."
-
-# Run the setup script
-sudo -u postgres psql -d peeriodic -f setup.sql
-
 echo "🎉 Database setup complete!"
 echo ""
 echo "📋 Next steps:"
@@ -64,10 +59,10 @@ echo "   DB_HOST=localhost"
 echo "   DB_PORT=5432"
 echo "   DB_SSLMODE=disable"
 echo ""
-echo "2. Test the connection:"
-echo "   psql -U logoes -d peeriodic -c \"SELECT COUNT(*) FROM users;\""
-echo ""
-echo "3. Run the application:"
+echo "2. Run the application (it creates the tables on first start):"
 echo "   cd backend && go run main.go"
 echo ""
+echo "3. Check the schema:"
+echo "   cd backend && go run . migrate status"
+echo ""
 echo "🔒 Security note: Change the default password in production!"
@@ -3,6 +3,8 @@ package utils
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 // Response represents a standard API response
@@ -11,6 +13,9 @@ type Response struct {
 	Message string      `json:"message,omitempty"`
 	Data    interface{} `json:"data,omitempty"`
 	Error   string      `json:"error,omitempty"`
+	// NextCursor fetches the next page of a paged list; it is empty on the
+	// last page
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
 // JSONResponse sends a JSON response with the given status code
@@ -32,6 +37,16 @@ func SuccessResponse(w http.ResponseWriter, data interface{}) {
 	JSONResponse(w, http.StatusOK, response)
 }
 
+// PageResponse sends one page of a list with the cursor of the next page
+func PageResponse(w http.ResponseWriter, data interface{}, nextCursor string) {
+	response := Response{
+		Success:    true,
+		Data:       data,
+		NextCursor: nextCursor,
+	}
+	JSONResponse(w, http.StatusOK, response)
+}
+
 // ErrorResponse sends an error JSON response
 func ErrorResponse(w http.ResponseWriter, statusCode int, message string) {
 	response := Response{
@@ -46,6 +61,30 @@ func BadRequest(w http.ResponseWriter, message string) {
 	ErrorResponse(w, http.StatusBadRequest, message)
 }
 
+// Unauthorized sends a 401 Unauthorized response asking for a bearer token
+func Unauthorized(w http.ResponseWriter, message string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="peeriodic"`)
+	ErrorResponse(w, http.StatusUnauthorized, message)
+}
+
+// Forbidden sends a 403 Forbidden response
+func Forbidden(w http.ResponseWriter, message string) {
+	ErrorResponse(w, http.StatusForbidden, message)
+}
+
+// TooManyRequests sends a 429 Too Many Requests response telling the client
+// when to retry
+func TooManyRequests(w http.ResponseWriter, message string, retryAfter time.Duration) {
+	SetRetryAfter(w, retryAfter)
+	ErrorResponse(w, http.StatusTooManyRequests, message)
+}
+
+// SetRetryAfter sets the Retry-After header in whole seconds, rounded up
+func SetRetryAfter(w http.ResponseWriter, retryAfter time.Duration) {
+	seconds := max(int64((retryAfter+time.Second-1)/time.Second), 1)
+	w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+}
+
 // NotFound sends a 404 Not Found response
 func NotFound(w http.ResponseWriter, message string) {
 	ErrorResponse(w, http.StatusNotFound, message)
@@ -56,6 +95,11 @@ func InternalServerError(w http.ResponseWriter, message string) {
 	ErrorResponse(w, http.StatusInternalServerError, message)
 }
 
+// PreconditionFailed sends a 412 Precondition Failed response
+func PreconditionFailed(w http.ResponseWriter, message string) {
+	ErrorResponse(w, http.StatusPreconditionFailed, message)
+}
+
 // MethodNotAllowed sends a 405 Method Not Allowed response
 func MethodNotAllowed(w http.ResponseWriter) {
 	ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
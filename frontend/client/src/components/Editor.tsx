@@ -2,6 +2,7 @@ import React, { useState, useEffect, useCallback, useRef } from 'react';
 import { WebSocketMessage } from '../types';
 import { StorageService } from '../utils/storage';
 import { UrlUtils } from '../utils/url';
+import { TextUtils } from '../utils/text';
 import apiService from '../services/api';
 import wsService from '../services/websocket';
 import './Editor.css';
@@ -19,11 +20,38 @@ const Editor: React.FC<EditorProps> = ({ roomId, roomName, onBack, onShare }) =>
   const [isSaving, setIsSaving] = useState(false);
   const [copiedRoomId, setCopiedRoomId] = useState<string | null>(null);
   const autoSaveIntervalRef = useRef<NodeJS.Timeout | null>(null);
-  const lastUpdateRef = useRef<string>('');
-  const isLocalUpdateRef = useRef<boolean>(false);
-  const lastSentContentRef = useRef<string>('');
+  // Latest local content, readable from the WebSocket handlers
+  const documentRef = useRef<string>('');
+  // The server's document at revisionRef
+  const serverContentRef = useRef<string>('');
+  const revisionRef = useRef<number>(0);
+  // Content of the update awaiting its ack, or null when none is in flight
+  const sentContentRef = useRef<string | null>(null);
+  const syncedRef = useRef<boolean>(false);
   const wsConnectedRef = useRef<boolean>(false);
 
+  const updateDocument = useCallback((content: string) => {
+    documentRef.current = content;
+    setDocument(content);
+  }, []);
+
+  // Sends the local content if it differs from the server's. Only one update
+  // is in flight at a time, as each one must be built on the revision the
+  // previous one became.
+  const sendPending = useCallback(() => {
+    if (!wsConnectedRef.current || sentContentRef.current !== null) return;
+    const content = documentRef.current;
+    if (content === serverContentRef.current) return;
+
+    sentContentRef.current = content;
+    console.log('📤 Sending document update via WebSocket');
+    wsService.send({
+      type: 'update',
+      data: content,
+      baseRev: revisionRef.current,
+    });
+  }, []);
+
   // Load initial document content
   useEffect(() => {
     const loadDocument = async () => {
@@ -31,9 +59,11 @@ const Editor: React.FC<EditorProps> = ({ roomId, roomName, onBack, onShare }) =>
         setIsLoading(true);
         const response = await apiService.getDocument(roomId);
         const content = response.content || '';
-        setDocument(content);
-        lastUpdateRef.current = content;
-        lastSentContentRef.current = content;
+        // The WebSocket's initial state is newer, so keep it if it came first
+        if (!syncedRef.current) {
+          updateDocument(content);
+          serverContentRef.current = content;
+        }
       } catch (error) {
         console.error('Failed to load document:', error);
       } finally {
@@ -42,7 +72,7 @@ const Editor: React.FC<EditorProps> = ({ roomId, roomName, onBack, onShare }) =>
     };
 
     loadDocument();
-  }, [roomId]);
+  }, [roomId, updateDocument]);
 
   // Setup WebSocket connection
   useEffect(() => {
@@ -64,13 +94,40 @@ const Editor: React.FC<EditorProps> = ({ roomId, roomName, onBack, onShare }) =>
           if (!isActive) return;
           
           console.log('Editor received message:', message);
-          if (message.type === 'init' || message.type === 'update') {
-            // Only update if this is not a local update and content is different
-            if (!isLocalUpdateRef.current && message.data !== lastUpdateRef.current) {
-              console.log('🔄 Updating document from WebSocket:', message.data.substring(0, 50) + '...');
-              setDocument(message.data);
-              lastUpdateRef.current = message.data;
-              lastSentContentRef.current = message.data;
+          if (message.type === 'init') {
+            // Our next update is built on this revision, so take its content
+            // too, or the server rejects the update as stale
+            const data = message.data ?? '';
+            syncedRef.current = true;
+            revisionRef.current = message.rev ?? 0;
+            serverContentRef.current = data;
+            sentContentRef.current = null;
+            updateDocument(data);
+          } else if (message.type === 'update' || message.type === 'op') {
+            // Another client's edit. Keep any local edit not yet accepted by
+            // the server on top of it, so the retry does not undo theirs.
+            const remote = message.type === 'op'
+              ? TextUtils.applyOperations(serverContentRef.current, message.ops ?? [])
+              : message.data ?? '';
+            console.log('🔄 Updating document from WebSocket:', remote.substring(0, 50) + '...');
+            updateDocument(TextUtils.merge(serverContentRef.current, documentRef.current, remote));
+            serverContentRef.current = remote;
+            revisionRef.current = message.rev ?? revisionRef.current;
+          } else if (message.type === 'ack') {
+            revisionRef.current = message.rev ?? revisionRef.current;
+            serverContentRef.current = sentContentRef.current ?? serverContentRef.current;
+            sentContentRef.current = null;
+            sendPending();
+          } else if (message.type === 'error') {
+            console.warn('⚠️ Update rejected by the server:', message.code, message.data);
+            sentContentRef.current = null;
+            if (message.code === 'stale_revision' || message.code === 'invalid_revision') {
+              // The edits that made ours stale arrived before this error and
+              // are already merged in, so retry on the current revision
+              revisionRef.current = message.rev ?? revisionRef.current;
+              sendPending();
+            } else if (message.code === 'rate_limited') {
+              setTimeout(sendPending, 1000);
             }
           }
         });
@@ -100,7 +157,7 @@ const Editor: React.FC<EditorProps> = ({ roomId, roomName, onBack, onShare }) =>
       wsConnectedRef.current = false;
       wsService.disconnect();
     };
-  }, [roomId]);
+  }, [roomId, updateDocument, sendPending]);
 
   // Auto-save functionality
   useEffect(() => {
@@ -118,28 +175,11 @@ const Editor: React.FC<EditorProps> = ({ roomId, roomName, onBack, onShare }) =>
   }, [document]);
 
   const handleDocumentChange = useCallback((e: React.ChangeEvent<HTMLTextAreaElement>) => {
-    const newContent = e.target.value;
-    
-    // Update local state immediately
-    setDocument(newContent);
-    
-    // Only send WebSocket update if content has actually changed and we're connected
-    if (wsConnectedRef.current && newContent !== lastSentContentRef.current) {
-      isLocalUpdateRef.current = true;
-      lastSentContentRef.current = newContent;
-      
-      console.log('📤 Sending document update via WebSocket');
-      wsService.send({
-        type: 'update',
-        data: newContent,
-      });
-
-      // Reset the flag after a longer delay to account for network latency
-      setTimeout(() => {
-        isLocalUpdateRef.current = false;
-      }, 1000); // Increased timeout to 1 second
-    }
-  }, []);
+    // Update local state immediately, then send it unless an update is
+    // already in flight; its ack sends whatever changed meanwhile
+    updateDocument(e.target.value);
+    sendPending();
+  }, [updateDocument, sendPending]);
 
   const handleSave = useCallback(async () => {
     if (!document.trim()) return;
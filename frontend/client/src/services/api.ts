@@ -6,8 +6,11 @@ import {
   SaveDocumentRequest,
   SaveDocumentResponse 
 } from '../types';
+import { StorageService } from '../utils/storage';
 
 const API_BASE_URL = process.env.REACT_APP_API_URL || 'http://localhost:5000';
+// Largest page the server hands out
+const ROOM_PAGE_SIZE = 200;
 
 class ApiService {
   private baseUrl: string;
@@ -20,13 +23,20 @@ class ApiService {
     endpoint: string, 
     options: RequestInit = {}
   ): Promise<T> {
-    const url = `${this.baseUrl}${endpoint}`;
-    
+    // Identify the caller by token, or by user ID on a server that runs
+    // without authentication
+    const separator = endpoint.includes('?') ? '&' : '?';
+    const uid = encodeURIComponent(StorageService.getUserId());
+    const url = `${this.baseUrl}${endpoint}${separator}uid=${uid}`;
+    const token = StorageService.getAuthToken();
+
     const defaultOptions: RequestInit = {
+      ...options,
       headers: {
         'Content-Type': 'application/json',
+        ...(token ? { Authorization: `Bearer ${token}` } : {}),
+        ...options.headers,
       },
-      ...options,
     };
 
     try {
@@ -44,9 +54,17 @@ class ApiService {
   }
 
   // Room API methods
-  async getRooms(uid: string): Promise<Room[]> {
-    const response = await this.request<ApiResponse<Room[]>>(`/api/rooms?uid=${uid}`);
-    return response.data || [];
+  // Room lists are paged, so follow the cursor until the last page
+  async getRooms(): Promise<Room[]> {
+    const rooms: Room[] = [];
+    let cursor: string | undefined;
+    do {
+      const query = cursor ? `&cursor=${encodeURIComponent(cursor)}` : '';
+      const response = await this.request<ApiResponse<Room[]>>(`/api/rooms?limit=${ROOM_PAGE_SIZE}${query}`);
+      rooms.push(...(response.data || []));
+      cursor = response.next_cursor;
+    } while (cursor);
+    return rooms;
   }
 
   async createRoom(request: CreateRoomRequest): Promise<CreateRoomResponse> {
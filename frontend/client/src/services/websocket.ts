@@ -1,4 +1,5 @@
 import { WebSocketMessage } from '../types';
+import { StorageService } from '../utils/storage';
 
 type MessageHandler = (message: WebSocketMessage) => void;
 type ConnectionHandler = () => void;
@@ -35,9 +36,17 @@ class WebSocketService {
         const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
         const host = window.location.hostname;
         const port = '5000'; // Backend port is always 5000
-        const wsUrl = `${protocol}//${host}:${port}/ws?room=${roomId}`;
+        // Browsers cannot set headers on the handshake, so the token travels
+        // as access_token; the user ID is what a server without
+        // authentication goes by
+        const params = new URLSearchParams({ room: roomId, uid: StorageService.getUserId() });
+        const token = StorageService.getAuthToken();
+        if (token) {
+          params.set('access_token', token);
+        }
+        const wsUrl = `${protocol}//${host}:${port}/ws?${params}`;
         
-        console.log('🔌 Connecting to WebSocket:', wsUrl);
+        console.log('🔌 Connecting to WebSocket to room:', roomId);
         this.socket = new WebSocket(wsUrl);
         this.currentRoomId = roomId;
 
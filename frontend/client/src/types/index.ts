@@ -4,6 +4,8 @@ export interface ApiResponse<T = any> {
   message?: string;
   data?: T;
   error?: string;
+  // Fetches the next page of a paged list; absent on the last page
+  next_cursor?: string;
 }
 
 // Room types
@@ -36,9 +38,24 @@ export interface SaveDocumentResponse {
 }
 
 // WebSocket message types
+export interface Operation {
+  type: 'insert' | 'delete';
+  // Offset in UTF-16 code units
+  pos: number;
+  text?: string;
+  len?: number;
+}
+
 export interface WebSocketMessage {
-  type: 'init' | 'update';
-  data: string;
+  type: 'init' | 'update' | 'op' | 'ack' | 'error';
+  data?: string;
+  // Operations of an 'op' message, applied in order
+  ops?: Operation[];
+  // Revision of the room's document, sent by the server
+  rev?: number;
+  // Revision an edit was built on, sent by the client
+  baseRev?: number;
+  code?: string;
 }
 
 // Component props types
@@ -3,6 +3,7 @@ import { Room } from '../types';
 const STORAGE_KEYS = {
   ROOMS: 'myRooms',
   USER_ID: 'userId',
+  AUTH_TOKEN: 'authToken',
 } as const;
 
 export class StorageService {
@@ -62,6 +63,21 @@ export class StorageService {
     return userId;
   }
 
+  // Bearer token of the signed-in user, stored by whatever signs them in.
+  // Without one the server only accepts the user ID when it runs with
+  // authentication disabled.
+  static getAuthToken(): string | null {
+    return localStorage.getItem(STORAGE_KEYS.AUTH_TOKEN);
+  }
+
+  static setAuthToken(token: string | null): void {
+    if (token) {
+      localStorage.setItem(STORAGE_KEYS.AUTH_TOKEN, token);
+    } else {
+      localStorage.removeItem(STORAGE_KEYS.AUTH_TOKEN);
+    }
+  }
+
   private static generateUserId(): string {
     return `user_${Date.now()}_${Math.random().toString(36).substr(2, 9)}`;
   }
@@ -70,6 +86,7 @@ export class StorageService {
   static clearAll(): void {
     localStorage.removeItem(STORAGE_KEYS.ROOMS);
     localStorage.removeItem(STORAGE_KEYS.USER_ID);
+    localStorage.removeItem(STORAGE_KEYS.AUTH_TOKEN);
   }
 }
 
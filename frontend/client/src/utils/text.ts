@@ -0,0 +1,58 @@
+import { Operation } from '../types';
+
+// The region where two versions of a document differ: [start, endA) in the
+// first is replaced by [start, endB) in the second
+interface Change {
+  start: number;
+  endA: number;
+  endB: number;
+}
+
+export class TextUtils {
+  // Applies operations in order. Positions are UTF-16 code units, which is
+  // how both the server and JavaScript strings index text.
+  static applyOperations(text: string, ops: Operation[]): string {
+    return ops.reduce((result, op) => {
+      const pos = Math.min(op.pos, result.length);
+      if (op.type === 'insert') {
+        return result.slice(0, pos) + (op.text ?? '') + result.slice(pos);
+      }
+      return result.slice(0, pos) + result.slice(pos + (op.len ?? 0));
+    }, text);
+  }
+
+  // Merges the local and remote edits of base. Edits to separate parts of the
+  // document are both kept; when they overlap the local edit wins, as the
+  // local content is what gets sent next.
+  static merge(base: string, local: string, remote: string): string {
+    const l = TextUtils.diff(base, local);
+    const r = TextUtils.diff(base, remote);
+    if (!l) return remote;
+    if (!r) return local;
+
+    // Outside its change each version matches base, so the other version's
+    // text can be taken from the same offset
+    if (r.endA <= l.start) {
+      return remote.slice(0, r.endB) + local.slice(r.endA);
+    }
+    if (l.endA <= r.start) {
+      return local.slice(0, l.endB) + remote.slice(l.endA);
+    }
+    return local;
+  }
+
+  private static diff(a: string, b: string): Change | null {
+    if (a === b) return null;
+    let start = 0;
+    while (start < a.length && start < b.length && a[start] === b[start]) {
+      start++;
+    }
+    let endA = a.length;
+    let endB = b.length;
+    while (endA > start && endB > start && a[endA - 1] === b[endB - 1]) {
+      endA--;
+      endB--;
+    }
+    return { start, endA, endB };
+  }
+}